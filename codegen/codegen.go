@@ -0,0 +1,148 @@
+// Package codegen generates type definitions of sheets from the header of xlsx files.
+package codegen
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"unicode"
+
+	"github.com/kama2vern/cxtj/config"
+	"github.com/kama2vern/cxtj/cxtj"
+)
+
+// Generator writes type definitions of sheets in a language
+type Generator interface {
+	Generate(w io.Writer, header cxtj.XlsxHeaderMap) error
+}
+
+// Languages
+const (
+	LangGo         = "go"
+	LangTypeScript = "typescript"
+	LangCSharp     = "csharp"
+)
+
+// Options configures a generator
+type Options struct {
+	// Package is the package or namespace of generated types
+	Package string
+
+	// Types maps value types into type names. It overrides the default type table of the language.
+	Types map[string]string
+}
+
+// NewGeneratorFunc creates a generator with options
+type NewGeneratorFunc func(opts Options) Generator
+
+var generators = map[string]NewGeneratorFunc{}
+
+func init() {
+	Register(LangGo, NewGoGenerator)
+	Register(LangTypeScript, NewTypeScriptGenerator)
+	Register(LangCSharp, NewCSharpGenerator)
+}
+
+// Register adds a generator of the language
+func Register(lang string, newGenerator NewGeneratorFunc) {
+	generators[lang] = newGenerator
+	config.RegisterCodegenLanguage(lang)
+}
+
+// GetGenerator creates the registered generator of the language
+func GetGenerator(lang string, opts Options) (Generator, error) {
+	newGenerator, ok := generators[lang]
+	if !ok {
+		return nil, fmt.Errorf("unknown language: %s", lang)
+	}
+	return newGenerator(opts), nil
+}
+
+// typeName returns the type name of the value type by the configured types and the default type table.
+// Unknown value types are string as the converter outputs them.
+// Array value types like "int[]" are the element type formatted by arrayFormat unless they are configured,
+// and reference value types are the value type of their cells.
+func (o Options) typeName(defaults map[string]string, arrayFormat string, valueType string) string {
+	if t, ok := o.Types[valueType]; ok {
+		return t
+	}
+	if elementType, _, ok := cxtj.ParseArrayValueType(valueType); ok {
+		return fmt.Sprintf(arrayFormat, o.typeName(defaults, arrayFormat, elementType))
+	}
+	if _, cellValueType, ok := cxtj.ParseReferenceValueType(valueType); ok {
+		return o.typeName(defaults, arrayFormat, cellValueType)
+	}
+	if t, ok := defaults[valueType]; ok {
+		return t
+	}
+	if t, ok := o.Types[cxtj.ValueTypeString]; ok {
+		return t
+	}
+	return defaults[cxtj.ValueTypeString]
+}
+
+// column is one of the columns of a sheet
+type column struct {
+	Name string
+	cxtj.ColumnInfo
+}
+
+// sortedSheetNames returns sheet names in alphabetical order
+func sortedSheetNames(header cxtj.XlsxHeaderMap) []string {
+	ret := make([]string, 0, len(header))
+	for sheetName := range header {
+		ret = append(ret, sheetName)
+	}
+	sort.Strings(ret)
+	return ret
+}
+
+// sortedColumns returns columns in order of index. Columns without name are skipped.
+func sortedColumns(columns map[string]cxtj.ColumnInfo) []column {
+	ret := make([]column, 0, len(columns))
+	for name, info := range columns {
+		if name == "" {
+			continue
+		}
+		ret = append(ret, column{Name: name, ColumnInfo: info})
+	}
+	sort.Slice(ret, func(i, j int) bool {
+		return ret[i].Index < ret[j].Index
+	})
+	return ret
+}
+
+// splitWords splits a sheet or column name into words by characters which are not letters or digits
+func splitWords(s string) []string {
+	return strings.FieldsFunc(s, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
+
+// pascalCase converts a name like "character_id" into "CharacterId".
+// Words in initialisms are written in upper case like "ID".
+func pascalCase(s string, initialisms map[string]bool) string {
+	var b strings.Builder
+	for _, word := range splitWords(s) {
+		if initialisms[strings.ToUpper(word)] {
+			b.WriteString(strings.ToUpper(word))
+			continue
+		}
+		rs := []rune(word)
+		b.WriteRune(unicode.ToUpper(rs[0]))
+		b.WriteString(string(rs[1:]))
+	}
+	return b.String()
+}
+
+// commentLines splits a comment of the comment row into trimmed lines
+func commentLines(comment string) []string {
+	ret := []string{}
+	for _, line := range strings.Split(strings.Replace(comment, "\r\n", "\n", -1), "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			ret = append(ret, line)
+		}
+	}
+	return ret
+}
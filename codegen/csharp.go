@@ -0,0 +1,174 @@
+package codegen
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+
+	"github.com/kama2vern/cxtj/cxtj"
+)
+
+// csharpTypes maps value types into c# types
+var csharpTypes = map[string]string{
+	cxtj.ValueTypeInt:    "int",
+	cxtj.ValueTypeLong:   "long",
+	cxtj.ValueTypeFloat:  "float",
+	cxtj.ValueTypeDouble: "double",
+	cxtj.ValueTypeBool:   "bool",
+	cxtj.ValueTypeString: "string",
+}
+
+var csharpIdentifier = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+var csharpKeywords = map[string]bool{}
+
+func init() {
+	for _, k := range strings.Fields(`abstract as base bool break byte case catch char checked class const continue
+		decimal default delegate do double else enum event explicit extern false finally fixed float for foreach
+		goto if implicit in int interface internal is lock long namespace new null object operator out override
+		params private protected public readonly ref return sbyte sealed short sizeof stackalloc static string
+		struct switch this throw true try typeof uint ulong unchecked unsafe ushort using virtual void volatile while`) {
+		csharpKeywords[k] = true
+	}
+}
+
+// CSharpGenerator writes a serializable class of each sheet.
+// Field names are the keys of json as they are, so that JsonUtility of Unity can deserialize them.
+type CSharpGenerator struct {
+	opts Options
+}
+
+// NewCSharpGenerator creates CSharpGenerator
+func NewCSharpGenerator(opts Options) Generator {
+	return &CSharpGenerator{opts: opts}
+}
+
+// Generate writes c# classes in alphabetical order of sheet names.
+// Classes are put in the namespace of the package option if it is given.
+func (g *CSharpGenerator) Generate(w io.Writer, header cxtj.XlsxHeaderMap) error {
+	if g.opts.Package != "" {
+		for _, part := range strings.Split(g.opts.Package, ".") {
+			if !csharpIdentifier.MatchString(part) || csharpKeywords[part] {
+				return fmt.Errorf("invalid c# namespace: %s", g.opts.Package)
+			}
+		}
+	}
+
+	bw := bufio.NewWriter(w)
+	fmt.Fprintln(bw, "// Code generated by cxtj codegen; DO NOT EDIT.")
+	fmt.Fprintln(bw)
+	fmt.Fprintln(bw, "using System;")
+
+	indent := ""
+	if g.opts.Package != "" {
+		fmt.Fprintln(bw)
+		fmt.Fprintf(bw, "namespace %s\n{\n", g.opts.Package)
+		indent = "    "
+	}
+
+	classNames := map[string]string{}
+	for i, sheetName := range sortedSheetNames(header) {
+		name := pascalCase(sheetName, nil)
+		if !csharpIdentifier.MatchString(name) {
+			return fmt.Errorf("sheet %s: cannot convert %q into c# identifier", sheetName, sheetName)
+		}
+		if other, ok := classNames[name]; ok {
+			return fmt.Errorf("class name %s conflicts between sheet %s and %s", name, other, sheetName)
+		}
+		classNames[name] = sheetName
+
+		if i > 0 || indent == "" {
+			fmt.Fprintln(bw)
+		}
+		if err := g.writeClass(bw, indent, name, "Row of sheet "+sheetName, header[sheetName], sheetName); err != nil {
+			return err
+		}
+	}
+
+	if g.opts.Package != "" {
+		fmt.Fprintln(bw, "}")
+	}
+	return bw.Flush()
+}
+
+// csharpClass is a nested class to be generated from columns
+type csharpClass struct {
+	name    string
+	doc     string
+	columns map[string]cxtj.ColumnInfo
+}
+
+// writeClass writes the class. Nested objects are written as nested classes named by the field.
+func (g *CSharpGenerator) writeClass(w io.Writer, indent string, name string, doc string, columns map[string]cxtj.ColumnInfo, sheetName string) error {
+	writeCSharpDoc(w, indent, []string{doc})
+	fmt.Fprintf(w, "%s[Serializable]\n", indent)
+	fmt.Fprintf(w, "%spublic class %s\n%s{\n", indent, name, indent)
+
+	members := map[string]string{name: "class " + name}
+	nested := []csharpClass{}
+	for _, col := range sortedColumns(columns) {
+		if !csharpIdentifier.MatchString(col.Name) {
+			return fmt.Errorf("sheet %s: column %s cannot be c# field name", sheetName, col.Name)
+		}
+		if other, ok := members[col.Name]; ok {
+			return fmt.Errorf("sheet %s: field %s conflicts with %s", sheetName, col.Name, other)
+		}
+		members[col.Name] = "field " + col.Name
+
+		fieldName := col.Name
+		if csharpKeywords[fieldName] {
+			fieldName = "@" + fieldName
+		}
+		fieldType, class := g.fieldType(col.ColumnInfo, col.Name)
+		if class != nil {
+			if other, ok := members[class.name]; ok {
+				return fmt.Errorf("sheet %s: nested class %s conflicts with %s", sheetName, class.name, other)
+			}
+			members[class.name] = "class " + class.name
+			nested = append(nested, *class)
+		}
+
+		writeCSharpDoc(w, indent+"    ", commentLines(col.Comment))
+		fmt.Fprintf(w, "%s    public %s %s;\n", indent, fieldType, fieldName)
+	}
+
+	for _, class := range nested {
+		fmt.Fprintln(w)
+		if err := g.writeClass(w, indent+"    ", class.name, class.doc, class.columns, sheetName); err != nil {
+			return err
+		}
+	}
+	fmt.Fprintf(w, "%s}\n", indent)
+	return nil
+}
+
+// fieldType returns the c# type of the column and the nested class of an object
+func (g *CSharpGenerator) fieldType(info cxtj.ColumnInfo, columnName string) (string, *csharpClass) {
+	switch {
+	case info.Fields != nil:
+		class := &csharpClass{name: pascalCase(columnName, nil), doc: columnName, columns: info.Fields}
+		return class.name, class
+	case info.Items != nil:
+		t, class := g.fieldType(*info.Items, columnName)
+		return t + "[]", class
+	}
+	return g.opts.typeName(csharpTypes, "%s[]", info.ValueType), nil
+}
+
+func writeCSharpDoc(w io.Writer, indent string, lines []string) {
+	if len(lines) == 0 {
+		return
+	}
+	fmt.Fprintf(w, "%s/// <summary>\n", indent)
+	for _, line := range lines {
+		fmt.Fprintf(w, "%s/// %s\n", indent, csharpComment(line))
+	}
+	fmt.Fprintf(w, "%s/// </summary>\n", indent)
+}
+
+// csharpComment escapes xml characters in a documentation comment
+func csharpComment(s string) string {
+	return strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;").Replace(s)
+}
@@ -0,0 +1,123 @@
+package codegen
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/kama2vern/cxtj/cxtj"
+)
+
+func TestCSharpGenerator(t *testing.T) {
+	header := cxtj.XlsxHeaderMap{
+		"item_master": {
+			"id":    {Index: 0, ValueType: "int", Comment: "ID of item"},
+			"class": {Index: 1, ValueType: "string", Comment: "<rare> & <common>"},
+			"rate":  {Index: 2, ValueType: "double"},
+		},
+		"shop": {
+			"itemId": {Index: 0, ValueType: "int"},
+		},
+	}
+
+	var buf bytes.Buffer
+	if err := NewCSharpGenerator(Options{Package: "Game.Master"}).Generate(&buf, header); err != nil {
+		t.Fatal(err)
+	}
+
+	except := `// Code generated by cxtj codegen; DO NOT EDIT.
+
+using System;
+
+namespace Game.Master
+{
+    /// <summary>
+    /// Row of sheet item_master
+    /// </summary>
+    [Serializable]
+    public class ItemMaster
+    {
+        /// <summary>
+        /// ID of item
+        /// </summary>
+        public int id;
+        /// <summary>
+        /// &lt;rare&gt; &amp; &lt;common&gt;
+        /// </summary>
+        public string @class;
+        public double rate;
+    }
+
+    /// <summary>
+    /// Row of sheet shop
+    /// </summary>
+    [Serializable]
+    public class Shop
+    {
+        public int itemId;
+    }
+}
+`
+	if buf.String() != except {
+		t.Errorf("Mismatch c# source. except:\n%s\nactual:\n%s", except, buf.String())
+	}
+}
+
+func TestCSharpGeneratorInvalidFieldName(t *testing.T) {
+	header := cxtj.XlsxHeaderMap{
+		"sheet": {
+			"item-id": {Index: 0, ValueType: "int"},
+		},
+	}
+	var buf bytes.Buffer
+	if err := NewCSharpGenerator(Options{}).Generate(&buf, header); err == nil {
+		t.Error("column which is not c# identifier should be error")
+	}
+}
+
+func TestCSharpGeneratorNested(t *testing.T) {
+	var buf bytes.Buffer
+	if err := NewCSharpGenerator(Options{}).Generate(&buf, newNestedTestHeader()); err != nil {
+		t.Fatal(err)
+	}
+
+	except := `// Code generated by cxtj codegen; DO NOT EDIT.
+
+using System;
+
+/// <summary>
+/// Row of sheet unit
+/// </summary>
+[Serializable]
+public class Unit
+{
+    public int id;
+    public Stats stats;
+    public Rewards[] rewards;
+    public string[] tags;
+
+    /// <summary>
+    /// stats
+    /// </summary>
+    [Serializable]
+    public class Stats
+    {
+        /// <summary>
+        /// HP
+        /// </summary>
+        public int hp;
+    }
+
+    /// <summary>
+    /// rewards
+    /// </summary>
+    [Serializable]
+    public class Rewards
+    {
+        public int id;
+    }
+}
+`
+	if buf.String() != except {
+		t.Errorf("Mismatch c# source. except:\n%s\nactual:\n%s", except, buf.String())
+	}
+}
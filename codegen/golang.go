@@ -0,0 +1,158 @@
+package codegen
+
+import (
+	"bytes"
+	"fmt"
+	"go/format"
+	"go/token"
+	"io"
+	"strconv"
+	"unicode"
+
+	"github.com/kama2vern/cxtj/cxtj"
+)
+
+// DefaultGoPackage is a package name used when it is not configured
+const DefaultGoPackage = "master"
+
+var goInitialisms = map[string]bool{
+	"API":  true,
+	"HTTP": true,
+	"ID":   true,
+	"JSON": true,
+	"URL":  true,
+	"UUID": true,
+}
+
+// goTypes maps value types into go types.
+// int is 32-bit and long is 64-bit as the converter checks.
+var goTypes = map[string]string{
+	cxtj.ValueTypeInt:    "int32",
+	cxtj.ValueTypeLong:   "int64",
+	cxtj.ValueTypeFloat:  "float32",
+	cxtj.ValueTypeDouble: "float64",
+	cxtj.ValueTypeBool:   "bool",
+	cxtj.ValueTypeString: "string",
+}
+
+// GoGenerator writes a go struct of each sheet with json tags
+type GoGenerator struct {
+	opts Options
+}
+
+// NewGoGenerator creates GoGenerator
+func NewGoGenerator(opts Options) Generator {
+	return &GoGenerator{opts: opts}
+}
+
+// Generate writes go source of structs in alphabetical order of sheet names
+func (g *GoGenerator) Generate(w io.Writer, header cxtj.XlsxHeaderMap) error {
+	pkg := g.opts.Package
+	if pkg == "" {
+		pkg = DefaultGoPackage
+	}
+	if !token.IsIdentifier(pkg) {
+		return fmt.Errorf("invalid go package name: %s", pkg)
+	}
+
+	var buf bytes.Buffer
+	fmt.Fprintln(&buf, "// Code generated by cxtj codegen; DO NOT EDIT.")
+	fmt.Fprintln(&buf)
+	fmt.Fprintf(&buf, "package %s\n", pkg)
+
+	structNames := map[string]string{}
+	for _, sheetName := range sortedSheetNames(header) {
+		name, err := goIdentifier(sheetName)
+		if err != nil {
+			return fmt.Errorf("sheet %s: %s", sheetName, err)
+		}
+
+		// nested objects are written as structs named by the parent struct and the field
+		structs := []goStruct{{name: name, doc: "is a row of sheet " + sheetName, columns: header[sheetName]}}
+		for len(structs) > 0 {
+			st := structs[0]
+			structs = structs[1:]
+			if other, ok := structNames[st.name]; ok {
+				return fmt.Errorf("struct name %s conflicts between sheet %s and %s", st.name, other, sheetName)
+			}
+			structNames[st.name] = sheetName
+
+			nested, err := g.writeStruct(&buf, st, sheetName)
+			if err != nil {
+				return err
+			}
+			structs = append(structs, nested...)
+		}
+	}
+
+	src, err := format.Source(buf.Bytes())
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(src)
+	return err
+}
+
+// goStruct is a struct to be generated from columns
+type goStruct struct {
+	name    string
+	doc     string
+	columns map[string]cxtj.ColumnInfo
+}
+
+// writeStruct writes the struct and returns structs of nested objects
+func (g *GoGenerator) writeStruct(buf *bytes.Buffer, st goStruct, sheetName string) ([]goStruct, error) {
+	fmt.Fprintln(buf)
+	fmt.Fprintf(buf, "// %s %s\n", st.name, st.doc)
+	fmt.Fprintf(buf, "type %s struct {\n", st.name)
+
+	nested := []goStruct{}
+	fieldNames := map[string]string{}
+	for _, col := range sortedColumns(st.columns) {
+		fieldName, err := goIdentifier(col.Name)
+		if err != nil {
+			return nil, fmt.Errorf("sheet %s, column %s: %s", sheetName, col.Name, err)
+		}
+		if other, ok := fieldNames[fieldName]; ok {
+			return nil, fmt.Errorf("sheet %s: field name %s conflicts between column %s and %s", sheetName, fieldName, other, col.Name)
+		}
+		fieldNames[fieldName] = col.Name
+
+		for _, line := range commentLines(col.Comment) {
+			fmt.Fprintf(buf, "// %s\n", line)
+		}
+		fieldType := g.fieldType(col.ColumnInfo, st.name, fieldName, col.Name, &nested)
+		fmt.Fprintf(buf, "%s %s `json:%s`\n", fieldName, fieldType, strconv.Quote(col.Name))
+	}
+	fmt.Fprintln(buf, "}")
+	return nested, nil
+}
+
+// fieldType returns the go type of the column.
+// A nested object is added into nested as a struct named by the parent struct and the field.
+func (g *GoGenerator) fieldType(info cxtj.ColumnInfo, parent string, fieldName string, columnName string, nested *[]goStruct) string {
+	switch {
+	case info.Fields != nil:
+		name := parent + fieldName
+		*nested = append(*nested, goStruct{name: name, doc: "is " + columnName + " of " + parent, columns: info.Fields})
+		return name
+	case info.Items != nil:
+		return "[]" + g.fieldType(*info.Items, parent, fieldName, columnName, nested)
+	}
+	return g.opts.typeName(goTypes, "[]%s", info.ValueType)
+}
+
+// goIdentifier converts a sheet or column name into an exported go identifier
+func goIdentifier(s string) (string, error) {
+	ret := pascalCase(s, goInitialisms)
+	if ret == "" {
+		return "", fmt.Errorf("cannot convert %q into go identifier", s)
+	}
+	if unicode.IsDigit([]rune(ret)[0]) {
+		ret = "X" + ret
+	}
+	if !token.IsExported(ret) {
+		ret = "X" + ret
+	}
+	return ret, nil
+}
@@ -0,0 +1,177 @@
+package codegen
+
+import (
+	"bytes"
+	"os"
+	"path"
+	"strings"
+	"testing"
+
+	"github.com/kama2vern/cxtj/cxtj"
+)
+
+func TestGoGenerator(t *testing.T) {
+	header := cxtj.XlsxHeaderMap{
+		"item_master": {
+			"id":        {Index: 0, ValueType: "int", Comment: "ID of item"},
+			"name":      {Index: 1, ValueType: "string", Comment: "Name\nshown in shop"},
+			"price":     {Index: 2, ValueType: "long"},
+			"rate":      {Index: 3, ValueType: "double"},
+			"isLimited": {Index: 4, ValueType: "bool"},
+			"memo":      {Index: 5, ValueType: "unknown"},
+			"":          {Index: 6},
+		},
+	}
+
+	var buf bytes.Buffer
+	if err := NewGoGenerator(Options{Package: "model"}).Generate(&buf, header); err != nil {
+		t.Fatal(err)
+	}
+
+	except := `// Code generated by cxtj codegen; DO NOT EDIT.
+
+package model
+
+// ItemMaster is a row of sheet item_master
+type ItemMaster struct {
+	// ID of item
+	ID int32 ` + "`json:\"id\"`" + `
+	// Name
+	// shown in shop
+	Name      string  ` + "`json:\"name\"`" + `
+	Price     int64   ` + "`json:\"price\"`" + `
+	Rate      float64 ` + "`json:\"rate\"`" + `
+	IsLimited bool    ` + "`json:\"isLimited\"`" + `
+	Memo      string  ` + "`json:\"memo\"`" + `
+}
+`
+	if buf.String() != except {
+		t.Errorf("Mismatch go source. except:\n%s\nactual:\n%s", except, buf.String())
+	}
+}
+
+func TestGoGeneratorConflict(t *testing.T) {
+	header := cxtj.XlsxHeaderMap{
+		"sheet": {
+			"item_id": {Index: 0, ValueType: "int"},
+			"item-id": {Index: 1, ValueType: "int"},
+		},
+	}
+	var buf bytes.Buffer
+	if err := NewGoGenerator(Options{}).Generate(&buf, header); err == nil {
+		t.Error("conflicting field names should be error")
+	}
+
+	if err := NewGoGenerator(Options{Package: "my-model"}).Generate(&buf, cxtj.XlsxHeaderMap{}); err == nil {
+		t.Error("invalid package name should be error")
+	}
+}
+
+func TestGoGeneratorFromXlsx(t *testing.T) {
+	dir, _ := os.Getwd()
+	c, err := cxtj.NewConverter(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	header, err := c.ReadHeader([]string{
+		path.Join(dir, "..", "test", "excels", "convert_test.xlsx"),
+		path.Join(dir, "..", "test", "excels", "convert_test2.xlsx"),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+	if err := NewGoGenerator(Options{}).Generate(&buf, header); err != nil {
+		t.Fatal(err)
+	}
+	src := buf.String()
+	for _, s := range []string{"package master", "type NextSheet struct", "type Sheet struct", "CharacterId int32 `json:\"characterId\"`", "// キャラID"} {
+		if !strings.Contains(src, s) {
+			t.Errorf("generated source should contain %q:\n%s", s, src)
+		}
+	}
+}
+
+func TestGetGenerator(t *testing.T) {
+	if _, err := GetGenerator(LangGo, Options{}); err != nil {
+		t.Error(err)
+	}
+	if _, err := GetGenerator("cobol", Options{}); err == nil {
+		t.Error("unknown language should be error")
+	}
+}
+
+func newNestedTestHeader() cxtj.XlsxHeaderMap {
+	return cxtj.XlsxHeaderMap{
+		"unit": {
+			"id": {Index: 0, ValueType: "int"},
+			"stats": {Index: 1, ValueType: "object", Fields: cxtj.SheetColumns{
+				"hp": {Index: 1, ValueType: "int", Comment: "HP"},
+			}},
+			"rewards": {Index: 2, ValueType: "array", Length: 2, Items: &cxtj.ColumnInfo{Index: 2, ValueType: "object", Fields: cxtj.SheetColumns{
+				"id": {Index: 2, ValueType: "int"},
+			}}},
+			"tags": {Index: 4, ValueType: "array", Length: 2, Items: &cxtj.ColumnInfo{Index: 4, ValueType: "string"}},
+		},
+	}
+}
+
+func TestGoGeneratorNested(t *testing.T) {
+	var buf bytes.Buffer
+	if err := NewGoGenerator(Options{}).Generate(&buf, newNestedTestHeader()); err != nil {
+		t.Fatal(err)
+	}
+
+	except := `// Code generated by cxtj codegen; DO NOT EDIT.
+
+package master
+
+// Unit is a row of sheet unit
+type Unit struct {
+	ID      int32         ` + "`json:\"id\"`" + `
+	Stats   UnitStats     ` + "`json:\"stats\"`" + `
+	Rewards []UnitRewards ` + "`json:\"rewards\"`" + `
+	Tags    []string      ` + "`json:\"tags\"`" + `
+}
+
+// UnitStats is stats of Unit
+type UnitStats struct {
+	// HP
+	Hp int32 ` + "`json:\"hp\"`" + `
+}
+
+// UnitRewards is rewards of Unit
+type UnitRewards struct {
+	ID int32 ` + "`json:\"id\"`" + `
+}
+`
+	if buf.String() != except {
+		t.Errorf("Mismatch go source. except:\n%s\nactual:\n%s", except, buf.String())
+	}
+}
+
+func TestTypeNameOfArrayAndReferenceValueType(t *testing.T) {
+	opts := Options{Types: map[string]string{"long": "Long", "string[]": "Strings"}}
+	cases := []struct {
+		defaults    map[string]string
+		arrayFormat string
+		valueType   string
+		expect      string
+	}{
+		{goTypes, "[]%s", "int[]", "[]int32"},
+		{goTypes, "[]%s", "float[|]", "[]float32"},
+		{goTypes, "[]%s", "long[]", "[]Long"},
+		{typeScriptTypes, "%s[]", "bool[]", "boolean[]"},
+		{csharpTypes, "%s[]", "double[]", "double[]"},
+		{csharpTypes, "%s[]", "string[]", "Strings"},
+		{goTypes, "[]%s", "ref:characters.id", "Long"},
+		{typeScriptTypes, "%s[]", "ref:characters.name:string", "string"},
+	}
+
+	for _, c := range cases {
+		if actual := opts.typeName(c.defaults, c.arrayFormat, c.valueType); actual != c.expect {
+			t.Errorf("invalid type name of %s. expect: %s, actual: %s", c.valueType, c.expect, actual)
+		}
+	}
+}
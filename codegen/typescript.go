@@ -0,0 +1,107 @@
+package codegen
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/kama2vern/cxtj/cxtj"
+)
+
+// typeScriptTypes maps value types into typescript types
+var typeScriptTypes = map[string]string{
+	cxtj.ValueTypeInt:    "number",
+	cxtj.ValueTypeLong:   "number",
+	cxtj.ValueTypeFloat:  "number",
+	cxtj.ValueTypeDouble: "number",
+	cxtj.ValueTypeBool:   "boolean",
+	cxtj.ValueTypeString: "string",
+}
+
+var typeScriptIdentifier = regexp.MustCompile(`^[A-Za-z_$][A-Za-z0-9_$]*$`)
+
+// TypeScriptGenerator writes an exported interface of each sheet.
+// Property names are the keys of json as they are.
+type TypeScriptGenerator struct {
+	opts Options
+}
+
+// NewTypeScriptGenerator creates TypeScriptGenerator
+func NewTypeScriptGenerator(opts Options) Generator {
+	return &TypeScriptGenerator{opts: opts}
+}
+
+// Generate writes typescript interfaces in alphabetical order of sheet names
+func (g *TypeScriptGenerator) Generate(w io.Writer, header cxtj.XlsxHeaderMap) error {
+	bw := bufio.NewWriter(w)
+	fmt.Fprintln(bw, "// Code generated by cxtj codegen; DO NOT EDIT.")
+
+	interfaceNames := map[string]string{}
+	for _, sheetName := range sortedSheetNames(header) {
+		name := pascalCase(sheetName, nil)
+		if !typeScriptIdentifier.MatchString(name) {
+			return fmt.Errorf("sheet %s: cannot convert %q into typescript identifier", sheetName, sheetName)
+		}
+		if other, ok := interfaceNames[name]; ok {
+			return fmt.Errorf("interface name %s conflicts between sheet %s and %s", name, other, sheetName)
+		}
+		interfaceNames[name] = sheetName
+
+		fmt.Fprintln(bw)
+		fmt.Fprintf(bw, "/** Row of sheet %s */\n", typeScriptComment(sheetName))
+		fmt.Fprintf(bw, "export interface %s %s\n", name, g.objectType(header[sheetName], ""))
+	}
+	return bw.Flush()
+}
+
+// objectType returns an object type literal of columns. Nested objects are written inline.
+func (g *TypeScriptGenerator) objectType(columns map[string]cxtj.ColumnInfo, indent string) string {
+	var b strings.Builder
+	b.WriteString("{\n")
+	for _, col := range sortedColumns(columns) {
+		writeTypeScriptDoc(&b, indent+"  ", commentLines(col.Comment))
+		fmt.Fprintf(&b, "%s  %s: %s;\n", indent, typeScriptPropertyName(col.Name), g.fieldType(col.ColumnInfo, indent+"  "))
+	}
+	b.WriteString(indent + "}")
+	return b.String()
+}
+
+func (g *TypeScriptGenerator) fieldType(info cxtj.ColumnInfo, indent string) string {
+	switch {
+	case info.Fields != nil:
+		return g.objectType(info.Fields, indent)
+	case info.Items != nil:
+		return g.fieldType(*info.Items, indent) + "[]"
+	}
+	return g.opts.typeName(typeScriptTypes, "%s[]", info.ValueType)
+}
+
+func writeTypeScriptDoc(w io.Writer, indent string, lines []string) {
+	switch len(lines) {
+	case 0:
+	case 1:
+		fmt.Fprintf(w, "%s/** %s */\n", indent, typeScriptComment(lines[0]))
+	default:
+		fmt.Fprintf(w, "%s/**\n", indent)
+		for _, line := range lines {
+			fmt.Fprintf(w, "%s * %s\n", indent, typeScriptComment(line))
+		}
+		fmt.Fprintf(w, "%s */\n", indent)
+	}
+}
+
+// typeScriptPropertyName quotes the name if it is not an identifier
+func typeScriptPropertyName(name string) string {
+	if typeScriptIdentifier.MatchString(name) {
+		return name
+	}
+	return strconv.Quote(name)
+}
+
+// typeScriptComment escapes the end of a comment
+func typeScriptComment(s string) string {
+	return strings.Replace(s, "*/", "*\\/", -1)
+}
@@ -0,0 +1,69 @@
+package codegen
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/kama2vern/cxtj/cxtj"
+)
+
+func TestTypeScriptGenerator(t *testing.T) {
+	header := cxtj.XlsxHeaderMap{
+		"item_master": {
+			"id":         {Index: 0, ValueType: "int", Comment: "ID of item"},
+			"name":       {Index: 1, ValueType: "string", Comment: "Name\nshown in shop"},
+			"price":      {Index: 2, ValueType: "long"},
+			"is-limited": {Index: 3, ValueType: "bool"},
+		},
+	}
+
+	var buf bytes.Buffer
+	if err := NewTypeScriptGenerator(Options{Types: map[string]string{"long": "bigint"}}).Generate(&buf, header); err != nil {
+		t.Fatal(err)
+	}
+
+	except := `// Code generated by cxtj codegen; DO NOT EDIT.
+
+/** Row of sheet item_master */
+export interface ItemMaster {
+  /** ID of item */
+  id: number;
+  /**
+   * Name
+   * shown in shop
+   */
+  name: string;
+  price: bigint;
+  "is-limited": boolean;
+}
+`
+	if buf.String() != except {
+		t.Errorf("Mismatch typescript source. except:\n%s\nactual:\n%s", except, buf.String())
+	}
+}
+
+func TestTypeScriptGeneratorNested(t *testing.T) {
+	var buf bytes.Buffer
+	if err := NewTypeScriptGenerator(Options{}).Generate(&buf, newNestedTestHeader()); err != nil {
+		t.Fatal(err)
+	}
+
+	except := `// Code generated by cxtj codegen; DO NOT EDIT.
+
+/** Row of sheet unit */
+export interface Unit {
+  id: number;
+  stats: {
+    /** HP */
+    hp: number;
+  };
+  rewards: {
+    id: number;
+  }[];
+  tags: string[];
+}
+`
+	if buf.String() != except {
+		t.Errorf("Mismatch typescript source. except:\n%s\nactual:\n%s", except, buf.String())
+	}
+}
@@ -1,33 +1,100 @@
 package main
 
 import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"sort"
+	"strings"
+	"syscall"
+
+	"github.com/kama2vern/cxtj/codegen"
 	"github.com/kama2vern/cxtj/config"
+	"github.com/kama2vern/cxtj/cxtj"
 	"github.com/kama2vern/cxtj/logger"
+	"github.com/kama2vern/cxtj/schema"
 	"github.com/urfave/cli"
 )
 
 // Commands cli.Command object list
 var Commands = []cli.Command{
 	commandConvert,
+	commandCodegen,
+	commandSchema,
+	commandValidate,
+	commandCheck,
+	commandExport,
+	commandDiff,
 }
 
 var commandConvert = cli.Command{
 	Name:      "convert",
 	Usage:     "Convert xlsx to json file(s)",
-	ArgsUsage: "[--verbose | -v] [--only-header] [--multiple-output] --from <xlsxFileName|xlsxDir> --to <jsonFileName|jsonDir>",
+	ArgsUsage: "[--verbose | -v] [--only-header] [--watch] [--concurrent [--jobs <n>] [--keep-going]] [--sheet-jobs <n>] [--stream] [--multiple-output [--output-name <template>]] [--duplicate-sheet <error|namespace|append>] [--format <json|yaml|toml|csv>] [--row-shape <array|keyed>] [--key-order <alphabetical|column>] [--cache-dir <dir>] --from <xlsxFileName|xlsxDir> --to <jsonFileName|jsonDir>",
 	Description: `
     Convert single or multiple xlsx file to single or multiple json file.
     You can designate multiple xlsx file names/dirs and also json files.
+    In --multiple-output mode, each sheet is written into <jsonDir>/<template>.
+    {book}, {sheet} and {ext} in the template are replaced with xlsx file name, sheet name and extension of the format.
+    The output format is json, yaml, toml or csv. It is detected from the extension of --to when --format is not given.
+    The output is the same bytes for the same input regardless of --concurrent. Sheets are sorted by name,
+    and keys of each row are in order of columns of the spreadsheet, or sorted by name with --key-order alphabetical.
+    Column names like stats.hp and rewards[0].id in the key row make nested objects and arrays.
+    Value types like int[] and string[|] split a cell into an array by array_delimiter of the config or the delimiter in brackets.
+    A value type like ref:characters.id declares a reference to the column of another sheet, which is checked by check command.
+    A value type with "!" suffix like int! or [primary_key] in config declares the primary key, which should be unique and not empty.
+    With --row-shape keyed, rows of sheets which have the primary key are written as an object keyed by it.
+    With --watch, xlsx files are converted again when they are changed until interrupted. Excel lock files (~$*.xlsx) are ignored.
+    With --concurrent, xlsx files are converted by --jobs workers, and the remaining files are canceled on the first failure.
+    With --keep-going, all files are converted and all failures are reported. Nothing is written when some of them fail.
+    Sheets of each xlsx file are converted by --sheet-jobs workers in each of the file workers, so that --jobs times --sheet-jobs
+    goroutines can run at once. Without --sheet-jobs and sheet_jobs of the config, sheets are converted one by one with --concurrent.
+    With --cache-dir or cache_dir in config, converted xlsx files are cached by their content and config, and unchanged files are not parsed again.
+    With --stream, rows are read from the xml of xlsx files and written as soon as they are converted, so that memory usage
+    stays flat for large sheets. It supports only json format, and the output is the same as without --stream.
 `,
 	Action: doConvert,
 	Flags: []cli.Flag{
 		cli.BoolFlag{Name: "verbose, v", Usage: "Verbose output mode"},
 		cli.BoolFlag{Name: "only-header", Usage: "Only header output mode"},
 		cli.BoolFlag{Name: "concurrent", Usage: "Conversion in concurrency"},
+		cli.IntFlag{Name: "jobs, j", Usage: "Number of workers of --concurrent (default: number of cpu)"},
+		cli.BoolFlag{Name: "keep-going", Usage: "Convert all files with --concurrent even if some of them fail, and report all failures"},
+		cli.IntFlag{Name: "sheet-jobs", Usage: "Number of workers to convert sheets of each xlsx file (default: sheet_jobs in config, or 1 with --concurrent and number of cpu without it)"},
+		cli.BoolFlag{Name: "watch", Usage: "Watch xlsx files and convert changed ones again"},
+		cli.BoolFlag{Name: "stream", Usage: "Write rows as soon as they are read from xlsx files to keep memory usage flat. json format only"},
 		cli.BoolFlag{
 			Name:  "multiple-output",
 			Usage: "Output multiple json files each xlsx sheets",
 		},
+		cli.StringFlag{
+			Name:  "output-name",
+			Usage: "File name template of each sheet in --multiple-output mode. e.g. {sheet}.{ext}, {book}/{sheet}.json (default: output.file_name in config)",
+		},
+		cli.StringFlag{
+			Name:  "duplicate-sheet",
+			Usage: "How to treat the same sheet name in multiple xlsx files. error, namespace (book.sheet) or append (default: duplicate_sheet in config)",
+		},
+		cli.StringFlag{
+			Name:  "format",
+			Usage: "Output format. json, yaml, toml or csv (default: output.format in config or extension of --to)",
+		},
+		cli.StringFlag{
+			Name:  "key-order",
+			Usage: "Order of keys in each row. column of the spreadsheet or alphabetical (default: output.key_order in config, or column)",
+		},
+		cli.StringFlag{
+			Name:  "row-shape",
+			Usage: "Shape of rows of each sheet. array or keyed by the primary key (default: output.row_shape in config)",
+		},
+		cli.StringFlag{
+			Name:  "cache-dir",
+			Usage: "Directory to cache converted xlsx files (default: cache_dir in config)",
+		},
 		cli.StringSliceFlag{
 			Name:  "from",
 			Value: &cli.StringSlice{},
@@ -50,22 +117,522 @@ func doConvert(c *cli.Context) error {
 	isOnlyHeader := c.Bool("only-header")
 	isMultipleOutput := c.Bool("multiple-output")
 	isConcurrent := c.Bool("concurrent")
+	isWatch := c.Bool("watch")
+	isStream := c.Bool("stream")
+	workerOpts := cxtj.WorkerOptions{Jobs: c.Int("jobs"), KeepGoing: c.Bool("keep-going")}
 
 	if len(from) < 1 || to == "" {
 		cli.ShowCommandHelpAndExit(c, "convert", 1)
 	}
-	if isOnlyHeader && isConcurrent {
-		return cli.NewExitError("Concurrency conversion into header does not support", 1)
+	if isWatch && (isOnlyHeader || isConcurrent) {
+		return cli.NewExitError("Watch mode does not support --only-header and --concurrent", 1)
+	}
+	if isStream && (isWatch || isOnlyHeader || isConcurrent) {
+		return cli.NewExitError("--stream does not support --watch, --only-header and --concurrent", 1)
+	}
+	if !isConcurrent && (c.IsSet("jobs") || workerOpts.KeepGoing) {
+		return cli.NewExitError("--jobs and --keep-going require --concurrent", 1)
 	}
+	if workerOpts.Jobs < 0 {
+		return cli.NewExitError("--jobs should be positive", 1)
+	}
+	overridden := *conf
+	if outputName := c.String("output-name"); outputName != "" {
+		if err := config.VerifyOutputFileName(outputName); err != nil {
+			return cli.NewExitError(err.Error(), 1)
+		}
+		overridden.Output.FileName = outputName
+	}
+	if format := c.String("format"); format != "" {
+		overridden.Output.Format = format
+	}
+	if keyOrder := c.String("key-order"); keyOrder != "" {
+		if err := overridden.Output.KeyOrder.UnmarshalText([]byte(keyOrder)); err != nil {
+			return cli.NewExitError(err.Error(), 1)
+		}
+	}
+	if rowShape := c.String("row-shape"); rowShape != "" {
+		if err := overridden.Output.RowShape.UnmarshalText([]byte(rowShape)); err != nil {
+			return cli.NewExitError(err.Error(), 1)
+		}
+	}
+	if duplicateSheet := c.String("duplicate-sheet"); duplicateSheet != "" {
+		if err := overridden.DuplicateSheet.UnmarshalText([]byte(duplicateSheet)); err != nil {
+			return cli.NewExitError(err.Error(), 1)
+		}
+	}
+	if cacheDir := c.String("cache-dir"); cacheDir != "" {
+		overridden.CacheDir = cacheDir
+	}
+	if c.IsSet("sheet-jobs") {
+		if c.Int("sheet-jobs") < 1 {
+			return cli.NewExitError("--sheet-jobs should be positive", 1)
+		}
+		overridden.SheetJobs = c.Int("sheet-jobs")
+	}
+	conf = &overridden
+
+	converter, err := cxtj.NewConverter(conf)
+	logger.DieIf(err)
 
-	converter := NewConverter(conf)
-	if isConcurrent {
-		converter.ConvertConcurrency(from, to, isMultipleOutput)
+	if isWatch {
+		err = converter.Watch(from, to, isMultipleOutput, interrupted())
+	} else if isConcurrent {
+		if isOnlyHeader {
+			err = converter.ConvertIntoHeaderConcurrencyContext(interruptedContext(), from, to, isMultipleOutput, workerOpts)
+		} else {
+			err = converter.ConvertConcurrencyContext(interruptedContext(), from, to, isMultipleOutput, workerOpts)
+		}
+		if errs, ok := err.(cxtj.WorkerErrors); ok {
+			for _, e := range errs {
+				logger.Log("error", e.Error())
+			}
+			return cli.NewExitError(fmt.Sprintf("%d xlsx file(s) failed", len(errs)), 1)
+		}
+	} else if isStream {
+		err = converter.ConvertStream(from, to, isMultipleOutput)
 	} else if isOnlyHeader {
-		converter.ConvertIntoHeader(from, to, isMultipleOutput)
+		err = converter.ConvertIntoHeader(from, to, isMultipleOutput)
+	} else {
+		err = converter.Convert(from, to, isMultipleOutput)
+	}
+	logger.DieIf(err)
+
+	return nil
+}
+
+// interrupted returns a channel which is closed by SIGINT or SIGTERM
+func interrupted() <-chan struct{} {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+
+	stop := make(chan struct{})
+	go func() {
+		<-signals
+		close(stop)
+	}()
+	return stop
+}
+
+// interruptedContext returns a context which is canceled by SIGINT or SIGTERM
+func interruptedContext() context.Context {
+	ctx, cancel := context.WithCancel(context.Background())
+	stop := interrupted()
+	go func() {
+		<-stop
+		cancel()
+	}()
+	return ctx
+}
+
+var commandCodegen = cli.Command{
+	Name:      "codegen",
+	Usage:     "Generate type definitions from header of xlsx",
+	ArgsUsage: "--lang <go|typescript|csharp> [--package <name>] --from <xlsxFileName|xlsxDir> --to <sourceFileName>",
+	Description: `
+    Generate a type of each sheet from the key, value-type and comment rows.
+    Field names come from the key row, field types from the value-type row and field comments from the comment row.
+    Sheets of multiple xlsx files are merged by the duplicate sheet policy in config.
+    Field types can be changed by [codegen.types.<lang>] in config. e.g. long = "bigint" in [codegen.types.typescript]
+`,
+	Action: doCodegen,
+	Flags: []cli.Flag{
+		cli.StringFlag{
+			Name:  "lang",
+			Usage: "Language of generated source. go, typescript or csharp",
+		},
+		cli.StringFlag{
+			Name:  "package",
+			Usage: "Package name of go (default: " + codegen.DefaultGoPackage + ") or namespace of c#",
+		},
+		cli.StringSliceFlag{
+			Name:  "from",
+			Value: &cli.StringSlice{},
+			Usage: "Input xlsx files or directory which includes some xlsx files. Multiple choices are allowed.",
+		},
+		cli.StringFlag{
+			Name:  "to",
+			Usage: "Output source file",
+		},
+	},
+}
+
+func doCodegen(c *cli.Context) error {
+	conffile := c.GlobalString("conf")
+	conf, err := config.LoadConfigFile(conffile)
+	logger.DieIf(err)
+
+	lang := c.String("lang")
+	from := c.StringSlice("from")
+	to := c.String("to")
+
+	if lang == "" || len(from) < 1 || to == "" {
+		cli.ShowCommandHelpAndExit(c, "codegen", 1)
+	}
+
+	generator, err := codegen.GetGenerator(lang, codegen.Options{
+		Package: c.String("package"),
+		Types:   conf.Codegen.Types[lang],
+	})
+	if err != nil {
+		return cli.NewExitError(err.Error(), 1)
+	}
+
+	converter, err := cxtj.NewConverter(conf)
+	logger.DieIf(err)
+
+	header, err := converter.ReadHeader(from)
+	logger.DieIf(err)
+
+	logger.DieIf(os.MkdirAll(filepath.Dir(to), 0755))
+	f, err := os.Create(to)
+	logger.DieIf(err)
+
+	if err := generator.Generate(f, header); err != nil {
+		f.Close()
+		os.Remove(to)
+		logger.DieIf(err)
+	}
+	logger.DieIf(f.Close())
+	logger.Log("created", to)
+
+	return nil
+}
+
+var commandSchema = cli.Command{
+	Name:      "schema",
+	Usage:     "Generate JSON Schema of each sheet from header of xlsx",
+	ArgsUsage: "--from <xlsxFileName|xlsxDir> --to <schemaDir>",
+	Description: `
+    Generate <schemaDir>/<sheet>.schema.json from the key, value-type and comment rows of each sheet.
+    The schema describes an array of rows, and nullable columns follow output.empty_cell in config.
+    Rows of sheets which have the primary key are an object keyed by it when output.row_shape is keyed.
+`,
+	Action: doSchema,
+	Flags: []cli.Flag{
+		cli.StringSliceFlag{
+			Name:  "from",
+			Value: &cli.StringSlice{},
+			Usage: "Input xlsx files or directory which includes some xlsx files. Multiple choices are allowed.",
+		},
+		cli.StringFlag{
+			Name:  "to",
+			Usage: "Output directory of schema files",
+		},
+	},
+}
+
+var commandValidate = cli.Command{
+	Name:      "validate",
+	Usage:     "Validate converted json by JSON Schema of xlsx",
+	ArgsUsage: "--from <xlsxFileName|xlsxDir> --json <jsonFileName|jsonDir>",
+	Description: `
+    Validate json converted before against JSON Schema generated from current header of xlsx.
+    A json file should have all sheets in the shape of output.shape and rows in output.row_shape in config.
+    A json directory should have a file of each sheet named by output.file_name in config, which does not contain {book}.
+`,
+	Action: doValidate,
+	Flags: []cli.Flag{
+		cli.StringSliceFlag{
+			Name:  "from",
+			Value: &cli.StringSlice{},
+			Usage: "Input xlsx files or directory which includes some xlsx files. Multiple choices are allowed.",
+		},
+		cli.StringFlag{
+			Name:  "json",
+			Usage: "Json file or directory to be validated",
+		},
+	},
+}
+
+// loadSchemas reads header of xlsx files and generates JSON Schema of each sheet
+func loadSchemas(conf *config.Config, from []string) map[string]*schema.Schema {
+	converter, err := cxtj.NewConverter(conf)
+	logger.DieIf(err)
+
+	header, err := converter.ReadHeader(from)
+	logger.DieIf(err)
+
+	return schema.FromHeaderByOutput(header, conf.Output)
+}
+
+func sortedSchemaNames(schemas map[string]*schema.Schema) []string {
+	ret := make([]string, 0, len(schemas))
+	for sheetName := range schemas {
+		ret = append(ret, sheetName)
+	}
+	sort.Strings(ret)
+	return ret
+}
+
+func doSchema(c *cli.Context) error {
+	conffile := c.GlobalString("conf")
+	conf, err := config.LoadConfigFile(conffile)
+	logger.DieIf(err)
+
+	from := c.StringSlice("from")
+	to := c.String("to")
+
+	if len(from) < 1 || to == "" {
+		cli.ShowCommandHelpAndExit(c, "schema", 1)
+	}
+
+	schemas := loadSchemas(conf, from)
+	logger.DieIf(os.MkdirAll(to, 0755))
+	for _, sheetName := range sortedSchemaNames(schemas) {
+		bs, err := json.MarshalIndent(schemas[sheetName], "", "  ")
+		logger.DieIf(err)
+
+		outputFile := filepath.Join(to, sheetName+".schema.json")
+		logger.DieIf(ioutil.WriteFile(outputFile, append(bs, '\n'), 0644))
+		logger.Log("created", outputFile)
+	}
+
+	return nil
+}
+
+func doValidate(c *cli.Context) error {
+	conffile := c.GlobalString("conf")
+	conf, err := config.LoadConfigFile(conffile)
+	logger.DieIf(err)
+
+	from := c.StringSlice("from")
+	jsonPath := c.String("json")
+
+	if len(from) < 1 || jsonPath == "" {
+		cli.ShowCommandHelpAndExit(c, "validate", 1)
+	}
+
+	schemas := loadSchemas(conf, from)
+
+	fi, err := os.Stat(jsonPath)
+	logger.DieIf(err)
+
+	invalid := 0
+	if fi.IsDir() {
+		if strings.Contains(conf.Output.FileName, "{book}") {
+			return cli.NewExitError(fmt.Sprintf("json directory cannot be validated with output.file_name %s", conf.Output.FileName), 1)
+		}
+		for _, sheetName := range sortedSchemaNames(schemas) {
+			s := schemas[sheetName]
+			fileName := strings.NewReplacer("{sheet}", sheetName, "{ext}", "json").Replace(conf.Output.FileName)
+			jsonFile := filepath.Join(jsonPath, fileName)
+			invalid += validateJSONFile(jsonFile, func(v interface{}) []schema.ValidationError {
+				return s.Validate(v)
+			})
+		}
 	} else {
-		converter.Convert(from, to, isMultipleOutput)
+		invalid += validateJSONFile(jsonPath, func(v interface{}) []schema.ValidationError {
+			return schema.ValidateOutput(schemas, v, conf.Output.Shape)
+		})
+	}
+
+	if invalid > 0 {
+		return cli.NewExitError(fmt.Sprintf("%d invalid value(s) are found", invalid), 1)
+	}
+	return nil
+}
+
+// validateJSONFile logs errors of the json file and returns the number of them
+func validateJSONFile(jsonFile string, validate func(v interface{}) []schema.ValidationError) int {
+	f, err := os.Open(jsonFile)
+	if err != nil {
+		logger.Log("invalid", err.Error())
+		return 1
+	}
+	defer f.Close()
+
+	v, err := schema.Decode(f)
+	if err != nil {
+		logger.Log("invalid", fmt.Sprintf("%s: %s", jsonFile, err))
+		return 1
+	}
+
+	errs := validate(v)
+	for _, e := range errs {
+		logger.Log("invalid", fmt.Sprintf("%s: %s", jsonFile, e))
+	}
+	if len(errs) == 0 {
+		logger.Log("valid", jsonFile)
+	}
+	return len(errs)
+}
+
+var commandCheck = cli.Command{
+	Name:      "check",
+	Usage:     "Check references between sheets of xlsx",
+	ArgsUsage: "--from <xlsxFileName|xlsxDir>",
+	Description: `
+    Check every value of columns declared like ref:characters.id in the value-type row is found in the referenced column.
+    Cells of a reference are long values, or the value type after the column like ref:characters.name:string.
+    Sheets of the same name in multiple xlsx files are referenced as one sheet.
+`,
+	Action: doCheck,
+	Flags: []cli.Flag{
+		cli.StringSliceFlag{
+			Name:  "from",
+			Value: &cli.StringSlice{},
+			Usage: "Input xlsx files or directory which includes some xlsx files. Multiple choices are allowed.",
+		},
+	},
+}
+
+func doCheck(c *cli.Context) error {
+	conffile := c.GlobalString("conf")
+	conf, err := config.LoadConfigFile(conffile)
+	logger.DieIf(err)
+
+	from := c.StringSlice("from")
+
+	if len(from) < 1 {
+		cli.ShowCommandHelpAndExit(c, "check", 1)
+	}
+
+	converter, err := cxtj.NewConverter(conf)
+	logger.DieIf(err)
+
+	errs, err := converter.Check(from)
+	logger.DieIf(err)
+
+	for _, e := range errs {
+		logger.Log("dangling", e.Error())
+	}
+	if len(errs) > 0 {
+		return cli.NewExitError(fmt.Sprintf("%d dangling reference(s) are found", len(errs)), 1)
+	}
+	logger.Log("checked", strings.Join(from, ", "))
+	return nil
+}
+
+var commandExport = cli.Command{
+	Name:      "export",
+	Usage:     "Export json back into xlsx file",
+	ArgsUsage: "[--header <jsonFileName>] --from <jsonFileName> --to <xlsxFileName>",
+	Description: `
+    Export json converted by convert command into a xlsx file, which is the inverse of convert.
+    Each sheet is written with the key, value-type and comment rows laid out by the excel formats of the config.
+    With --header, columns are laid out by json written by convert --only-header.
+    Otherwise columns are inferred from rows and sorted by name.
+`,
+	Action: doExport,
+	Flags: []cli.Flag{
+		cli.StringFlag{
+			Name:  "from",
+			Usage: "Input json file of sheets",
+		},
+		cli.StringFlag{
+			Name:  "header",
+			Usage: "Input json file of header of sheets",
+		},
+		cli.StringFlag{
+			Name:  "to",
+			Usage: "Output xlsx file",
+		},
+	},
+}
+
+func doExport(c *cli.Context) error {
+	conffile := c.GlobalString("conf")
+	conf, err := config.LoadConfigFile(conffile)
+	logger.DieIf(err)
+
+	from := c.String("from")
+	to := c.String("to")
+
+	if from == "" || to == "" {
+		cli.ShowCommandHelpAndExit(c, "export", 1)
+	}
+
+	converter, err := cxtj.NewConverter(conf)
+	logger.DieIf(err)
+
+	if err := converter.Export(from, c.String("header"), to); err != nil {
+		return cli.NewExitError(err.Error(), 1)
 	}
+	return nil
+}
 
+var commandDiff = cli.Command{
+	Name:      "diff",
+	Usage:     "Show changes of rows between two xlsx files or git revisions",
+	ArgsUsage: "[--json] <oldXlsxFileName> <newXlsxFileName> | [--json] --rev <revision> [--rev <revision>] <xlsxFileName> | --textconv <xlsxFileName>",
+	Description: `
+    Convert two xlsx files, or an xlsx file of two git revisions, and show added, removed and modified rows of each sheet.
+    Rows are matched by the primary key, or by the index of data rows when the sheet has no primary key.
+    With one --rev, the revision is compared with the working tree file.
+    It can be used as a git external diff driver, which is given 7 arguments by git:
+        git config diff.xlsx.command "cxtj diff"
+    or as a textconv driver, which writes each row as a line of json:
+        git config diff.xlsx.textconv "cxtj diff --textconv"
+    with "*.xlsx diff=xlsx" in .gitattributes.
+`,
+	Action: doDiff,
+	Flags: []cli.Flag{
+		cli.BoolFlag{Name: "json", Usage: "Output changes as json"},
+		cli.BoolFlag{Name: "textconv", Usage: "Output rows of one xlsx file as lines for diff.textconv of git"},
+		cli.StringSliceFlag{
+			Name:  "rev",
+			Value: &cli.StringSlice{},
+			Usage: "Git revisions to compare. The working tree file is compared when only one is given.",
+		},
+	},
+}
+
+func doDiff(c *cli.Context) error {
+	conffile := c.GlobalString("conf")
+	conf, err := config.LoadConfigFile(conffile)
+	logger.DieIf(err)
+
+	converter, err := cxtj.NewConverter(conf)
+	logger.DieIf(err)
+
+	args := c.Args()
+	revs := c.StringSlice("rev")
+
+	if c.Bool("textconv") {
+		if len(args) != 1 {
+			cli.ShowCommandHelpAndExit(c, "diff", 1)
+		}
+		if err := converter.TextConv(os.Stdout, args[0]); err != nil {
+			return cli.NewExitError(err.Error(), 1)
+		}
+		return nil
+	}
+
+	var diffs []cxtj.SheetDiff
+	var oldLabel, newLabel string
+	switch {
+	case len(revs) > 0 && len(revs) <= 2 && len(args) == 1:
+		newRev := ""
+		if len(revs) == 2 {
+			newRev = revs[1]
+		}
+		oldLabel, newLabel = revs[0]+":"+args[0], args[0]
+		if newRev != "" {
+			newLabel = newRev + ":" + args[0]
+		}
+		diffs, err = converter.DiffRevisions(args[0], revs[0], newRev)
+	case len(revs) == 0 && len(args) == 2:
+		oldLabel, newLabel = args[0], args[1]
+		diffs, err = converter.DiffFiles(args[0], args[1])
+	case len(revs) == 0 && len(args) == 7:
+		// git external diff: path old-file old-hex old-mode new-file new-hex new-mode
+		oldLabel, newLabel = "a/"+args[0], "b/"+args[0]
+		diffs, err = converter.DiffFiles(args[1], args[4])
+	default:
+		cli.ShowCommandHelpAndExit(c, "diff", 1)
+	}
+	if err != nil {
+		return cli.NewExitError(err.Error(), 1)
+	}
+
+	if c.Bool("json") {
+		err = cxtj.WriteDiffJSON(os.Stdout, diffs)
+	} else {
+		err = cxtj.WriteDiff(os.Stdout, oldLabel, newLabel, diffs)
+	}
+	if err != nil {
+		return cli.NewExitError(err.Error(), 1)
+	}
 	return nil
 }
@@ -0,0 +1,53 @@
+package config
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// CodegenConfig represents configuration of type definitions generated from header
+type CodegenConfig struct {
+	// Types maps value types into type names of each language.
+	// e.g. [codegen.types.typescript] long = "bigint"
+	Types map[string]map[string]string `toml:"types"`
+}
+
+var codegenLanguages = []string{"go", "typescript", "csharp"}
+
+// RegisterCodegenLanguage adds the name of a language which can be configured
+func RegisterCodegenLanguage(lang string) {
+	for _, l := range codegenLanguages {
+		if l == lang {
+			return
+		}
+	}
+	codegenLanguages = append(codegenLanguages, lang)
+}
+
+func verifyCodegenConfig(codegen *CodegenConfig) error {
+	langs := make([]string, 0, len(codegen.Types))
+	for lang := range codegen.Types {
+		langs = append(langs, lang)
+	}
+	sort.Strings(langs)
+
+	for _, lang := range langs {
+		known := false
+		for _, l := range codegenLanguages {
+			if l == lang {
+				known = true
+				break
+			}
+		}
+		if !known {
+			return fmt.Errorf("Invalid codegen language: %s\nLanguage should be one of %s", lang, strings.Join(codegenLanguages, ", "))
+		}
+		for valueType, typeName := range codegen.Types[lang] {
+			if strings.TrimSpace(typeName) == "" {
+				return fmt.Errorf("Invalid codegen type of %s in %s\nType name should not be empty", valueType, lang)
+			}
+		}
+	}
+	return nil
+}
@@ -2,7 +2,7 @@ package config
 
 import (
 	"fmt"
-	"sort"
+	"strings"
 
 	"github.com/kama2vern/cxtj/logger"
 
@@ -16,11 +16,33 @@ var DefaultConfig *Config
 type Config struct {
 	ExcelFormats []ExcelFormat `toml:"excel"`
 	ExcelExts    []string      `toml:"excel_extension"`
+	Output       OutputConfig  `toml:"output"`
+	Codegen      CodegenConfig `toml:"codegen"`
 
-	// TODO: output json config
+	// DuplicateSheet decides how to treat the same sheet name in multiple xlsx files
+	DuplicateSheet DuplicateSheetPolicy `toml:"duplicate_sheet"`
+
+	// ArrayDelimiter separates elements of a cell of array value types like int[]
+	ArrayDelimiter string `toml:"array_delimiter"`
+
+	// PrimaryKeys is the primary key column of each sheet name.
+	// A primary key can also be declared by a value type with "!" suffix like "int!".
+	PrimaryKeys map[string]string `toml:"primary_key"`
+
+	// CacheDir is the directory to store converted xlsx files, and the cache is disabled when it is empty
+	CacheDir string `toml:"cache_dir"`
+
+	// SheetJobs is the number of workers to convert sheets of a xlsx file concurrently.
+	// When it is 0, the number of cpu is used, or sheets are converted one by one if xlsx files are
+	// converted by multiple workers. Sheets are converted one by one when it is 1.
+	SheetJobs int `toml:"sheet_jobs"`
 }
 
-// ExcelFormat represents an input excel format
+// DefaultArrayDelimiter is used when array_delimiter is not configured
+const DefaultArrayDelimiter = ","
+
+// ExcelFormat represents an input excel format.
+// Key, value-type and comment rows can be placed on any row line, and data format is the row line where data starts.
 type ExcelFormat struct {
 	RowType ExcelFormatRowType `toml:"row_type"`
 	RowLine int                `toml:"row_line"`
@@ -72,6 +94,46 @@ func (c *ExcelFormatRowType) UnmarshalText(text []byte) error {
 	}
 }
 
+// DuplicateSheetPolicy is an enum to represent how to treat duplicate sheet names across xlsx files.
+type DuplicateSheetPolicy int
+
+// DuplicateSheetPolicy enum values
+const (
+	DuplicateSheetPolicyError DuplicateSheetPolicy = iota
+	DuplicateSheetPolicyNamespace
+	DuplicateSheetPolicyAppend
+)
+
+func (p DuplicateSheetPolicy) String() string {
+	switch p {
+	case DuplicateSheetPolicyError:
+		return "error"
+	case DuplicateSheetPolicyNamespace:
+		return "namespace"
+	case DuplicateSheetPolicyAppend:
+		return "append"
+	}
+	return ""
+}
+
+// UnmarshalText is used by toml unmarshaller
+func (p *DuplicateSheetPolicy) UnmarshalText(text []byte) error {
+	switch string(text) {
+	case "error", "":
+		*p = DuplicateSheetPolicyError
+		return nil
+	case "namespace":
+		*p = DuplicateSheetPolicyNamespace
+		return nil
+	case "append":
+		*p = DuplicateSheetPolicyAppend
+		return nil
+	default:
+		*p = DuplicateSheetPolicyError
+		return fmt.Errorf("failed to parse duplicate sheet policy: %s", string(text))
+	}
+}
+
 func init() {
 	DefaultConfig = &Config{
 		ExcelExts: []string{
@@ -91,6 +153,8 @@ func init() {
 				RowLine: 3,
 			},
 		},
+		Output:         DefaultOutputConfig(),
+		ArrayDelimiter: DefaultArrayDelimiter,
 	}
 }
 
@@ -123,19 +187,72 @@ func (c *Config) GetExcelFormatByRowType(rowType ExcelFormatRowType) (ExcelForma
 	return ExcelFormat{}, fmt.Errorf("not found excel format. row_type: %s", rowType.String())
 }
 
+// DataRowLine returns the row line where data starts.
+// It is the row line of data format if configured, otherwise the next line of the last header row.
+func (c *Config) DataRowLine() int {
+	if excelFormat, err := c.GetExcelFormatByRowType(ExcelFormatRowTypeData); err == nil {
+		return excelFormat.RowLine
+	}
+
+	line := 0
+	for _, excelFormat := range c.ExcelFormats {
+		if excelFormat.RowLine > line {
+			line = excelFormat.RowLine
+		}
+	}
+	return line + 1
+}
+
+// ElementDelimiter returns the configured array delimiter or DefaultArrayDelimiter
+func (c *Config) ElementDelimiter() string {
+	if c.ArrayDelimiter == "" {
+		return DefaultArrayDelimiter
+	}
+	return c.ArrayDelimiter
+}
+
+// IsHeaderRowLine reports whether the row line is a key, value-type or comment row
+func (c *Config) IsHeaderRowLine(line int) bool {
+	excelFormat, err := c.GetExcelFormatByLine(line)
+	return err == nil && excelFormat.RowType != ExcelFormatRowTypeData
+}
+
 func verifyConfig(config *Config) error {
-	var rowLines []int
-	for _, excelFormat := range config.ExcelFormats {
-		rowLines = append(rowLines, excelFormat.RowLine)
+	if err := verifyOutputConfig(&config.Output); err != nil {
+		return err
+	}
+	if err := verifyCodegenConfig(&config.Codegen); err != nil {
+		return err
+	}
+
+	if strings.Contains(config.ArrayDelimiter, "\\") {
+		return fmt.Errorf("Invalid array delimiter %q\nBackslash is reserved to escape the delimiter", config.ArrayDelimiter)
+	}
+
+	if config.SheetJobs < 0 {
+		return fmt.Errorf("Invalid sheet jobs %d\nSheet jobs should be 0 or more", config.SheetJobs)
+	}
+
+	for sheet, column := range config.PrimaryKeys {
+		if column == "" {
+			return fmt.Errorf("Invalid primary key configuration\nPrimary key column of sheet %s is empty", sheet)
+		}
 	}
-	sort.Ints(rowLines)
-	for index := range rowLines {
-		if index+1 >= len(rowLines) {
-			break
+
+	rowLines := map[int]bool{}
+	rowTypes := map[ExcelFormatRowType]bool{}
+	for _, excelFormat := range config.ExcelFormats {
+		if excelFormat.RowLine < 1 {
+			return fmt.Errorf("Invalid Excel Format configuration\nRow Line of %s should be 1 or more, but %d", excelFormat.RowType, excelFormat.RowLine)
+		}
+		if rowLines[excelFormat.RowLine] {
+			return fmt.Errorf("Invalid Excel Format configuration\nRow Line %d is configured more than once", excelFormat.RowLine)
 		}
-		if rowLines[index+1]-rowLines[index] != 1 {
-			return fmt.Errorf("Invalid Excel Format configuration\nRow Lines of excel formats should be in serial numbers")
+		if rowTypes[excelFormat.RowType] {
+			return fmt.Errorf("Invalid Excel Format configuration\nRow Type %s is configured more than once", excelFormat.RowType)
 		}
+		rowLines[excelFormat.RowLine] = true
+		rowTypes[excelFormat.RowType] = true
 	}
 	return nil
 }
@@ -146,7 +263,9 @@ func LoadConfigFile(file string) (*Config, error) {
 		return DefaultConfig, nil
 	}
 
-	config := &Config{}
+	config := &Config{
+		Output: DefaultOutputConfig(),
+	}
 	if _, err := toml.DecodeFile(file, config); err != nil {
 		logger.ErrorIf(err)
 		return nil, err
@@ -159,3 +278,8 @@ func LoadConfigFile(file string) (*Config, error) {
 
 	return config, nil
 }
+
+// Verify checks the configuration is valid
+func (c *Config) Verify() error {
+	return verifyConfig(c)
+}
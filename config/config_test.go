@@ -3,7 +3,10 @@ package config
 import (
 	"os"
 	"path"
+	"strings"
 	"testing"
+
+	"github.com/BurntSushi/toml"
 )
 
 func TestLoadExcelFormatFromConfig(t *testing.T) {
@@ -63,4 +66,268 @@ func TestValidationOfConfig(t *testing.T) {
 }
 
 func TestLoadJsonFormatFromConfig(t *testing.T) {
+	dir, _ := os.Getwd()
+	conffle := path.Join(dir, "..", "test", "output.conf")
+
+	conf, err := LoadConfigFile(conffle)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	output := conf.Output
+	if output.Format != "yaml" {
+		t.Errorf("invalid format. expect: yaml, actual: %s", output.Format)
+	}
+	if output.FileName != "{book}/{sheet}.{ext}" {
+		t.Errorf("invalid file name. expect: {book}/{sheet}.{ext}, actual: %s", output.FileName)
+	}
+	if !output.Pretty || output.Indent != "\t" {
+		t.Errorf("invalid pretty print. pretty: %t, indent: %q", output.Pretty, output.Indent)
+	}
+	if output.KeyOrder != KeyOrderColumn {
+		t.Errorf("invalid key order. expect: column, actual: %s", output.KeyOrder)
+	}
+	if output.Shape != OutputShapeArray {
+		t.Errorf("invalid shape. expect: array, actual: %s", output.Shape)
+	}
+	if output.EmptyCell != EmptyCellNull {
+		t.Errorf("invalid empty cell. expect: null, actual: %s", output.EmptyCell)
+	}
+	if output.Newline != NewlineCRLF {
+		t.Errorf("invalid newline. expect: crlf, actual: %s", output.Newline)
+	}
+}
+
+func TestDefaultOutputConfig(t *testing.T) {
+	dir, _ := os.Getwd()
+	conffle := path.Join(dir, "..", "test", "cxtj.conf")
+
+	conf, err := LoadConfigFile(conffle)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if conf.Output != DefaultOutputConfig() {
+		t.Errorf("output config should be default when it is not configured: %+v", conf.Output)
+	}
+}
+
+func TestValidationOfOutputConfig(t *testing.T) {
+	for section, expect := range map[string]string{
+		`format = "xml"`:                       "Invalid output format: xml",
+		`file_name = "{book}.json"`:            "Invalid output file name: {book}.json",
+		`indent = "--"`:                        `Invalid output indent: "--"`,
+		`key_order = "random"`:                 "failed to parse key order: random",
+		`shape = "tree"`:                       "failed to parse output shape: tree",
+		"format = \"toml\"\nshape = \"array\"": "Toml document cannot be an array",
+		`empty_cell = "nil"`:                   "failed to parse empty cell: nil",
+		`newline = "cr"`:                       "failed to parse newline: cr",
+		`row_shape = "tree"`:                   "failed to parse row shape: tree",
+	} {
+		// an invalid value is rejected by decoding or verification
+		conf := &Config{Output: DefaultOutputConfig()}
+		_, err := toml.Decode("[output]\n"+section, conf)
+		if err == nil {
+			err = conf.Verify()
+		}
+		if err == nil || !strings.Contains(err.Error(), expect) {
+			t.Errorf("invalid output config should be rejected with %q: %s, actual %v", expect, section, err)
+		}
+	}
+
+	// enum values which are set without decoding are verified
+	for expect, set := range map[string]func(output *OutputConfig){
+		"Invalid key order: 9":    func(output *OutputConfig) { output.KeyOrder = 9 },
+		"Invalid output shape: 9": func(output *OutputConfig) { output.Shape = 9 },
+		"Invalid row shape: 9":    func(output *OutputConfig) { output.RowShape = 9 },
+		"Invalid empty cell: 9":   func(output *OutputConfig) { output.EmptyCell = 9 },
+		"Invalid newline: 9":      func(output *OutputConfig) { output.Newline = 9 },
+	} {
+		conf := &Config{Output: DefaultOutputConfig()}
+		set(&conf.Output)
+		if err := conf.Verify(); err == nil || !strings.Contains(err.Error(), expect) {
+			t.Errorf("invalid output config should be rejected with %q, actual %v", expect, err)
+		}
+	}
+}
+
+func TestVerifyOutputFileName(t *testing.T) {
+	for _, fileName := range []string{"{sheet}.json", "{book}/{sheet}.json", "{book}.{sheet}.json"} {
+		if err := VerifyOutputFileName(fileName); err != nil {
+			t.Errorf("valid output file name is rejected: %s", fileName)
+		}
+	}
+	for _, fileName := range []string{"", "{book}.json", "output.json"} {
+		if err := VerifyOutputFileName(fileName); err == nil {
+			t.Errorf("output file name without {sheet} should be rejected: %s", fileName)
+		}
+	}
+}
+
+func TestDuplicateSheetPolicyFromConfig(t *testing.T) {
+	for text, expect := range map[string]DuplicateSheetPolicy{
+		"error":     DuplicateSheetPolicyError,
+		"namespace": DuplicateSheetPolicyNamespace,
+		"append":    DuplicateSheetPolicyAppend,
+	} {
+		conf := &Config{}
+		if _, err := toml.Decode("duplicate_sheet = \""+text+"\"", conf); err != nil {
+			t.Fatal(err)
+		}
+		if conf.DuplicateSheet != expect {
+			t.Errorf("invalid duplicate sheet policy. expect: %s, actual: %s", expect, conf.DuplicateSheet)
+		}
+	}
+
+	conf := &Config{}
+	if _, err := toml.Decode(`duplicate_sheet = "overwrite"`, conf); err == nil {
+		t.Error("unknown duplicate sheet policy should be error")
+	}
+}
+
+func TestCodegenTypesFromConfig(t *testing.T) {
+	conf := &Config{Output: DefaultOutputConfig()}
+	if _, err := toml.Decode("[codegen.types.typescript]\nlong = \"bigint\"", conf); err != nil {
+		t.Fatal(err)
+	}
+	if err := conf.Verify(); err != nil {
+		t.Fatal(err)
+	}
+	if conf.Codegen.Types["typescript"]["long"] != "bigint" {
+		t.Errorf("invalid codegen type. expect: bigint, actual: %s", conf.Codegen.Types["typescript"]["long"])
+	}
+
+	for _, section := range []string{
+		"[codegen.types.cobol]\nlong = \"PIC 9(18)\"",
+		"[codegen.types.go]\nlong = \"\"",
+	} {
+		conf := &Config{Output: DefaultOutputConfig()}
+		if _, err := toml.Decode(section, conf); err != nil {
+			t.Fatal(err)
+		}
+		if err := conf.Verify(); err == nil {
+			t.Errorf("invalid codegen config should be rejected: %s", section)
+		}
+	}
+}
+
+func TestLoadExcelLayoutFromConfig(t *testing.T) {
+	dir, _ := os.Getwd()
+	conffle := path.Join(dir, "..", "test", "layout.conf")
+
+	conf, err := LoadConfigFile(conffle)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if conf.DataRowLine() != 7 {
+		t.Errorf("invalid data row line. expect: 7, actual: %d", conf.DataRowLine())
+	}
+	for line, expect := range map[int]bool{1: true, 2: false, 3: false, 4: true, 5: true, 6: false, 7: false} {
+		if conf.IsHeaderRowLine(line) != expect {
+			t.Errorf("invalid header row line %d. expect: %t", line, expect)
+		}
+	}
+}
+
+func TestArrayDelimiterFromConfig(t *testing.T) {
+	if DefaultConfig.ElementDelimiter() != "," {
+		t.Errorf("invalid default array delimiter. actual: %s", DefaultConfig.ElementDelimiter())
+	}
+
+	conf := &Config{Output: DefaultOutputConfig()}
+	if _, err := toml.Decode("array_delimiter = \"|\"", conf); err != nil {
+		t.Fatal(err)
+	}
+	if err := conf.Verify(); err != nil {
+		t.Fatal(err)
+	}
+	if conf.ElementDelimiter() != "|" {
+		t.Errorf("invalid array delimiter. expect: |, actual: %s", conf.ElementDelimiter())
+	}
+
+	conf.ArrayDelimiter = "\\"
+	if err := conf.Verify(); err == nil {
+		t.Error("backslash should be rejected as array delimiter")
+	}
+}
+
+func TestSheetJobsFromConfig(t *testing.T) {
+	conf := &Config{Output: DefaultOutputConfig()}
+	if _, err := toml.Decode("sheet_jobs = 4", conf); err != nil {
+		t.Fatal(err)
+	}
+	if err := conf.Verify(); err != nil {
+		t.Fatal(err)
+	}
+	if conf.SheetJobs != 4 {
+		t.Errorf("invalid sheet jobs. expect: 4, actual: %d", conf.SheetJobs)
+	}
+
+	conf.SheetJobs = -1
+	if err := conf.Verify(); err == nil {
+		t.Error("negative sheet jobs should be rejected")
+	}
+}
+
+func TestPrimaryKeyFromConfig(t *testing.T) {
+	conf := &Config{Output: DefaultOutputConfig()}
+	if _, err := toml.Decode("[primary_key]\ncharacters = \"id\"\n[output]\nrow_shape = \"keyed\"", conf); err != nil {
+		t.Fatal(err)
+	}
+	if err := conf.Verify(); err != nil {
+		t.Fatal(err)
+	}
+	if conf.PrimaryKeys["characters"] != "id" || conf.Output.RowShape != RowShapeKeyed {
+		t.Errorf("invalid primary key config. actual: %v, %s", conf.PrimaryKeys, conf.Output.RowShape)
+	}
+
+	conf.PrimaryKeys["items"] = ""
+	if err := conf.Verify(); err == nil {
+		t.Error("empty primary key column should be rejected")
+	}
+}
+
+func TestDataRowLine(t *testing.T) {
+	if DefaultConfig.DataRowLine() != 4 {
+		t.Errorf("data should start after the last header row. expect: 4, actual: %d", DefaultConfig.DataRowLine())
+	}
+
+	conf := &Config{ExcelFormats: []ExcelFormat{
+		{RowType: ExcelFormatRowTypeComment, RowLine: 1},
+		{RowType: ExcelFormatRowTypeKey, RowLine: 3},
+	}}
+	if conf.DataRowLine() != 4 {
+		t.Errorf("data should start after the last header row. expect: 4, actual: %d", conf.DataRowLine())
+	}
+
+	conf = &Config{}
+	if conf.DataRowLine() != 1 {
+		t.Errorf("data should start from the first row without excel formats. actual: %d", conf.DataRowLine())
+	}
+}
+
+func TestValidationOfExcelFormats(t *testing.T) {
+	for _, excelFormats := range [][]ExcelFormat{
+		{{RowType: ExcelFormatRowTypeKey, RowLine: 0}},
+		{{RowType: ExcelFormatRowTypeKey, RowLine: 1}, {RowType: ExcelFormatRowTypeComment, RowLine: 1}},
+		{{RowType: ExcelFormatRowTypeKey, RowLine: 1}, {RowType: ExcelFormatRowTypeKey, RowLine: 2}},
+		{{RowType: ExcelFormatRowTypeData, RowLine: 3}, {RowType: ExcelFormatRowTypeData, RowLine: 5}},
+	} {
+		conf := &Config{ExcelFormats: excelFormats, Output: DefaultOutputConfig()}
+		if err := conf.Verify(); err == nil {
+			t.Errorf("invalid excel formats should be rejected: %v", excelFormats)
+		}
+	}
+
+	conf := &Config{
+		ExcelFormats: []ExcelFormat{
+			{RowType: ExcelFormatRowTypeComment, RowLine: 1},
+			{RowType: ExcelFormatRowTypeKey, RowLine: 3},
+			{RowType: ExcelFormatRowTypeData, RowLine: 5},
+			{RowType: ExcelFormatRowTypeValueType, RowLine: 8},
+		},
+		Output: DefaultOutputConfig(),
+	}
+	if err := conf.Verify(); err != nil {
+		t.Errorf("excel formats in any row lines should be valid: %s", err)
+	}
 }
@@ -0,0 +1,313 @@
+package config
+
+import (
+	"fmt"
+	"strings"
+)
+
+// OutputConfig represents an output format
+type OutputConfig struct {
+	// Format is an output format such as json, yaml, toml and csv.
+	// When it is empty, the format is detected from the extension of the output file.
+	Format string `toml:"format"`
+
+	// FileName is a template of the file name for --multiple-output mode.
+	// {book} is replaced with the xlsx file name without extension, {sheet} with the sheet name
+	// and {ext} with the extension of the output format.
+	FileName string `toml:"file_name"`
+
+	// Pretty enables indented output with Indent
+	Pretty bool   `toml:"pretty"`
+	Indent string `toml:"indent"`
+
+	KeyOrder  KeyOrder    `toml:"key_order"`
+	Shape     OutputShape `toml:"shape"`
+	RowShape  RowShape    `toml:"row_shape"`
+	EmptyCell EmptyCell   `toml:"empty_cell"`
+	Newline   Newline     `toml:"newline"`
+}
+
+// DefaultOutputFileName is a file name template used when it is not configured
+const DefaultOutputFileName = "{sheet}.{ext}"
+
+// DefaultIndent is an indent used when it is not configured
+const DefaultIndent = "  "
+
+// DefaultOutputConfig provides default output configuration
+func DefaultOutputConfig() OutputConfig {
+	return OutputConfig{
+		FileName: DefaultOutputFileName,
+		Indent:   DefaultIndent,
+		KeyOrder: KeyOrderColumn,
+	}
+}
+
+var outputFormats = []string{"json", "yaml", "toml", "csv"}
+
+// RegisterOutputFormat adds the name of an output format which can be configured
+func RegisterOutputFormat(format string) {
+	for _, f := range outputFormats {
+		if f == format {
+			return
+		}
+	}
+	outputFormats = append(outputFormats, format)
+}
+
+// KeyOrder is an enum to represent the order of keys in each row.
+type KeyOrder int
+
+// KeyOrder enum values
+const (
+	KeyOrderAlphabetical KeyOrder = iota
+	KeyOrderColumn
+)
+
+func (o KeyOrder) String() string {
+	switch o {
+	case KeyOrderAlphabetical:
+		return "alphabetical"
+	case KeyOrderColumn:
+		return "column"
+	}
+	return ""
+}
+
+// UnmarshalText is used by toml unmarshaller
+func (o *KeyOrder) UnmarshalText(text []byte) error {
+	switch string(text) {
+	case "alphabetical":
+		*o = KeyOrderAlphabetical
+		return nil
+	case "column", "":
+		*o = KeyOrderColumn
+		return nil
+	default:
+		*o = KeyOrderAlphabetical
+		return fmt.Errorf("failed to parse key order: %s", string(text))
+	}
+}
+
+// OutputShape is an enum to represent the top-level shape of output.
+type OutputShape int
+
+// OutputShape enum values
+const (
+	// OutputShapeObject is an object keyed by sheet name
+	OutputShapeObject OutputShape = iota
+	// OutputShapeArray is an array of objects which have name and rows (or columns) of each sheet
+	OutputShapeArray
+)
+
+func (s OutputShape) String() string {
+	switch s {
+	case OutputShapeObject:
+		return "object"
+	case OutputShapeArray:
+		return "array"
+	}
+	return ""
+}
+
+// UnmarshalText is used by toml unmarshaller
+func (s *OutputShape) UnmarshalText(text []byte) error {
+	switch string(text) {
+	case "object", "":
+		*s = OutputShapeObject
+		return nil
+	case "array":
+		*s = OutputShapeArray
+		return nil
+	default:
+		*s = OutputShapeObject
+		return fmt.Errorf("failed to parse output shape: %s", string(text))
+	}
+}
+
+// RowShape is an enum to represent the shape of rows of each sheet.
+type RowShape int
+
+// RowShape enum values
+const (
+	// RowShapeArray is an array of rows
+	RowShapeArray RowShape = iota
+	// RowShapeKeyed is an object of rows keyed by the primary key, and sheets without primary key are arrays
+	RowShapeKeyed
+)
+
+func (s RowShape) String() string {
+	switch s {
+	case RowShapeArray:
+		return "array"
+	case RowShapeKeyed:
+		return "keyed"
+	}
+	return ""
+}
+
+// UnmarshalText is used by toml unmarshaller
+func (s *RowShape) UnmarshalText(text []byte) error {
+	switch string(text) {
+	case "array", "":
+		*s = RowShapeArray
+		return nil
+	case "keyed":
+		*s = RowShapeKeyed
+		return nil
+	default:
+		*s = RowShapeArray
+		return fmt.Errorf("failed to parse row shape: %s", string(text))
+	}
+}
+
+// EmptyCell is an enum to represent how to output an empty cell.
+type EmptyCell int
+
+// EmptyCell enum values
+const (
+	// EmptyCellDefault is "" for string columns, [] for array columns and null for the others
+	EmptyCellDefault EmptyCell = iota
+	// EmptyCellNull is null for all columns
+	EmptyCellNull
+	// EmptyCellEmptyString is "" for all columns
+	EmptyCellEmptyString
+	// EmptyCellZero is the zero value of the value type like "", 0 and false
+	EmptyCellZero
+	// EmptyCellOmit omits the key from the row
+	EmptyCellOmit
+)
+
+func (e EmptyCell) String() string {
+	switch e {
+	case EmptyCellDefault:
+		return "default"
+	case EmptyCellNull:
+		return "null"
+	case EmptyCellEmptyString:
+		return "empty-string"
+	case EmptyCellZero:
+		return "zero"
+	case EmptyCellOmit:
+		return "omit"
+	}
+	return ""
+}
+
+// UnmarshalText is used by toml unmarshaller
+func (e *EmptyCell) UnmarshalText(text []byte) error {
+	switch string(text) {
+	case "default", "":
+		*e = EmptyCellDefault
+		return nil
+	case "null":
+		*e = EmptyCellNull
+		return nil
+	case "empty-string":
+		*e = EmptyCellEmptyString
+		return nil
+	case "zero":
+		*e = EmptyCellZero
+		return nil
+	case "omit":
+		*e = EmptyCellOmit
+		return nil
+	default:
+		*e = EmptyCellDefault
+		return fmt.Errorf("failed to parse empty cell: %s", string(text))
+	}
+}
+
+// Newline is an enum to represent the newline style of output.
+// It is applied to line breaks of the format, and newlines in string values are kept as they are.
+type Newline int
+
+// Newline enum values
+const (
+	NewlineLF Newline = iota
+	NewlineCRLF
+)
+
+func (n Newline) String() string {
+	switch n {
+	case NewlineLF:
+		return "lf"
+	case NewlineCRLF:
+		return "crlf"
+	}
+	return ""
+}
+
+// Bytes returns the newline characters
+func (n Newline) Bytes() []byte {
+	if n == NewlineCRLF {
+		return []byte("\r\n")
+	}
+	return []byte("\n")
+}
+
+// UnmarshalText is used by toml unmarshaller
+func (n *Newline) UnmarshalText(text []byte) error {
+	switch string(text) {
+	case "lf", "":
+		*n = NewlineLF
+		return nil
+	case "crlf":
+		*n = NewlineCRLF
+		return nil
+	default:
+		*n = NewlineLF
+		return fmt.Errorf("failed to parse newline: %s", string(text))
+	}
+}
+
+// VerifyOutputFileName checks the file name template for --multiple-output mode
+func VerifyOutputFileName(fileName string) error {
+	if !strings.Contains(fileName, "{sheet}") {
+		return fmt.Errorf("Invalid output file name: %s\nOutput file name should contain {sheet}", fileName)
+	}
+	return nil
+}
+
+// VerifyOutputFormat checks the output format is known
+func VerifyOutputFormat(format string) error {
+	if format == "" {
+		return nil
+	}
+	for _, f := range outputFormats {
+		if f == format {
+			return nil
+		}
+	}
+	return fmt.Errorf("Invalid output format: %s\nOutput format should be one of %s", format, strings.Join(outputFormats, ", "))
+}
+
+func verifyOutputConfig(output *OutputConfig) error {
+	if err := VerifyOutputFormat(output.Format); err != nil {
+		return err
+	}
+	if err := VerifyOutputFileName(output.FileName); err != nil {
+		return err
+	}
+	if strings.Trim(output.Indent, " \t") != "" {
+		return fmt.Errorf("Invalid output indent: %q\nIndent should consist of spaces or tabs", output.Indent)
+	}
+	if output.KeyOrder.String() == "" {
+		return fmt.Errorf("Invalid key order: %d", output.KeyOrder)
+	}
+	if output.Shape.String() == "" {
+		return fmt.Errorf("Invalid output shape: %d", output.Shape)
+	}
+	if output.Shape == OutputShapeArray && output.Format == "toml" {
+		return fmt.Errorf("Invalid output shape: array\nToml document cannot be an array")
+	}
+	if output.RowShape.String() == "" {
+		return fmt.Errorf("Invalid row shape: %d", output.RowShape)
+	}
+	if output.EmptyCell.String() == "" {
+		return fmt.Errorf("Invalid empty cell: %d", output.EmptyCell)
+	}
+	if output.Newline.String() == "" {
+		return fmt.Errorf("Invalid newline: %d", output.Newline)
+	}
+	return nil
+}
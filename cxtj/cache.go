@@ -0,0 +1,135 @@
+package cxtj
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/gob"
+	"encoding/hex"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/kama2vern/cxtj/config"
+)
+
+// cacheVersion is changed when the format of cache files or converted values are changed
+const cacheVersion = "1"
+
+func init() {
+	gob.Register([]interface{}{})
+	gob.Register(map[string]interface{}{})
+}
+
+// workbookCache stores converted workbooks in a directory keyed by the content hash of xlsx files and the config hash
+type workbookCache struct {
+	dir        string
+	configHash string
+}
+
+// cacheEntry is the content of a cache file
+type cacheEntry struct {
+	Sheets      XlsxMap
+	Columns     map[string][]string
+	PrimaryKeys map[string]string
+}
+
+// newWorkbookCache creates workbookCache of the directory.
+// The config hash includes only the configuration which changes converted values.
+func newWorkbookCache(dir string, conf *config.Config) (*workbookCache, error) {
+	bs, err := json.Marshal(struct {
+		Version        string
+		ExcelFormats   []config.ExcelFormat
+		ArrayDelimiter string
+		PrimaryKeys    map[string]string
+		EmptyCell      config.EmptyCell
+	}{cacheVersion, conf.ExcelFormats, conf.ElementDelimiter(), conf.PrimaryKeys, conf.Output.EmptyCell})
+	if err != nil {
+		return nil, err
+	}
+	sum := sha256.Sum256(bs)
+	return &workbookCache{dir: dir, configHash: hex.EncodeToString(sum[:8])}, nil
+}
+
+// path returns the cache file path of the xlsx content
+func (c *workbookCache) path(content []byte) string {
+	sum := sha256.Sum256(content)
+	return filepath.Join(c.dir, hex.EncodeToString(sum[:])+"-"+c.configHash+".gob")
+}
+
+// load reads the converted workbook of the xlsx content if it is cached
+func (c *workbookCache) load(filename string, content []byte) (workbook, bool) {
+	f, err := os.Open(c.path(content))
+	if err != nil {
+		return workbook{}, false
+	}
+	defer f.Close()
+
+	entry := cacheEntry{}
+	if err := gob.NewDecoder(f).Decode(&entry); err != nil {
+		return workbook{}, false
+	}
+
+	book := workbook{file: filename, sheets: XlsxMap{}, columns: entry.Columns, primaryKeys: entry.PrimaryKeys}
+	for sheetName, rows := range entry.Sheets {
+		l := make(SheetDataList, len(rows))
+		for i, row := range rows {
+			l[i] = restoreCachedValue(row).(map[string]interface{})
+		}
+		book.sheets[sheetName] = l
+	}
+	if book.columns == nil {
+		book.columns = map[string][]string{}
+	}
+	if book.primaryKeys == nil {
+		book.primaryKeys = map[string]string{}
+	}
+	return book, true
+}
+
+// store writes the converted workbook of the xlsx content.
+// The cache file is written into a temporary file and renamed not to be read while writing.
+func (c *workbookCache) store(content []byte, book workbook) error {
+	var buf bytes.Buffer
+	entry := cacheEntry{Sheets: book.sheets, Columns: book.columns, PrimaryKeys: book.primaryKeys}
+	if err := gob.NewEncoder(&buf).Encode(entry); err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(c.dir, 0755); err != nil {
+		return err
+	}
+	f, err := ioutil.TempFile(c.dir, "tmp-")
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(buf.Bytes()); err != nil {
+		f.Close()
+		os.Remove(f.Name())
+		return err
+	}
+	if err := f.Close(); err != nil {
+		os.Remove(f.Name())
+		return err
+	}
+	return os.Rename(f.Name(), c.path(content))
+}
+
+// restoreCachedValue restores empty arrays and objects which are decoded as nil by gob
+func restoreCachedValue(v interface{}) interface{} {
+	switch value := v.(type) {
+	case []interface{}:
+		ret := make([]interface{}, len(value))
+		for i, element := range value {
+			ret[i] = restoreCachedValue(element)
+		}
+		return ret
+	case map[string]interface{}:
+		ret := make(map[string]interface{}, len(value))
+		for k, element := range value {
+			ret[k] = restoreCachedValue(element)
+		}
+		return ret
+	}
+	return v
+}
@@ -0,0 +1,106 @@
+package cxtj
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/kama2vern/cxtj/config"
+)
+
+func newTestCacheConverter(t *testing.T, cacheDir string) *Converter {
+	conf := *config.DefaultConfig
+	conf.CacheDir = cacheDir
+	c, err := NewConverter(&conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return c
+}
+
+func TestWorkbookCacheRoundTrip(t *testing.T) {
+	cacheDir, err := ioutil.TempDir("", "cxtj")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(cacheDir)
+
+	c := newTestCacheConverter(t, cacheDir)
+	book := workbook{
+		file: "before.xlsx",
+		sheets: XlsxMap{
+			"sheet": SheetDataList{
+				{"id": int64(1), "rate": 0.5, "name": "a", "memo": nil, "tags": []interface{}{}, "stats": map[string]interface{}{"hp": int64(10)}},
+			},
+			"empty": SheetDataList{},
+		},
+		columns:     map[string][]string{"sheet": {"id", "rate", "name", "memo", "tags", "stats.hp"}},
+		primaryKeys: map[string]string{"sheet": "id"},
+	}
+	content := []byte("content")
+	if err := c.cache.store(content, book); err != nil {
+		t.Fatal(err)
+	}
+
+	loaded, ok := c.cache.load("after.xlsx", content)
+	if !ok {
+		t.Fatal("cache is not found")
+	}
+	book.file = "after.xlsx"
+	if !reflect.DeepEqual(loaded, book) {
+		t.Errorf("invalid cached workbook. expect: %#v, actual: %#v", book, loaded)
+	}
+
+	if _, ok := c.cache.load("after.xlsx", []byte("changed")); ok {
+		t.Error("cache of changed content should not be found")
+	}
+}
+
+func TestConvertXlsxFileUsesCache(t *testing.T) {
+	dir, _ := os.Getwd()
+	xlsxFile := path.Join(dir, "..", "test", "excels", "convert_test.xlsx")
+
+	cacheDir, err := ioutil.TempDir("", "cxtj")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(cacheDir)
+
+	c := newTestCacheConverter(t, cacheDir)
+	book, err := c.convertXlsxFile(context.Background(), xlsxFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	files, _ := filepath.Glob(filepath.Join(cacheDir, "*.gob"))
+	if len(files) != 1 {
+		t.Fatalf("a cache file should be written, but %d files are found", len(files))
+	}
+
+	cached, err := newTestCacheConverter(t, cacheDir).convertXlsxFile(context.Background(), xlsxFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(cached, book) {
+		t.Errorf("cached workbook is different. expect: %#v, actual: %#v", book, cached)
+	}
+
+	// the cache of another config is not used
+	conf := *config.DefaultConfig
+	conf.CacheDir = cacheDir
+	conf.ArrayDelimiter = "|"
+	other, err := NewConverter(&conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := other.convertXlsxFile(context.Background(), xlsxFile); err != nil {
+		t.Fatal(err)
+	}
+	files, _ = filepath.Glob(filepath.Join(cacheDir, "*.gob"))
+	if len(files) != 2 {
+		t.Errorf("a cache file of another config should be written, but %d files are found", len(files))
+	}
+}
@@ -0,0 +1,1135 @@
+// Package cxtj converts xlsx files into json.
+// The key row, value-type row and comment row of each sheet are configured by config.Config.
+package cxtj
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/tealeg/xlsx"
+
+	"github.com/kama2vern/cxtj/config"
+	"github.com/kama2vern/cxtj/logger"
+)
+
+// Converter converts xlsx files into XlsxMap or XlsxHeaderMap by the config
+type Converter struct {
+	config *config.Config
+
+	// watchDebounce is the debounce time of Watch. DefaultWatchDebounce is used when it is 0.
+	watchDebounce time.Duration
+	// cache stores converted workbooks when cache_dir is configured
+	cache *workbookCache
+}
+
+// XlsxMap is converted data structure from xlsx file
+/*
+	{
+		#{sheet name}: {
+			[
+				#{column1 name}: #{row1 column1 value},
+				#{column2 name}: #{row1 column2 value},
+				#{column3 name}: #{row1 column3 value},
+				...
+			],
+			...
+		} // same as SheetDataList
+	}
+*/
+type XlsxMap map[string][]map[string]interface{}
+
+// SheetDataList is converted data structure from one of xlsx sheet
+/*
+	[
+		{
+			#{column1 name}: #{row1 column1 value},
+			#{column2 name}: #{row1 column2 value},
+			#{column3 name}: #{row1 column3 value},
+		},
+		{
+			#{column1 name}: #{row2 column1 value},
+			#{column2 name}: #{row2 column2 value},
+			#{column3 name}: #{row2 column3 value},
+		}
+	]
+*/
+type SheetDataList []map[string]interface{}
+
+// RowMap is one of the record from sheet
+// Each value is typed by the value-type row: int64 for int/long, float64 for float/double,
+// bool for bool and string for string. An empty cell is converted by the empty cell config.
+/*
+	{
+		#{column1 name}: #{row1 column1 value},
+		#{column2 name}: #{row1 column2 value},
+		#{column3 name}: #{row1 column3 value},
+	},
+*/
+type RowMap map[string]interface{}
+
+// ColumnInfo is the maximum information about one of the column
+type ColumnInfo struct {
+	Index     int    `json:"index" yaml:"index" toml:"index"`
+	ValueType string `json:"valueType" yaml:"valueType" toml:"valueType"`
+	Comment   string `json:"comment,omitempty" yaml:"comment,omitempty" toml:"comment,omitempty"`
+
+	// Length is the number of elements when ValueType is array
+	Length int `json:"length,omitempty" yaml:"length,omitempty" toml:"length,omitempty"`
+	// Items is the column of elements when ValueType is array
+	Items *ColumnInfo `json:"items,omitempty" yaml:"items,omitempty" toml:"items,omitempty"`
+	// Fields is the columns of the nested object when ValueType is object
+	Fields SheetColumns `json:"fields,omitempty" yaml:"fields,omitempty" toml:"fields,omitempty"`
+
+	// PrimaryKey reports whether the column is the primary key of the sheet
+	PrimaryKey bool `json:"primaryKey,omitempty" yaml:"primaryKey,omitempty" toml:"primaryKey,omitempty"`
+}
+
+/*
+	sheet: {
+		column1: {
+			Index: 0,
+			ValueType: "int",
+			Comment: "column1 comment",
+		},
+		column2: {
+			Index: 1,
+			ValueType: "string",
+		},
+		column3: {
+			Index: 2,
+			ValueType: "float",
+		},
+		stats: {
+			Index: 3,
+			ValueType: "object",
+			Fields: {
+				hp: {
+					Index: 3,
+					ValueType: "int",
+				},
+			},
+		},
+	}
+*/
+// SheetColumns is information list of columns
+type SheetColumns map[string]ColumnInfo
+
+// XlsxHeaderMap is information list of columns from one xlsx
+type XlsxHeaderMap map[string]map[string]ColumnInfo
+
+func (c *Converter) sheet2Map(sheet *xlsx.Sheet) (SheetDataList, error) {
+	_, rows, err := c.sheetRows(sheet)
+	if err != nil {
+		return nil, err
+	}
+
+	converts := make(SheetDataList, len(rows))
+	for i, row := range rows {
+		converts[i] = row.values
+	}
+	return converts, nil
+}
+
+// sheetRow is a converted data row with the 1-based row line in the sheet
+type sheetRow struct {
+	line   int
+	values RowMap
+}
+
+// sheetRows converts data rows of the sheet with the header.
+// Rows which have all empty values are skipped, and the header is nil for an empty sheet.
+func (c *Converter) sheetRows(sheet *xlsx.Sheet) (*sheetHeader, []sheetRow, error) {
+	if len(sheet.Rows) == 0 {
+		return nil, []sheetRow{}, nil
+	}
+
+	header, err := c.sheetHeader(sheet)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	converts := make([]sheetRow, 0, len(sheet.Rows))
+	primaryKeys := primaryKeyLines{}
+	for i := c.config.DataRowLine() - 1; i < len(sheet.Rows); i++ {
+		if c.config.IsHeaderRowLine(i + 1) {
+			continue
+		}
+		r := sheet.Rows[i]
+
+		convertMap, isEmptyRow, err := c.convertRow(sheet.Name, header, i, func(j int) (string, error) {
+			if j >= len(r.Cells) {
+				return "", nil
+			}
+			return c.cellValue(r.Cells[j], header.valueTypes[j])
+		})
+		if err != nil {
+			return nil, nil, err
+		}
+		// ignore row which has all empty values
+		if isEmptyRow {
+			continue
+		}
+		if err := primaryKeys.add(sheet.Name, header, i, convertMap); err != nil {
+			return nil, nil, err
+		}
+		converts = append(converts, sheetRow{line: i + 1, values: convertMap})
+	}
+
+	return header, converts, nil
+}
+
+// convertRow converts the data row at the 0-based index i of the sheet by the header.
+// raw returns the raw string of the j-th cell. It reports whether all values of the row are empty.
+func (c *Converter) convertRow(sheetName string, header *sheetHeader, i int, raw func(j int) (string, error)) (RowMap, bool, error) {
+	convertMap := RowMap{}
+	isEmptyRow := true
+	for j, valueType := range header.valueTypes {
+		value, err := raw(j)
+		if err != nil {
+			return nil, false, newCellError(sheetName, i, j, err)
+		}
+		if len(value) > 0 {
+			isEmptyRow = false
+		}
+
+		if isEmptyValue(valueType, value) {
+			if converted, ok := c.emptyCellValue(valueType); ok {
+				setPathValue(convertMap, header.columns, header.paths[j], converted)
+			}
+			continue
+		}
+
+		converted, err := c.convertCellValue(valueType, value)
+		if err != nil {
+			return nil, false, newCellError(sheetName, i, j, err)
+		}
+		setPathValue(convertMap, header.columns, header.paths[j], converted)
+	}
+	return convertMap, isEmptyRow, nil
+}
+
+// primaryKeyLines is the 1-based row line of each primary key in a sheet to find duplicate keys
+type primaryKeyLines map[string]int
+
+// add checks the primary key of the converted row at the 0-based index i is not empty and not duplicate
+func (l primaryKeyLines) add(sheetName string, header *sheetHeader, i int, row RowMap) error {
+	if header.primaryKey < 0 {
+		return nil
+	}
+	key := getPathValue(row, header.paths[header.primaryKey])
+	if key == nil || key == "" {
+		return newCellError(sheetName, i, header.primaryKey, fmt.Errorf("primary key %s is empty", header.keys[header.primaryKey]))
+	}
+	if line, ok := l[primaryKeyString(key)]; ok {
+		return newCellError(sheetName, i, header.primaryKey, fmt.Errorf("duplicate primary key %v, which is also in row %d", key, line))
+	}
+	l[primaryKeyString(key)] = i + 1
+	return nil
+}
+
+// emptyCellValue returns the value of an empty cell by the empty cell config.
+// It returns false when the key should be omitted.
+func (c *Converter) emptyCellValue(valueType string) (interface{}, bool) {
+	switch c.config.Output.EmptyCell {
+	case config.EmptyCellNull:
+		return nil, true
+	case config.EmptyCellEmptyString:
+		return "", true
+	case config.EmptyCellZero:
+		return zeroValue(valueType), true
+	case config.EmptyCellOmit:
+		return nil, false
+	}
+
+	if isArrayValueType(valueType) {
+		return []interface{}{}, true
+	}
+	if isStringValueType(valueType) {
+		return "", true
+	}
+	return nil, true
+}
+
+// convertCellValue converts a raw cell value into the value type.
+// Array value types are split by the delimiter of the value type like "string[|]" or the configured one.
+func (c *Converter) convertCellValue(valueType string, raw string) (interface{}, error) {
+	elementType, delimiter, ok := ParseArrayValueType(valueType)
+	if !ok {
+		return convertValue(valueType, raw)
+	}
+	if delimiter == "" {
+		delimiter = c.config.ElementDelimiter()
+	}
+	return convertArrayValue(elementType, delimiter, raw)
+}
+
+// rowCells returns cells of the row of the row type.
+// It returns nil if the row type is not configured or the sheet does not have the row.
+func (c *Converter) rowCells(sheet *xlsx.Sheet, rowType config.ExcelFormatRowType) []*xlsx.Cell {
+	excelFormat, err := c.config.GetExcelFormatByRowType(rowType)
+	if err != nil || excelFormat.RowLine > len(sheet.Rows) {
+		return nil
+	}
+	return sheet.Rows[excelFormat.RowLine-1].Cells
+}
+
+// sheetKeys returns column names of the sheet from the key row in order of columns
+func (c *Converter) sheetKeys(sheet *xlsx.Sheet) ([]string, error) {
+	keyExcelFormat, err := c.config.GetExcelFormatByRowType(config.ExcelFormatRowTypeKey)
+	if err != nil {
+		return nil, &ConvertError{Sheet: sheet.Name, Err: err}
+	}
+	if keyExcelFormat.RowLine > len(sheet.Rows) {
+		return nil, &ConvertError{Sheet: sheet.Name, Row: keyExcelFormat.RowLine, Err: fmt.Errorf("key row is not found")}
+	}
+
+	cells := sheet.Rows[keyExcelFormat.RowLine-1].Cells
+	ret := make([]string, len(cells))
+	for i, c := range cells {
+		ret[i] = c.Value
+	}
+	return ret, nil
+}
+
+// sheetHeader is the header of a sheet read from the key, value-type and comment rows
+type sheetHeader struct {
+	keys       []string
+	valueTypes []string
+	columns    SheetColumns
+	paths      []columnPath
+	// primaryKey is the index of the primary key column, or -1 if the sheet has no primary key
+	primaryKey int
+}
+
+// primaryKeyColumn returns the name of the primary key column, or "" if the sheet has no primary key
+func (h *sheetHeader) primaryKeyColumn() string {
+	if h == nil || h.primaryKey < 0 {
+		return ""
+	}
+	return h.keys[h.primaryKey]
+}
+
+// emptySheetHeader returns the header of an empty sheet which has no columns
+func emptySheetHeader() *sheetHeader {
+	return &sheetHeader{
+		keys:       []string{},
+		valueTypes: []string{},
+		columns:    SheetColumns{},
+		paths:      []columnPath{},
+		primaryKey: -1,
+	}
+}
+
+// sheetHeader reads the header rows of the sheet and builds nested columns from column paths of keys
+func (c *Converter) sheetHeader(sheet *xlsx.Sheet) (*sheetHeader, error) {
+	keys, err := c.sheetKeys(sheet)
+	if err != nil {
+		return nil, err
+	}
+
+	return c.newSheetHeader(sheet.Name, keys, cellValues(c.rowCells(sheet, config.ExcelFormatRowTypeValueType)),
+		cellValues(c.rowCells(sheet, config.ExcelFormatRowTypeComment)))
+}
+
+// newSheetHeader builds the header from values of the key, value-type and comment rows
+func (c *Converter) newSheetHeader(sheetName string, keys []string, valueTypeRow []string, commentRow []string) (*sheetHeader, error) {
+	valueTypes := make([]string, len(keys))
+	copy(valueTypes, valueTypeRow)
+	primaryKey, err := c.sheetPrimaryKey(sheetName, keys, valueTypes)
+	if err != nil {
+		return nil, err
+	}
+	comments := make([]string, len(keys))
+	copy(comments, commentRow)
+
+	columns, paths, err := buildSheetColumns(keys, valueTypes, comments, primaryKey)
+	if err != nil {
+		convertErr := err.(*ConvertError)
+		convertErr.Sheet = sheetName
+		if keyExcelFormat, err := c.config.GetExcelFormatByRowType(config.ExcelFormatRowTypeKey); err == nil {
+			convertErr.Row = keyExcelFormat.RowLine
+		}
+		return nil, convertErr
+	}
+
+	return &sheetHeader{
+		keys:       keys,
+		valueTypes: valueTypes,
+		columns:    columns,
+		paths:      paths,
+		primaryKey: primaryKey,
+	}, nil
+}
+
+// sheetPrimaryKey finds the primary key column declared by the value type with "!" suffix or the config,
+// and removes the suffix from valueTypes. It returns -1 if the sheet has no primary key.
+func (c *Converter) sheetPrimaryKey(sheetName string, keys []string, valueTypes []string) (int, error) {
+	valueTypeLine := 0
+	if excelFormat, err := c.config.GetExcelFormatByRowType(config.ExcelFormatRowTypeValueType); err == nil {
+		valueTypeLine = excelFormat.RowLine
+	}
+
+	primaryKey := -1
+	for j, valueType := range valueTypes {
+		t, ok := parsePrimaryKeyValueType(valueType)
+		if !ok {
+			continue
+		}
+		valueTypes[j] = t
+		if primaryKey >= 0 {
+			return -1, &ConvertError{Sheet: sheetName, Row: valueTypeLine, Column: j + 1,
+				Err: fmt.Errorf("primary key is declared more than once. %s and %s", keys[primaryKey], keys[j])}
+		}
+		primaryKey = j
+	}
+
+	if column, ok := c.config.PrimaryKeys[sheetName]; ok {
+		configured := -1
+		for j, key := range keys {
+			if key == column {
+				configured = j
+				break
+			}
+		}
+		if configured < 0 {
+			return -1, &ConvertError{Sheet: sheetName, Err: fmt.Errorf("primary key column %s is not found", column)}
+		}
+		if primaryKey >= 0 && primaryKey != configured {
+			return -1, &ConvertError{Sheet: sheetName, Row: valueTypeLine, Column: primaryKey + 1,
+				Err: fmt.Errorf("primary key %s conflicts with %s in config", keys[primaryKey], column)}
+		}
+		primaryKey = configured
+	}
+
+	if primaryKey >= 0 && (isArrayValueType(valueTypes[primaryKey]) || strings.Contains(keys[primaryKey], "[")) {
+		return -1, &ConvertError{Sheet: sheetName, Row: valueTypeLine, Column: primaryKey + 1,
+			Err: fmt.Errorf("primary key %s should not be an array", keys[primaryKey])}
+	}
+	return primaryKey, nil
+}
+
+// cellValues returns stored values of cells
+func cellValues(cells []*xlsx.Cell) []string {
+	ret := make([]string, len(cells))
+	for i, cell := range cells {
+		ret[i] = cell.Value
+	}
+	return ret
+}
+
+// sheetColumnOrder returns column names of the sheet in order of columns
+func (c *Converter) sheetColumnOrder(sheet *xlsx.Sheet) []string {
+	keys, err := c.sheetKeys(sheet)
+	if err != nil {
+		return []string{}
+	}
+	return keys
+}
+
+// cellValue gets the raw string of the cell for conversion into the value type.
+// String columns use the formatted value as shown in Excel, the others use the stored value.
+func (c *Converter) cellValue(cell *xlsx.Cell, valueType string) (string, error) {
+	if elementType, _, ok := ParseArrayValueType(valueType); ok && elementType == ValueTypeString {
+		return cell.String()
+	}
+	if !isStringValueType(valueType) {
+		return cell.Value, nil
+	}
+	return cell.String()
+}
+
+// ConvertWorkbook converts an opened xlsx workbook into XlsxMap on memory
+func (c *Converter) ConvertWorkbook(xFile *xlsx.File) (XlsxMap, error) {
+	return c.xlsx2Map(context.Background(), xFile)
+}
+
+// ConvertWorkbookIntoHeader converts an opened xlsx workbook into XlsxHeaderMap on memory
+func (c *Converter) ConvertWorkbookIntoHeader(xFile *xlsx.File) (XlsxHeaderMap, error) {
+	return c.xlsx2HeaderMap(context.Background(), xFile)
+}
+
+// ConvertReader converts xlsx content read from r into XlsxMap
+func (c *Converter) ConvertReader(r io.Reader) (XlsxMap, error) {
+	xFile, err := openXlsxReader(r)
+	if err != nil {
+		return nil, err
+	}
+	return c.xlsx2Map(context.Background(), xFile)
+}
+
+// ConvertReaderIntoHeader converts xlsx content read from r into XlsxHeaderMap
+func (c *Converter) ConvertReaderIntoHeader(r io.Reader) (XlsxHeaderMap, error) {
+	xFile, err := openXlsxReader(r)
+	if err != nil {
+		return nil, err
+	}
+	return c.xlsx2HeaderMap(context.Background(), xFile)
+}
+
+func openXlsxReader(r io.Reader) (*xlsx.File, error) {
+	if ra, ok := r.(*bytes.Reader); ok {
+		return xlsx.OpenReaderAt(ra, ra.Size())
+	}
+
+	bs, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	return xlsx.OpenBinary(bs)
+}
+
+// WriteJSON writes v such as XlsxMap or XlsxHeaderMap into w as json
+func WriteJSON(w io.Writer, v interface{}) error {
+	return json.NewEncoder(w).Encode(v)
+}
+
+func (c *Converter) xlsx2Map(ctx context.Context, xFile *xlsx.File) (XlsxMap, error) {
+	results, err := c.convertSheets(ctx, xFile, func(sheet *xlsx.Sheet) (interface{}, error) {
+		return c.sheet2Map(sheet)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	resultJSON := XlsxMap{}
+	for i, s := range xFile.Sheets {
+		resultJSON[s.Name] = results[i].(SheetDataList)
+	}
+	return resultJSON, nil
+}
+
+// convertSheets converts sheets of the xlsx file by proc with sheet workers of the config under ctx,
+// and returns the results in order of sheets regardless of the finished order of workers.
+// The error is of the first failed sheet in order of sheets as well as converting sheets one by one.
+func (c *Converter) convertSheets(ctx context.Context, xFile *xlsx.File, proc func(sheet *xlsx.Sheet) (interface{}, error)) ([]interface{}, error) {
+	sheetNames := make([]string, len(xFile.Sheets))
+	sheets := make(map[string]*xlsx.Sheet, len(xFile.Sheets))
+	for i, s := range xFile.Sheets {
+		sheetNames[i] = s.Name
+		sheets[s.Name] = s
+	}
+
+	results, err := DispatchConcurrencyWorkers(ctx, sheetNames, WorkerOptions{Jobs: c.sheetJobs(ctx)}, func(ctx context.Context, sheetName string) (interface{}, error) {
+		return proc(sheets[sheetName])
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	ret := make([]interface{}, len(results))
+	for i, result := range results {
+		ret[i] = result.Data
+	}
+	return ret, nil
+}
+
+// fileJobsKey is the context key of the number of workers which convert xlsx files concurrently
+type fileJobsKey struct{}
+
+// withFileJobs returns the context of file workers whose number is jobs
+func withFileJobs(ctx context.Context, jobs int) context.Context {
+	return context.WithValue(ctx, fileJobsKey{}, jobs)
+}
+
+// sheetJobs returns the number of sheet workers of the config.
+// When it is not configured and xlsx files are already converted by multiple workers,
+// sheets are converted one by one so that the number of goroutines is not multiplied by the number of cpu.
+func (c *Converter) sheetJobs(ctx context.Context) int {
+	if fileJobs, ok := ctx.Value(fileJobsKey{}).(int); ok && fileJobs > 1 && c.config.SheetJobs == 0 {
+		return 1
+	}
+	return c.config.SheetJobs
+}
+
+// sheet2HeaderMap converts the header of the sheet into columns, and an empty sheet has no columns
+func (c *Converter) sheet2HeaderMap(sheet *xlsx.Sheet) (SheetColumns, error) {
+	if len(sheet.Rows) == 0 {
+		return emptySheetHeader().columns, nil
+	}
+
+	header, err := c.sheetHeader(sheet)
+	if err != nil {
+		return nil, err
+	}
+
+	if valueTypeExcelFormat, err := c.config.GetExcelFormatByRowType(config.ExcelFormatRowTypeValueType); err == nil &&
+		valueTypeExcelFormat.RowLine > len(sheet.Rows) {
+		return nil, &ConvertError{Sheet: sheet.Name, Row: valueTypeExcelFormat.RowLine, Err: fmt.Errorf("value-type row is not found")}
+	}
+	return header.columns, nil
+}
+
+func (c *Converter) xlsx2HeaderMap(ctx context.Context, xFile *xlsx.File) (XlsxHeaderMap, error) {
+	results, err := c.convertSheets(ctx, xFile, func(sheet *xlsx.Sheet) (interface{}, error) {
+		return c.sheet2HeaderMap(sheet)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	ret := XlsxHeaderMap{}
+	for i, s := range xFile.Sheets {
+		ret[s.Name] = results[i].(SheetColumns)
+	}
+	return ret, nil
+}
+
+// convertXlsxFileIntoHeader converts a xlsx file into XlsxHeaderMap.
+// A file which cannot be opened as xlsx is an error.
+func (c *Converter) convertXlsxFileIntoHeader(ctx context.Context, filename string) (XlsxHeaderMap, error) {
+	xlsxFile, err := xlsx.OpenFile(filename)
+	if err != nil {
+		return XlsxHeaderMap{}, &ConvertError{File: filename, Err: err}
+	}
+
+	ret, err := c.xlsx2HeaderMap(ctx, xlsxFile)
+	return ret, withFile(err, filename)
+}
+
+// convertXlsxFile converts a xlsx file into XlsxMap with column order of each sheet.
+// A file which cannot be read or opened as xlsx is an error.
+// If the cache is enabled, a file which has the same content as before is loaded from the cache.
+func (c *Converter) convertXlsxFile(ctx context.Context, filename string) (workbook, error) {
+	book := workbook{file: filename, sheets: XlsxMap{}, columns: map[string][]string{}, primaryKeys: map[string]string{}}
+
+	content, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return book, &ConvertError{File: filename, Err: err}
+	}
+	if c.cache != nil {
+		if cached, ok := c.cache.load(filename, content); ok {
+			logger.Log("cached", filename)
+			return cached, nil
+		}
+	}
+
+	xlsxFile, err := xlsx.OpenBinary(content)
+	if err != nil {
+		return book, &ConvertError{File: filename, Err: err}
+	}
+
+	book, err = c.xlsx2Workbook(ctx, filename, xlsxFile)
+	if err != nil {
+		return book, err
+	}
+
+	if c.cache != nil {
+		if err := c.cache.store(content, book); err != nil {
+			logger.Log("warning", fmt.Sprintf("failed to write cache of %s: %s", filename, err))
+		}
+	}
+	return book, nil
+}
+
+// xlsx2Workbook converts sheets of an opened xlsx file into workbook with sheet workers
+func (c *Converter) xlsx2Workbook(ctx context.Context, filename string, xlsxFile *xlsx.File) (workbook, error) {
+	book := workbook{file: filename, sheets: XlsxMap{}, columns: map[string][]string{}, primaryKeys: map[string]string{}}
+	results, err := c.convertSheets(ctx, xlsxFile, func(sheet *xlsx.Sheet) (interface{}, error) {
+		header, rows, err := c.sheetRows(sheet)
+		return convertedSheet{header: header, rows: rows}, err
+	})
+	if err != nil {
+		return book, withFile(err, filename)
+	}
+
+	for i, s := range xlsxFile.Sheets {
+		converted := results[i].(convertedSheet)
+		rows := make(SheetDataList, len(converted.rows))
+		for j, row := range converted.rows {
+			rows[j] = row.values
+		}
+		book.sheets[s.Name] = rows
+		book.columns[s.Name] = c.sheetColumnOrder(s)
+		if column := converted.header.primaryKeyColumn(); column != "" {
+			book.primaryKeys[s.Name] = column
+		}
+	}
+	return book, nil
+}
+
+// convertedSheet is the result of sheetRows
+type convertedSheet struct {
+	header *sheetHeader
+	rows   []sheetRow
+}
+
+// traversalInputFiles returns xlsx files in order of inputs, and files in a directory are in lexical order
+func (c *Converter) traversalInputFiles(inputDirsOrFiles []string) ([]string, error) {
+	ret := []string{}
+	for _, inputDirOrFile := range inputDirsOrFiles {
+		fi, err := os.Stat(inputDirOrFile)
+		if err != nil {
+			return nil, err
+		}
+
+		if fi.IsDir() {
+			err := filepath.Walk(inputDirOrFile, func(path string, info os.FileInfo, err error) error {
+				if err != nil {
+					return err
+				}
+				if !info.IsDir() && c.isExcelFile(path) {
+					ret = append(ret, path)
+				}
+				return nil
+			})
+			if err != nil {
+				return nil, err
+			}
+			continue
+		}
+
+		if c.isExcelFile(inputDirOrFile) {
+			ret = append(ret, inputDirOrFile)
+		}
+	}
+	return ret, nil
+}
+
+func (c *Converter) isExcelFile(filename string) bool {
+	if isExcelLockFile(filename) {
+		return false
+	}
+	targetExt := filepath.Ext(filename)
+	for _, ext := range c.config.ExcelExts {
+		if targetExt == ext {
+			return true
+		}
+	}
+	return false
+}
+
+// outputTarget is the destination of converted data
+type outputTarget struct {
+	path       string
+	isMultiple bool
+	format     string
+}
+
+// newOutputTarget decides the output format.
+// The format of config is used if it is set. Otherwise it is detected from the extension of the output file,
+// or of the file name template in multiple output mode. Json is used when it cannot be detected.
+func (c *Converter) newOutputTarget(output string, isMultipleOutput bool) (*outputTarget, error) {
+	format := c.config.Output.Format
+	if format == "" {
+		fileName := output
+		if isMultipleOutput {
+			fileName = c.config.Output.FileName
+		}
+		if detected, ok := FormatFromFileName(fileName); ok {
+			format = detected
+		} else {
+			format = FormatJSON
+		}
+	}
+
+	if _, ok := encoders[format]; !ok {
+		return nil, fmt.Errorf("unknown output format: %s", format)
+	}
+	return &outputTarget{
+		path:       output,
+		isMultiple: isMultipleOutput,
+		format:     format,
+	}, nil
+}
+
+// encoder creates the encoder of the output format
+func (c *Converter) encoder(out *outputTarget, columnOrder map[string][]string, primaryKeys map[string]string) (Encoder, error) {
+	return GetEncoder(out.format, EncoderOptions{
+		Output:      c.config.Output,
+		ColumnOrder: columnOrder,
+		PrimaryKeys: primaryKeys,
+	})
+}
+
+// outputFilePath builds the output file path of the sheet in --multiple-output mode
+func (c *Converter) outputFilePath(out *outputTarget, inputFile string, sheetName string) string {
+	book := strings.TrimSuffix(filepath.Base(inputFile), filepath.Ext(inputFile))
+	ext := strings.TrimPrefix(FormatExt(out.format), ".")
+	fileName := strings.NewReplacer("{book}", book, "{sheet}", sheetName, "{ext}", ext).Replace(c.config.Output.FileName)
+	return filepath.Join(out.path, fileName)
+}
+
+// writeFile writes into outputFile by write function, creating missing directories
+func (c *Converter) writeFile(outputFile string, write func(w io.Writer) error) error {
+	if err := os.MkdirAll(filepath.Dir(outputFile), 0755); err != nil {
+		return err
+	}
+
+	f, err := os.Create(outputFile)
+	if err != nil {
+		return err
+	}
+	if err := write(f); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+
+	logger.Log("created", outputFile)
+	return nil
+}
+
+// writeFileAtomically writes into a temporary file in the directory of outputFile by write function,
+// and renames it to outputFile on success, so that outputFile is not left partially written
+func (c *Converter) writeFileAtomically(outputFile string, write func(w io.Writer) error) error {
+	if err := os.MkdirAll(filepath.Dir(outputFile), 0755); err != nil {
+		return err
+	}
+
+	f, err := ioutil.TempFile(filepath.Dir(outputFile), "."+filepath.Base(outputFile)+".tmp-")
+	if err != nil {
+		return err
+	}
+	if err := write(f); err != nil {
+		f.Close()
+		os.Remove(f.Name())
+		return err
+	}
+	if err := f.Chmod(0644); err != nil {
+		f.Close()
+		os.Remove(f.Name())
+		return err
+	}
+	if err := f.Close(); err != nil {
+		os.Remove(f.Name())
+		return err
+	}
+	if err := os.Rename(f.Name(), outputFile); err != nil {
+		os.Remove(f.Name())
+		return err
+	}
+
+	logger.Log("created", outputFile)
+	return nil
+}
+
+// writeSheetFile writes a sheet into outputFile by the encoder
+func (c *Converter) writeSheetFile(encoder Encoder, outputFile string, sheetName string, v interface{}) error {
+	return c.writeFile(outputFile, func(w io.Writer) error {
+		return encoder.EncodeSheet(w, sheetName, v)
+	})
+}
+
+// writeWorkbooksEachSheet writes each sheet of xlsx files into its own file.
+// If changed is not nil, only files which have a sheet of the changed xlsx files are written.
+func (c *Converter) writeWorkbooksEachSheet(out *outputTarget, books []workbook, changed map[string]bool) error {
+	names, duplicates, err := c.resolveSheetNames(workbookSheetSources(books), func(src sheetSource, sheetName string) string {
+		return c.outputFilePath(out, src.file, sheetName)
+	})
+	if err != nil {
+		return err
+	}
+	c.logDuplicateSheets(duplicates)
+
+	outputFiles := []string{}
+	outputSheetNames := map[string]string{}
+	outputs := map[string]SheetDataList{}
+	columnOrder := map[string][]string{}
+	primaryKeys := map[string]string{}
+	outputChanged := map[string]bool{}
+	i := 0
+	for _, book := range books {
+		for _, sheetName := range sortedSheetNames(book.sheets) {
+			outputFile := c.outputFilePath(out, book.file, names[i])
+			if changed == nil || changed[book.file] {
+				outputChanged[outputFile] = true
+			}
+			if _, ok := outputs[outputFile]; !ok {
+				outputFiles = append(outputFiles, outputFile)
+				outputSheetNames[outputFile] = names[i]
+				outputs[outputFile] = SheetDataList{}
+			} else if err := appendablePrimaryKey(primaryKeys, names[i], book.primaryKeys[sheetName], outputs[outputFile], book.sheets[sheetName]); err != nil {
+				return &ConvertError{File: book.file, Sheet: sheetName, Err: err}
+			}
+			outputs[outputFile] = append(outputs[outputFile], book.sheets[sheetName]...)
+			columnOrder[names[i]] = mergeColumnOrder(columnOrder[names[i]], book.columns[sheetName])
+			if column, ok := book.primaryKeys[sheetName]; ok {
+				primaryKeys[names[i]] = column
+			}
+			i++
+		}
+	}
+
+	encoder, err := c.encoder(out, columnOrder, primaryKeys)
+	if err != nil {
+		return err
+	}
+	for _, outputFile := range outputFiles {
+		if !outputChanged[outputFile] {
+			continue
+		}
+		if err := c.writeSheetFile(encoder, outputFile, outputSheetNames[outputFile], outputs[outputFile]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// writeWorkbookHeadersEachSheet writes header of each sheet of xlsx files into its own file
+func (c *Converter) writeWorkbookHeadersEachSheet(out *outputTarget, books []workbookHeader) error {
+	names, duplicates, err := c.resolveSheetNames(workbookHeaderSheetSources(books), func(src sheetSource, sheetName string) string {
+		return c.outputFilePath(out, src.file, sheetName)
+	})
+	if err != nil {
+		return err
+	}
+	c.logDuplicateSheets(duplicates)
+
+	outputFiles := []string{}
+	outputSheetNames := map[string]string{}
+	outputs := map[string]SheetColumns{}
+	i := 0
+	for _, book := range books {
+		for _, sheetName := range sortedHeaderSheetNames(book.sheets) {
+			outputFile := c.outputFilePath(out, book.file, names[i])
+			if _, ok := outputs[outputFile]; !ok {
+				outputFiles = append(outputFiles, outputFile)
+				outputSheetNames[outputFile] = names[i]
+			}
+			merged, err := mergeSheetColumns(outputs[outputFile], book.sheets[sheetName])
+			if err != nil {
+				return &ConvertError{File: book.file, Sheet: sheetName, Err: err}
+			}
+			outputs[outputFile] = merged
+			i++
+		}
+	}
+
+	encoder, err := c.encoder(out, nil, nil)
+	if err != nil {
+		return err
+	}
+	for _, outputFile := range outputFiles {
+		if err := c.writeSheetFile(encoder, outputFile, outputSheetNames[outputFile], outputs[outputFile]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// writeWorkbooks writes converted xlsx files into one file or files of each sheet
+func (c *Converter) writeWorkbooks(out *outputTarget, books []workbook) error {
+	if out.isMultiple {
+		return c.writeWorkbooksEachSheet(out, books, nil)
+	}
+
+	merged, duplicates, err := c.mergeWorkbooks(books)
+	if err != nil {
+		return err
+	}
+	c.logDuplicateSheets(duplicates)
+
+	encoder, err := c.encoder(out, merged.columns, merged.primaryKeys)
+	if err != nil {
+		return err
+	}
+	return c.writeFile(out.path, func(w io.Writer) error {
+		return encoder.Encode(w, merged.sheets)
+	})
+}
+
+// writeWorkbookHeaders writes converted headers of xlsx files into one file or files of each sheet
+func (c *Converter) writeWorkbookHeaders(out *outputTarget, books []workbookHeader) error {
+	if out.isMultiple {
+		return c.writeWorkbookHeadersEachSheet(out, books)
+	}
+
+	result, duplicates, err := c.mergeWorkbookHeaders(books)
+	if err != nil {
+		return err
+	}
+	c.logDuplicateSheets(duplicates)
+
+	encoder, err := c.encoder(out, nil, nil)
+	if err != nil {
+		return err
+	}
+	return c.writeFile(out.path, func(w io.Writer) error {
+		return encoder.Encode(w, result)
+	})
+}
+
+// ConvertConcurrency executes as the same logic as Convert in concurrently
+// The result does not depend on the order of finished workers.
+func (c *Converter) ConvertConcurrency(inputDirsOrFiles []string, output string, isMultipleOutput bool) error {
+	return c.ConvertConcurrencyContext(context.Background(), inputDirsOrFiles, output, isMultipleOutput, WorkerOptions{})
+}
+
+// ConvertConcurrencyContext is ConvertConcurrency with the context and options of workers.
+// Nothing is written when some of xlsx files fail, and the error is WorkerErrors of all failures in keep going mode.
+func (c *Converter) ConvertConcurrencyContext(ctx context.Context, inputDirsOrFiles []string, output string, isMultipleOutput bool, opts WorkerOptions) error {
+	out, err := c.newOutputTarget(output, isMultipleOutput)
+	if err != nil {
+		return err
+	}
+
+	il, err := c.traversalInputFiles(inputDirsOrFiles)
+	if err != nil {
+		return err
+	}
+
+	results, err := DispatchConcurrencyWorkers(withFileJobs(ctx, opts.jobs(len(il))), il, opts, func(ctx context.Context, path string) (interface{}, error) {
+		return c.convertXlsxFile(ctx, path)
+	})
+	if err != nil {
+		return err
+	}
+
+	books := make([]workbook, len(results))
+	for i, result := range results {
+		books[i] = result.Data.(workbook)
+	}
+	return c.writeWorkbooks(out, books)
+}
+
+// Convert executes convertion from xlsx files or directories into json file(s)
+// In multiple output mode, output is a directory and each sheet is written into its own file.
+// The output format is decided by config or the extension of output.
+// Duplicate sheet names across xlsx files are treated by the duplicate sheet policy of config.
+// The output is deterministic, and it is the same bytes as ConvertConcurrency for the same input.
+// The returned error is *ConvertError when it is occurred in a xlsx file.
+func (c *Converter) Convert(inputDirsOrFiles []string, output string, isMultipleOutput bool) error {
+	out, err := c.newOutputTarget(output, isMultipleOutput)
+	if err != nil {
+		return err
+	}
+
+	inputFiles, err := c.traversalInputFiles(inputDirsOrFiles)
+	if err != nil {
+		return err
+	}
+
+	books := make([]workbook, 0, len(inputFiles))
+	for _, inputFile := range inputFiles {
+		book, err := c.convertXlsxFile(context.Background(), inputFile)
+		if err != nil {
+			return err
+		}
+		books = append(books, book)
+	}
+	return c.writeWorkbooks(out, books)
+}
+
+// ConvertIntoHeader executes convertion from xlsx files or directories into header only json file(s)
+func (c *Converter) ConvertIntoHeader(inputDirsOrFiles []string, output string, isMultipleOutput bool) error {
+	out, err := c.newOutputTarget(output, isMultipleOutput)
+	if err != nil {
+		return err
+	}
+
+	inputFiles, err := c.traversalInputFiles(inputDirsOrFiles)
+	if err != nil {
+		return err
+	}
+
+	books, err := c.convertXlsxFilesIntoHeader(inputFiles)
+	if err != nil {
+		return err
+	}
+	return c.writeWorkbookHeaders(out, books)
+}
+
+// ConvertIntoHeaderConcurrency executes as the same logic as ConvertIntoHeader in concurrently
+func (c *Converter) ConvertIntoHeaderConcurrency(inputDirsOrFiles []string, output string, isMultipleOutput bool) error {
+	return c.ConvertIntoHeaderConcurrencyContext(context.Background(), inputDirsOrFiles, output, isMultipleOutput, WorkerOptions{})
+}
+
+// ConvertIntoHeaderConcurrencyContext is ConvertIntoHeaderConcurrency with the context and options of workers
+func (c *Converter) ConvertIntoHeaderConcurrencyContext(ctx context.Context, inputDirsOrFiles []string, output string, isMultipleOutput bool, opts WorkerOptions) error {
+	out, err := c.newOutputTarget(output, isMultipleOutput)
+	if err != nil {
+		return err
+	}
+
+	inputFiles, err := c.traversalInputFiles(inputDirsOrFiles)
+	if err != nil {
+		return err
+	}
+
+	results, err := DispatchConcurrencyWorkers(withFileJobs(ctx, opts.jobs(len(inputFiles))), inputFiles, opts, func(ctx context.Context, path string) (interface{}, error) {
+		converted, err := c.convertXlsxFileIntoHeader(ctx, path)
+		return workbookHeader{file: path, sheets: converted}, err
+	})
+	if err != nil {
+		return err
+	}
+
+	books := make([]workbookHeader, len(results))
+	for i, result := range results {
+		books[i] = result.Data.(workbookHeader)
+	}
+	return c.writeWorkbookHeaders(out, books)
+}
+
+// ReadHeader reads headers of xlsx files or directories and merges them into one XlsxHeaderMap
+// by the duplicate sheet policy.
+func (c *Converter) ReadHeader(inputDirsOrFiles []string) (XlsxHeaderMap, error) {
+	inputFiles, err := c.traversalInputFiles(inputDirsOrFiles)
+	if err != nil {
+		return nil, err
+	}
+
+	books, err := c.convertXlsxFilesIntoHeader(inputFiles)
+	if err != nil {
+		return nil, err
+	}
+
+	result, duplicates, err := c.mergeWorkbookHeaders(books)
+	if err != nil {
+		return nil, err
+	}
+	c.logDuplicateSheets(duplicates)
+	return result, nil
+}
+
+func (c *Converter) convertXlsxFilesIntoHeader(inputFiles []string) ([]workbookHeader, error) {
+	books := make([]workbookHeader, 0, len(inputFiles))
+	for _, inputFile := range inputFiles {
+		converted, err := c.convertXlsxFileIntoHeader(context.Background(), inputFile)
+		if err != nil {
+			return nil, err
+		}
+		books = append(books, workbookHeader{file: inputFile, sheets: converted})
+	}
+	return books, nil
+}
+
+// NewConverter creates new Converter instance
+// The default config is used when conf is nil.
+func NewConverter(conf *config.Config) (*Converter, error) {
+	var c *config.Config
+	if conf == nil {
+		c = config.DefaultConfig
+	} else {
+		c = conf
+	}
+
+	if err := c.Verify(); err != nil {
+		return nil, err
+	}
+	if _, err := c.GetExcelFormatByRowType(config.ExcelFormatRowTypeKey); err != nil {
+		return nil, err
+	}
+
+	ret := &Converter{
+		config: c,
+	}
+	if c.CacheDir != "" {
+		cache, err := newWorkbookCache(c.CacheDir, c)
+		if err != nil {
+			return nil, err
+		}
+		ret.cache = cache
+	}
+	return ret, nil
+}
@@ -0,0 +1,1190 @@
+package cxtj
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"path"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/tealeg/xlsx"
+
+	"github.com/kama2vern/cxtj/config"
+)
+
+func TestConvertFromOneXlsxIntoOneJson(t *testing.T) {
+	dir, _ := os.Getwd()
+	inputFiles := []string{
+		path.Join(dir, "..", "test", "excels", "convert_test.xlsx"),
+	}
+	outputFile := path.Join(dir, "..", "test", "output", "convert_test.json")
+
+	c, err := NewConverter(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Convert(inputFiles, outputFile, false); err != nil {
+		t.Fatal(err)
+	}
+
+	bytes, err := ioutil.ReadFile(outputFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	result := make(map[string][]map[string]interface{})
+	if err := json.Unmarshal(bytes, &result); err != nil {
+		log.Fatal(err)
+	}
+
+	if _, ok := result["sheet"]; !ok {
+		t.Errorf("outputed json should have a key of sheet name")
+	}
+
+	contents, _ := result["sheet"]
+	if len(contents) == 0 {
+		t.Fatalf("contents array is empty")
+	}
+
+	// numbers are typed by value-type row
+	except := map[string]interface{}{
+		"id":          float64(1),
+		"characterId": float64(1001),
+		"name":        "アルファ",
+		"hp":          float64(100),
+		"mp":          float64(50),
+		"attack":      float64(1),
+		"defense":     float64(1),
+	}
+
+	for k, v := range contents[0] {
+		if v != except[k] {
+			t.Errorf("Mismatch contents. key %s, except %v, actual %v", k, except[k], v)
+		}
+	}
+
+	exceptMaxSize := 4
+	if len(contents) != exceptMaxSize {
+		t.Errorf("Invalid contents size (has empty row data?). except %d, actual %d", exceptMaxSize, len(contents))
+	}
+}
+
+func TestConvertFromMultiXlsxIntoOneJson(t *testing.T) {
+	dir, _ := os.Getwd()
+	inputFiles := []string{
+		path.Join(dir, "..", "test", "excels", "convert_test.xlsx"),
+		path.Join(dir, "..", "test", "excels", "convert_test2.xlsx"),
+	}
+	outputFile := path.Join(dir, "..", "test", "output", "convert_test.json")
+
+	c, err := NewConverter(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Convert(inputFiles, outputFile, false); err != nil {
+		t.Fatal(err)
+	}
+
+	bytes, err := ioutil.ReadFile(outputFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	result := make(map[string][]map[string]interface{})
+	if err := json.Unmarshal(bytes, &result); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, sheetName := range []string{"sheet", "nextSheet"} {
+		if _, ok := result[sheetName]; !ok {
+			t.Errorf("outputed json should have a key of sheet name %s", sheetName)
+		}
+		contents, _ := result[sheetName]
+		if len(contents) == 0 {
+			t.Errorf("contents array is empty %s", sheetName)
+		}
+	}
+}
+
+// newValidTestXlsxDir copies xlsx files of test/excels into a temporary directory
+// except error.xlsx which cannot be opened as xlsx
+func newValidTestXlsxDir(t *testing.T) string {
+	dir, _ := os.Getwd()
+	files, err := filepath.Glob(path.Join(dir, "..", "test", "excels", "*.xlsx"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	validDir, err := ioutil.TempDir("", "cxtj")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, file := range files {
+		if path.Base(file) == "error.xlsx" {
+			continue
+		}
+		copyTestFile(t, file, path.Join(validDir, path.Base(file)))
+	}
+	return validDir
+}
+
+func TestConvertXlsxDirWithInvalidFile(t *testing.T) {
+	dir, _ := os.Getwd()
+	inputDir := []string{
+		path.Join(dir, "..", "test", "excels"),
+	}
+	outputDir, err := ioutil.TempDir("", "cxtj")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(outputDir)
+
+	c, err := NewConverter(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	isInvalidFileError := func(err error) bool {
+		convertErr, ok := err.(*ConvertError)
+		return ok && path.Base(convertErr.File) == "error.xlsx"
+	}
+	if err := c.Convert(inputDir, path.Join(outputDir, "convert.json"), false); !isInvalidFileError(err) {
+		t.Errorf("a file which cannot be opened as xlsx should be ConvertError. actual %v", err)
+	}
+	if err := c.ConvertConcurrency(inputDir, path.Join(outputDir, "concurrency.json"), false); !isInvalidFileError(err) {
+		t.Errorf("a file which cannot be opened as xlsx should be ConvertError in concurrency. actual %v", err)
+	}
+	if err := c.ConvertIntoHeader(inputDir, path.Join(outputDir, "header.json"), false); !isInvalidFileError(err) {
+		t.Errorf("a file which cannot be opened as xlsx should be ConvertError of header. actual %v", err)
+	}
+	if err := c.ConvertStream(inputDir, path.Join(outputDir, "stream.json"), false); !isInvalidFileError(err) {
+		t.Errorf("a file which cannot be opened as xlsx should be ConvertError in streaming. actual %v", err)
+	}
+	if _, err := os.Stat(path.Join(outputDir, "convert.json")); !os.IsNotExist(err) {
+		t.Errorf("output should not be written when a file cannot be opened as xlsx")
+	}
+}
+
+func TestConvertFromOneXlsxDirIntoOneJson(t *testing.T) {
+	dir, _ := os.Getwd()
+	validDir := newValidTestXlsxDir(t)
+	defer os.RemoveAll(validDir)
+	inputDir := []string{validDir}
+	outputFile := path.Join(dir, "..", "test", "output", "convert_test.json")
+
+	c, err := NewConverter(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Convert(inputDir, outputFile, false); err != nil {
+		t.Fatal(err)
+	}
+
+	bytes, err := ioutil.ReadFile(outputFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	result := make(map[string][]map[string]interface{})
+	if err := json.Unmarshal(bytes, &result); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, sheetName := range []string{"sheet", "nextSheet"} {
+		if _, ok := result[sheetName]; !ok {
+			t.Errorf("outputed json should have a key of sheet name %s", sheetName)
+		}
+		contents, _ := result[sheetName]
+		if len(contents) == 0 {
+			t.Errorf("contents array is empty %s", sheetName)
+		}
+	}
+}
+
+func TestConcurrencyConvertFromOneXlsxDirIntoOneJson(t *testing.T) {
+	dir, _ := os.Getwd()
+	validDir := newValidTestXlsxDir(t)
+	defer os.RemoveAll(validDir)
+	inputDir := []string{validDir}
+	outputFile := path.Join(dir, "..", "test", "output", "convert_test.json")
+
+	c, err := NewConverter(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := c.ConvertConcurrency(inputDir, outputFile, false); err != nil {
+		t.Fatal(err)
+	}
+
+	bytes, err := ioutil.ReadFile(outputFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	result := make(map[string][]map[string]interface{})
+	if err := json.Unmarshal(bytes, &result); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, sheetName := range []string{"sheet", "nextSheet"} {
+		if _, ok := result[sheetName]; !ok {
+			t.Errorf("outputed json should have a key of sheet name %s", sheetName)
+		}
+		contents, _ := result[sheetName]
+		if len(contents) == 0 {
+			t.Errorf("contents array is empty %s", sheetName)
+		}
+	}
+}
+
+func TestConvertFromOneXlsxIntoOneJsonOnlyHeader(t *testing.T) {
+	dir, _ := os.Getwd()
+	inputFiles := []string{
+		path.Join(dir, "..", "test", "excels", "convert_test.xlsx"),
+	}
+	outputFile := path.Join(dir, "..", "test", "output", "convert_test.json")
+
+	c, err := NewConverter(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := c.ConvertIntoHeader(inputFiles, outputFile, false); err != nil {
+		t.Fatal(err)
+	}
+
+	bytes, err := ioutil.ReadFile(outputFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	result := make(XlsxHeaderMap)
+	if err := json.Unmarshal(bytes, &result); err != nil {
+		log.Fatal(err)
+	}
+
+	if _, ok := result["sheet"]; !ok {
+		t.Errorf("outputed json should have a key of sheet name")
+	}
+
+	headerInfo := result["sheet"]
+
+	except := map[string]ColumnInfo{
+		"id": ColumnInfo{
+			Index:     0,
+			ValueType: "int",
+		},
+		"characterId": ColumnInfo{
+			Index:     1,
+			ValueType: "int",
+		},
+		"name": ColumnInfo{
+			Index:     2,
+			ValueType: "string",
+		},
+		"hp": ColumnInfo{
+			Index:     3,
+			ValueType: "long",
+		},
+		"mp": ColumnInfo{
+			Index:     4,
+			ValueType: "long",
+		},
+		"attack": ColumnInfo{
+			Index:     5,
+			ValueType: "int",
+		},
+		"defense": ColumnInfo{
+			Index:     6,
+			ValueType: "int",
+		},
+	}
+
+	for k1, v1 := range except {
+		if _, ok := headerInfo[k1]; !ok {
+			t.Errorf("column not found: %s", k1)
+		}
+
+		if v1.Index != headerInfo[k1].Index {
+			t.Errorf("invalid column info. column name: %s, attribute: Index, expect: %d, actual: %d", k1, v1.Index, headerInfo[k1].Index)
+		}
+		if v1.ValueType != headerInfo[k1].ValueType {
+			t.Errorf("invalid column info. column name: %s, attribute: ValueType, expect: %s, actual: %s", k1, v1.ValueType, headerInfo[k1].ValueType)
+		}
+	}
+}
+
+func TestConvertFromMultiXlsxIntoMultipleJson(t *testing.T) {
+	dir, _ := os.Getwd()
+	inputFiles := []string{
+		path.Join(dir, "..", "test", "excels", "convert_test.xlsx"),
+		path.Join(dir, "..", "test", "excels", "convert_test2.xlsx"),
+	}
+	outputDir, err := ioutil.TempDir("", "cxtj")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(outputDir)
+
+	conf := *config.DefaultConfig
+	conf.Output.FileName = "{book}/{sheet}.json"
+
+	c, err := NewConverter(&conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Convert(inputFiles, outputDir, true); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, outputFile := range []string{
+		path.Join(outputDir, "convert_test", "sheet.json"),
+		path.Join(outputDir, "convert_test2", "nextSheet.json"),
+	} {
+		bytes, err := ioutil.ReadFile(outputFile)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var contents []map[string]interface{}
+		if err := json.Unmarshal(bytes, &contents); err != nil {
+			t.Fatal(err)
+		}
+		if len(contents) != 4 {
+			t.Errorf("Invalid contents size of %s. except %d, actual %d", outputFile, 4, len(contents))
+		}
+	}
+}
+
+func TestConcurrencyConvertFromOneXlsxDirIntoMultipleJson(t *testing.T) {
+	validDir := newValidTestXlsxDir(t)
+	defer os.RemoveAll(validDir)
+	inputDir := []string{validDir}
+	outputDir, err := ioutil.TempDir("", "cxtj")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(outputDir)
+
+	c, err := NewConverter(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := c.ConvertConcurrency(inputDir, outputDir, true); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, sheetName := range []string{"sheet", "nextSheet", "heavy1", "master"} {
+		if _, err := os.Stat(path.Join(outputDir, sheetName+".json")); err != nil {
+			t.Errorf("json file of sheet %s is not written: %s", sheetName, err)
+		}
+	}
+}
+
+func TestConvertIntoMultipleJsonOnlyHeader(t *testing.T) {
+	dir, _ := os.Getwd()
+	inputFiles := []string{
+		path.Join(dir, "..", "test", "excels", "convert_test.xlsx"),
+	}
+	outputDir, err := ioutil.TempDir("", "cxtj")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(outputDir)
+
+	c, err := NewConverter(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := c.ConvertIntoHeader(inputFiles, outputDir, true); err != nil {
+		t.Fatal(err)
+	}
+
+	bytes, err := ioutil.ReadFile(path.Join(outputDir, "sheet.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	result := make(SheetColumns)
+	if err := json.Unmarshal(bytes, &result); err != nil {
+		t.Fatal(err)
+	}
+	if result["name"].ValueType != "string" {
+		t.Errorf("invalid column info. column name: name, attribute: ValueType, expect: string, actual: %s", result["name"].ValueType)
+	}
+}
+
+func newTestXlsxFile(t *testing.T, sheetName string, rows [][]string) *xlsx.File {
+	return newTestXlsxWorkbook(t, testSheet{sheetName, rows})
+}
+
+// testSheet is the name and rows of a sheet of newTestXlsxWorkbook
+type testSheet struct {
+	name string
+	rows [][]string
+}
+
+// newTestXlsxWorkbook creates a xlsx workbook of the sheets whose cells are strings
+func newTestXlsxWorkbook(t testing.TB, sheets ...testSheet) *xlsx.File {
+	f := xlsx.NewFile()
+	for _, s := range sheets {
+		sheet, err := f.AddSheet(s.name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, values := range s.rows {
+			row := sheet.AddRow()
+			for _, v := range values {
+				row.AddCell().SetString(v)
+			}
+		}
+	}
+	return f
+}
+
+func TestConvertEmptySheetIntoHeader(t *testing.T) {
+	f := newTestXlsxFile(t, "empty", nil)
+
+	c, err := NewConverter(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m, err := c.xlsx2HeaderMap(context.Background(), f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if columns, ok := m["empty"]; !ok || len(columns) != 0 {
+		t.Errorf("empty sheet should have an empty header. actual %v", m)
+	}
+
+	header, err := c.streamSheetHeader("empty", map[int][]string{}, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(header.keys) != 0 || len(header.columns) != 0 {
+		t.Errorf("empty sheet should have an empty header in streaming. actual %v", header)
+	}
+}
+
+func TestConvertErrorHasCellLocation(t *testing.T) {
+	f := newTestXlsxFile(t, "sheet", [][]string{
+		{"id", "hp"},
+		{"int", "int"},
+		{"ID", "HP"},
+		{"1", "100"},
+		{"2", "abc"},
+	})
+
+	c, err := NewConverter(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = c.xlsx2Map(context.Background(), f)
+	if err == nil {
+		t.Fatal("mismatched value type should be error")
+	}
+
+	convertErr, ok := err.(*ConvertError)
+	if !ok {
+		t.Fatalf("error should be *ConvertError, actual: %T", err)
+	}
+	if convertErr.Sheet != "sheet" || convertErr.Row != 5 || convertErr.Column != 2 || convertErr.Cell() != "B5" {
+		t.Errorf("invalid error location. sheet: %s, row: %d, column: %d, cell: %s", convertErr.Sheet, convertErr.Row, convertErr.Column, convertErr.Cell())
+	}
+}
+
+func TestConvertArrayValueTypes(t *testing.T) {
+	f := newTestXlsxFile(t, "sheet", [][]string{
+		{"id", "tags", "items", "rates"},
+		{"int", "string[]", "int[]", "float[|]"},
+		{"ID", "Tags", "Items", "Rates"},
+		{"1", "a;b\\;c", "1; 2", "0.5|1"},
+		{"2", "", "", ""},
+	})
+
+	conf := *config.DefaultConfig
+	conf.ArrayDelimiter = ";"
+	c, err := NewConverter(&conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	converted, err := c.ConvertWorkbook(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expect := SheetDataList{
+		{"id": int64(1), "tags": []interface{}{"a", "b;c"}, "items": []interface{}{int64(1), int64(2)}, "rates": []interface{}{float64(0.5), float64(1)}},
+		{"id": int64(2), "tags": []interface{}{}, "items": []interface{}{}, "rates": []interface{}{}},
+	}
+	if !reflect.DeepEqual(SheetDataList(converted["sheet"]), expect) {
+		t.Errorf("Mismatch contents of array value types. except %v, actual %v", expect, converted["sheet"])
+	}
+}
+
+func TestConvertArrayValueTypeErrorHasCellLocation(t *testing.T) {
+	f := newTestXlsxFile(t, "sheet", [][]string{
+		{"id", "items"},
+		{"int", "int[]"},
+		{"ID", "Items"},
+		{"1", "1,x"},
+	})
+
+	c, err := NewConverter(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = c.xlsx2Map(context.Background(), f)
+	convertErr, ok := err.(*ConvertError)
+	if !ok {
+		t.Fatalf("error should be *ConvertError, actual: %T", err)
+	}
+	if convertErr.Cell() != "B4" || !strings.Contains(convertErr.Error(), `element 2 of "1,x"`) {
+		t.Errorf("invalid error of array element: %s", convertErr)
+	}
+}
+
+func TestConvertPrimaryKey(t *testing.T) {
+	f := newTestXlsxFile(t, "sheet", [][]string{
+		{"id", "name"},
+		{"int!", "string"},
+		{"ID", "Name"},
+		{"1", "alpha"},
+		{"", ""},
+		{"2", "beta"},
+	})
+
+	c, err := NewConverter(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	converted, err := c.ConvertWorkbook(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(converted["sheet"]) != 2 || converted["sheet"][1]["id"] != int64(2) {
+		t.Errorf("value type with primary key suffix should be converted. actual %v", converted["sheet"])
+	}
+
+	header, err := c.ConvertWorkbookIntoHeader(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info := header["sheet"]["id"]; !info.PrimaryKey || info.ValueType != "int" {
+		t.Errorf("primary key column should be marked in header. actual %+v", info)
+	}
+}
+
+func TestConvertPrimaryKeyErrors(t *testing.T) {
+	cases := []struct {
+		rows        [][]string
+		primaryKeys map[string]string
+		cell        string
+		message     string
+	}{
+		{
+			[][]string{{"id", "name"}, {"int!", "string"}, {"ID", "Name"}, {"1", "a"}, {"1", "b"}},
+			nil, "A5", "duplicate primary key 1, which is also in row 4",
+		},
+		{
+			[][]string{{"id", "name"}, {"int", "string"}, {"ID", "Name"}, {"1", "a"}, {"", "b"}},
+			map[string]string{"sheet": "id"}, "A5", "primary key id is empty",
+		},
+		{
+			[][]string{{"id", "code"}, {"int!", "string!"}, {"ID", "Code"}},
+			nil, "B2", "primary key is declared more than once",
+		},
+		{
+			[][]string{{"id", "code"}, {"int!", "string"}, {"ID", "Code"}},
+			map[string]string{"sheet": "code"}, "A2", "conflicts with code in config",
+		},
+		{
+			[][]string{{"id", "tags"}, {"int", "string[]!"}, {"ID", "Tags"}},
+			nil, "B2", "should not be an array",
+		},
+		{
+			[][]string{{"id"}, {"int"}, {"ID"}},
+			map[string]string{"sheet": "code"}, "", "primary key column code is not found",
+		},
+	}
+
+	for _, tc := range cases {
+		conf := *config.DefaultConfig
+		conf.PrimaryKeys = tc.primaryKeys
+		c, err := NewConverter(&conf)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		_, err = c.ConvertWorkbook(newTestXlsxFile(t, "sheet", tc.rows))
+		convertErr, ok := err.(*ConvertError)
+		if !ok {
+			t.Errorf("error should be *ConvertError: %s, actual: %v", tc.message, err)
+			continue
+		}
+		if convertErr.Cell() != tc.cell || !strings.Contains(convertErr.Error(), tc.message) {
+			t.Errorf("invalid primary key error. expect: %s %s, actual: %s", tc.cell, tc.message, convertErr)
+		}
+	}
+}
+
+func TestNewConverterWithoutKeyRow(t *testing.T) {
+	conf := &config.Config{
+		ExcelExts: []string{".xlsx"},
+		ExcelFormats: []config.ExcelFormat{
+			config.ExcelFormat{RowType: config.ExcelFormatRowTypeValueType, RowLine: 1},
+		},
+	}
+	if _, err := NewConverter(conf); err == nil {
+		t.Error("config without key row should be error")
+	}
+}
+
+func TestConvertReaderAndWriteJSON(t *testing.T) {
+	dir, _ := os.Getwd()
+	f, err := os.Open(path.Join(dir, "..", "test", "excels", "convert_test.xlsx"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	c, err := NewConverter(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	converted, err := c.ConvertReader(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(converted["sheet"]) != 4 {
+		t.Errorf("Invalid contents size. except %d, actual %d", 4, len(converted["sheet"]))
+	}
+
+	var buf bytes.Buffer
+	if err := WriteJSON(&buf, converted); err != nil {
+		t.Fatal(err)
+	}
+
+	result := make(map[string][]map[string]interface{})
+	if err := json.Unmarshal(buf.Bytes(), &result); err != nil {
+		t.Fatal(err)
+	}
+	if result["sheet"][0]["name"] != "アルファ" {
+		t.Errorf("Mismatch contents. key name, except アルファ, actual %v", result["sheet"][0]["name"])
+	}
+}
+
+func TestConvertWorkbook(t *testing.T) {
+	f := newTestXlsxFile(t, "sheet", [][]string{
+		{"id", "name"},
+		{"int", "string"},
+		{"ID", "Name"},
+		{"1", "alpha"},
+	})
+
+	c, err := NewConverter(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	converted, err := c.ConvertWorkbook(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if converted["sheet"][0]["id"] != int64(1) || converted["sheet"][0]["name"] != "alpha" {
+		t.Errorf("Mismatch contents. actual %v", converted["sheet"][0])
+	}
+
+	header, err := c.ConvertWorkbookIntoHeader(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if header["sheet"]["name"].Index != 1 || header["sheet"]["name"].ValueType != "string" || header["sheet"]["name"].Comment != "Name" {
+		t.Errorf("invalid column info. actual %v", header["sheet"]["name"])
+	}
+}
+
+func TestConvertEmptyCell(t *testing.T) {
+	f := newTestXlsxFile(t, "sheet", [][]string{
+		{"id", "name", "hp", "rate", "enabled"},
+		{"int", "string", "long", "double", "bool"},
+		{"ID", "Name", "HP", "Rate", "Enabled"},
+		{"1", "", " ", "", ""},
+	})
+
+	for emptyCell, expect := range map[config.EmptyCell]RowMap{
+		config.EmptyCellDefault:     {"id": int64(1), "name": "", "hp": nil, "rate": nil, "enabled": nil},
+		config.EmptyCellNull:        {"id": int64(1), "name": nil, "hp": nil, "rate": nil, "enabled": nil},
+		config.EmptyCellEmptyString: {"id": int64(1), "name": "", "hp": "", "rate": "", "enabled": ""},
+		config.EmptyCellZero:        {"id": int64(1), "name": "", "hp": int64(0), "rate": float64(0), "enabled": false},
+		config.EmptyCellOmit:        {"id": int64(1)},
+	} {
+		conf := *config.DefaultConfig
+		conf.Output.EmptyCell = emptyCell
+
+		c, err := NewConverter(&conf)
+		if err != nil {
+			t.Fatal(err)
+		}
+		converted, err := c.ConvertWorkbook(f)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(RowMap(converted["sheet"][0]), expect) {
+			t.Errorf("Mismatch contents of empty cell %s. except %v, actual %v", emptyCell, expect, converted["sheet"][0])
+		}
+	}
+}
+
+func TestConvertExcelFormatLayouts(t *testing.T) {
+	for _, tc := range []struct {
+		name         string
+		excelFormats []config.ExcelFormat
+		rows         [][]string
+	}{
+		{
+			name: "key only",
+			excelFormats: []config.ExcelFormat{
+				{RowType: config.ExcelFormatRowTypeKey, RowLine: 1},
+			},
+			rows: [][]string{
+				{"id", "name"},
+				{"1", "alpha"},
+				{"2", "beta"},
+			},
+		},
+		{
+			name: "key after title row",
+			excelFormats: []config.ExcelFormat{
+				{RowType: config.ExcelFormatRowTypeComment, RowLine: 1},
+				{RowType: config.ExcelFormatRowTypeKey, RowLine: 2},
+				{RowType: config.ExcelFormatRowTypeValueType, RowLine: 3},
+			},
+			rows: [][]string{
+				{"ID", "Name"},
+				{"id", "name"},
+				{"int", "string"},
+				{"1", "alpha"},
+				{"2", "beta"},
+			},
+		},
+		{
+			name: "value type before key",
+			excelFormats: []config.ExcelFormat{
+				{RowType: config.ExcelFormatRowTypeValueType, RowLine: 1},
+				{RowType: config.ExcelFormatRowTypeKey, RowLine: 2},
+			},
+			rows: [][]string{
+				{"int", "string"},
+				{"id", "name"},
+				{"1", "alpha"},
+				{"2", "beta"},
+			},
+		},
+		{
+			name: "data start after gap",
+			excelFormats: []config.ExcelFormat{
+				{RowType: config.ExcelFormatRowTypeKey, RowLine: 1},
+				{RowType: config.ExcelFormatRowTypeValueType, RowLine: 3},
+				{RowType: config.ExcelFormatRowTypeData, RowLine: 5},
+			},
+			rows: [][]string{
+				{"id", "name"},
+				{"note", "not converted"},
+				{"int", "string"},
+				{"note", "not converted"},
+				{"1", "alpha"},
+				{"2", "beta"},
+			},
+		},
+		{
+			name: "header row between data",
+			excelFormats: []config.ExcelFormat{
+				{RowType: config.ExcelFormatRowTypeKey, RowLine: 1},
+				{RowType: config.ExcelFormatRowTypeData, RowLine: 2},
+				{RowType: config.ExcelFormatRowTypeValueType, RowLine: 3},
+			},
+			rows: [][]string{
+				{"id", "name"},
+				{"1", "alpha"},
+				{"int", "string"},
+				{"2", "beta"},
+			},
+		},
+	} {
+		conf := *config.DefaultConfig
+		conf.ExcelFormats = tc.excelFormats
+		c, err := NewConverter(&conf)
+		if err != nil {
+			t.Fatalf("%s: %s", tc.name, err)
+		}
+
+		converted, err := c.ConvertWorkbook(newTestXlsxFile(t, "sheet", tc.rows))
+		if err != nil {
+			t.Fatalf("%s: %s", tc.name, err)
+		}
+		rows := converted["sheet"]
+		if len(rows) != 2 || rows[0]["name"] != "alpha" || rows[1]["name"] != "beta" {
+			t.Errorf("%s: Mismatch contents. actual %v", tc.name, rows)
+		}
+
+		header, err := c.ConvertWorkbookIntoHeader(newTestXlsxFile(t, "sheet", tc.rows))
+		if err != nil {
+			t.Fatalf("%s: %s", tc.name, err)
+		}
+		if len(header["sheet"]) != 2 || header["sheet"]["name"].Index != 1 {
+			t.Errorf("%s: invalid header. actual %v", tc.name, header["sheet"])
+		}
+	}
+}
+
+func TestConvertWithoutKeyRowInSheet(t *testing.T) {
+	conf := *config.DefaultConfig
+	conf.ExcelFormats = []config.ExcelFormat{
+		{RowType: config.ExcelFormatRowTypeKey, RowLine: 3},
+	}
+	c, err := NewConverter(&conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = c.ConvertWorkbook(newTestXlsxFile(t, "sheet", [][]string{{"id"}, {"1"}}))
+	convertErr, ok := err.(*ConvertError)
+	if !ok || convertErr.Row != 3 {
+		t.Errorf("missing key row should be *ConvertError at row 3, actual: %v", err)
+	}
+}
+
+func TestConvertOutputIsDeterministic(t *testing.T) {
+	dir, _ := os.Getwd()
+	inputDir := []string{
+		path.Join(dir, "..", "test", "excels", "convert_test.xlsx"),
+		path.Join(dir, "..", "test", "excels", "convert_test2.xlsx"),
+		path.Join(dir, "..", "test", "excels", "data01.xlsx"),
+		path.Join(dir, "..", "test", "excels", "master.xlsx"),
+	}
+	outputDir, err := ioutil.TempDir("", "cxtj")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(outputDir)
+
+	for _, keyOrder := range []config.KeyOrder{config.KeyOrderAlphabetical, config.KeyOrderColumn} {
+		for _, format := range []string{FormatJSON, FormatYAML, FormatTOML} {
+			conf := *config.DefaultConfig
+			conf.Output.KeyOrder = keyOrder
+			conf.Output.Format = format
+			c, err := NewConverter(&conf)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			outputs := []string{}
+			convert := func(name string, f func(output string) error) {
+				output := path.Join(outputDir, name)
+				if err := f(output); err != nil {
+					t.Fatal(err)
+				}
+				outputs = append(outputs, output)
+			}
+			for i := 0; i < 2; i++ {
+				convert(fmt.Sprintf("serial%d", i), func(output string) error {
+					return c.Convert(inputDir, output, false)
+				})
+			}
+			for _, jobs := range []int{1, 4} {
+				convert(fmt.Sprintf("concurrent%d", jobs), func(output string) error {
+					return c.ConvertConcurrencyContext(context.Background(), inputDir, output, false, WorkerOptions{Jobs: jobs})
+				})
+			}
+
+			expect, err := ioutil.ReadFile(outputs[0])
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, output := range outputs[1:] {
+				actual, err := ioutil.ReadFile(output)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if !bytes.Equal(actual, expect) {
+					t.Errorf("%s of %s key order is different from %s", path.Base(output), keyOrder, path.Base(outputs[0]))
+				}
+			}
+		}
+	}
+}
+
+func TestConvertKeysInColumnOrderByDefault(t *testing.T) {
+	dir, err := ioutil.TempDir("", "cxtj")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	inputFile := path.Join(dir, "order.xlsx")
+	f := newTestXlsxFile(t, "sheet", [][]string{
+		{"name", "id", "hp"},
+		{"string", "int", "int"},
+		{"Name", "ID", "HP"},
+		{"slime", "1", "10"},
+	})
+	if err := f.Save(inputFile); err != nil {
+		t.Fatal(err)
+	}
+
+	c, err := NewConverter(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	output := path.Join(dir, "order.json")
+	if err := c.Convert([]string{inputFile}, output, false); err != nil {
+		t.Fatal(err)
+	}
+
+	actual, err := ioutil.ReadFile(output)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expect := `{"sheet":[{"name":"slime","id":1,"hp":10}]}`
+	if strings.TrimSpace(string(actual)) != expect {
+		t.Errorf("keys should be in order of columns by default. expect: %s, actual: %s", expect, actual)
+	}
+}
+
+func TestConcurrencyConvertIntoHeaderIsSameAsSerial(t *testing.T) {
+	dir, _ := os.Getwd()
+	inputFiles := []string{
+		path.Join(dir, "..", "test", "excels", "convert_test.xlsx"),
+		path.Join(dir, "..", "test", "excels", "convert_test2.xlsx"),
+	}
+	outputDir, err := ioutil.TempDir("", "cxtj")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(outputDir)
+
+	c, err := NewConverter(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	serial := path.Join(outputDir, "serial.json")
+	concurrent := path.Join(outputDir, "concurrent.json")
+	if err := c.ConvertIntoHeader(inputFiles, serial, false); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.ConvertIntoHeaderConcurrency(inputFiles, concurrent, false); err != nil {
+		t.Fatal(err)
+	}
+
+	expect, err := ioutil.ReadFile(serial)
+	if err != nil {
+		t.Fatal(err)
+	}
+	actual, err := ioutil.ReadFile(concurrent)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(actual, expect) {
+		t.Errorf("header of concurrency conversion is different. except %s, actual %s", expect, actual)
+	}
+}
+
+func TestConvertSheetsConcurrently(t *testing.T) {
+	rows := [][]string{
+		{"id", "name", "stats.hp"},
+		{"int!", "string", "int"},
+		{"ID", "Name", "HP"},
+	}
+	for i := 1; i <= 50; i++ {
+		rows = append(rows, []string{fmt.Sprint(i), fmt.Sprintf("name%d", i), fmt.Sprint(i * 10)})
+	}
+	sheetNames := []string{"d", "b", "a", "e", "c"}
+	sheets := make([]testSheet, len(sheetNames))
+	for i, sheetName := range sheetNames {
+		sheets[i] = testSheet{sheetName, rows}
+	}
+	f := newTestXlsxWorkbook(t, sheets...)
+
+	convert := func(sheetJobs int) (XlsxMap, XlsxHeaderMap, workbook) {
+		conf := *config.DefaultConfig
+		conf.SheetJobs = sheetJobs
+		c, err := NewConverter(&conf)
+		if err != nil {
+			t.Fatal(err)
+		}
+		converted, err := c.ConvertWorkbook(f)
+		if err != nil {
+			t.Fatal(err)
+		}
+		header, err := c.ConvertWorkbookIntoHeader(f)
+		if err != nil {
+			t.Fatal(err)
+		}
+		book, err := c.xlsx2Workbook(context.Background(), "book.xlsx", f)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return converted, header, book
+	}
+
+	expectData, expectHeader, expectBook := convert(1)
+	if len(expectData) != len(sheetNames) || len(expectData["a"]) != 50 || len(expectBook.primaryKeys) != len(sheetNames) {
+		t.Fatalf("invalid conversion of sheets: %v", expectBook.primaryKeys)
+	}
+	for _, sheetJobs := range []int{0, 2, 4, 8} {
+		data, header, book := convert(sheetJobs)
+		if !reflect.DeepEqual(data, expectData) || !reflect.DeepEqual(header, expectHeader) || !reflect.DeepEqual(book, expectBook) {
+			t.Errorf("conversion with %d sheet jobs is different from one by one", sheetJobs)
+		}
+	}
+
+	// sheet workers are stopped by the context of the caller
+	c, err := NewConverter(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err = c.xlsx2Workbook(ctx, "book.xlsx", f)
+	if convertErr, ok := err.(*ConvertError); !ok || convertErr.Err != context.Canceled {
+		t.Errorf("conversion with the canceled context should be canceled: %v", err)
+	}
+}
+
+func TestSheetJobsUnderFileWorkers(t *testing.T) {
+	testCases := []struct {
+		sheetJobs int
+		fileJobs  int
+		expect    int
+	}{
+		{0, 0, 0},
+		{0, 1, 0},
+		{0, 4, 1},
+		{2, 4, 2},
+		{2, 0, 2},
+	}
+	for _, tc := range testCases {
+		conf := *config.DefaultConfig
+		conf.SheetJobs = tc.sheetJobs
+		c, err := NewConverter(&conf)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ctx := context.Background()
+		if tc.fileJobs > 0 {
+			ctx = withFileJobs(ctx, tc.fileJobs)
+		}
+		if actual := c.sheetJobs(ctx); actual != tc.expect {
+			t.Errorf("sheet jobs of %d under %d file jobs should be %d, actual: %d", tc.sheetJobs, tc.fileJobs, tc.expect, actual)
+		}
+	}
+}
+
+func TestConvertSheetsConcurrentlyReturnsFirstError(t *testing.T) {
+	sheets := []testSheet{}
+	for _, s := range []struct {
+		name string
+		hp   string
+	}{{"a", "100"}, {"b", "abc"}, {"c", "xyz"}, {"d", "200"}} {
+		sheets = append(sheets, testSheet{s.name, [][]string{{"id", "hp"}, {"int", "int"}, {"ID", "HP"}, {"1", s.hp}}})
+	}
+	f := newTestXlsxWorkbook(t, sheets...)
+
+	conf := *config.DefaultConfig
+	conf.SheetJobs = 4
+	c, err := NewConverter(&conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 10; i++ {
+		_, err := c.ConvertWorkbook(f)
+		convertErr, ok := err.(*ConvertError)
+		if !ok || convertErr.Sheet != "b" {
+			t.Fatalf("the error of the first failed sheet b should be returned: %v", err)
+		}
+	}
+}
+
+func TestConcurrencyConvertReturnsErrorOfFailedFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "cxtj")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	// sheets of the slow file are canceled by the failure of the broken file
+	rows := [][]string{{"id", "name"}, {"int", "string"}, {"ID", "Name"}}
+	for i := 1; i <= 50; i++ {
+		rows = append(rows, []string{fmt.Sprint(i), fmt.Sprintf("name%d", i)})
+	}
+	sheets := make([]testSheet, 100)
+	for i := range sheets {
+		sheets[i] = testSheet{fmt.Sprintf("sheet%03d", i), rows}
+	}
+	slowFile := path.Join(dir, "a.xlsx")
+	if err := newTestXlsxWorkbook(t, sheets...).Save(slowFile); err != nil {
+		t.Fatal(err)
+	}
+	brokenFile := path.Join(dir, "b.xlsx")
+	if err := ioutil.WriteFile(brokenFile, []byte("broken"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	conf := *config.DefaultConfig
+	conf.SheetJobs = 1
+	c, err := NewConverter(&conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = c.ConvertConcurrencyContext(context.Background(), []string{slowFile, brokenFile}, path.Join(dir, "output.json"), false, WorkerOptions{Jobs: 2})
+	if convertErr, ok := err.(*ConvertError); !ok || convertErr.File != brokenFile || errors.Is(err, context.Canceled) {
+		t.Errorf("the error of the broken file should be returned: %v", err)
+	}
+}
+
+func BenchmarkConvertWorkbookSheets(b *testing.B) {
+	dir, _ := os.Getwd()
+	f, err := xlsx.OpenFile(path.Join(dir, "..", "test", "excels", "heavy.xlsx"))
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	for _, sheetJobs := range []int{1, 2, 4, 0} {
+		conf := *config.DefaultConfig
+		conf.SheetJobs = sheetJobs
+		c, err := NewConverter(&conf)
+		if err != nil {
+			b.Fatal(err)
+		}
+
+		name := fmt.Sprintf("jobs=%d", sheetJobs)
+		if sheetJobs == 0 {
+			name = "jobs=cpu"
+		}
+		b.Run(name, func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				if _, err := c.ConvertWorkbook(f); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+		b.Run(name+"/header", func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				if _, err := c.ConvertWorkbookIntoHeader(f); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,349 @@
+package cxtj
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/tealeg/xlsx"
+
+	"github.com/kama2vern/cxtj/config"
+)
+
+// DiffKind is an enum to represent how a sheet or a row is changed
+type DiffKind int
+
+// DiffKind enum values
+const (
+	DiffKindModified DiffKind = iota
+	DiffKindAdded
+	DiffKindRemoved
+)
+
+func (k DiffKind) String() string {
+	switch k {
+	case DiffKindModified:
+		return "modified"
+	case DiffKindAdded:
+		return "added"
+	case DiffKindRemoved:
+		return "removed"
+	}
+	return ""
+}
+
+// MarshalText writes DiffKind as its name in json
+func (k DiffKind) MarshalText() ([]byte, error) {
+	return []byte(k.String()), nil
+}
+
+// CellChange is a changed value of a column in a modified row
+type CellChange struct {
+	Column string      `json:"column"`
+	Old    interface{} `json:"old"`
+	New    interface{} `json:"new"`
+}
+
+// RowDiff is an added, removed or modified row.
+// Key is the primary key of the row, or the 1-based index of data rows when the sheet has no primary key.
+type RowDiff struct {
+	Kind    DiffKind               `json:"kind"`
+	Key     string                 `json:"key"`
+	Old     map[string]interface{} `json:"old,omitempty"`
+	New     map[string]interface{} `json:"new,omitempty"`
+	Changes []CellChange           `json:"changes,omitempty"`
+}
+
+// SheetDiff is changed rows of a sheet.
+// KeyColumn is the primary key column which matches rows, or empty when rows are matched by index.
+type SheetDiff struct {
+	Sheet     string    `json:"sheet"`
+	Kind      DiffKind  `json:"kind"`
+	KeyColumn string    `json:"keyColumn,omitempty"`
+	Rows      []RowDiff `json:"rows"`
+}
+
+// DiffFiles converts two xlsx files and compares them sheet by sheet.
+// An empty file like /dev/null is a workbook without sheets, so that it can be used as a git external diff.
+func (c *Converter) DiffFiles(oldFile string, newFile string) ([]SheetDiff, error) {
+	oldContent, err := ioutil.ReadFile(oldFile)
+	if err != nil {
+		return nil, err
+	}
+	newContent, err := ioutil.ReadFile(newFile)
+	if err != nil {
+		return nil, err
+	}
+	return c.diffContents(oldFile, oldContent, newFile, newContent)
+}
+
+// DiffRevisions converts the xlsx file of two git revisions and compares them sheet by sheet.
+// The working tree file is used when newRev is empty.
+func (c *Converter) DiffRevisions(file string, oldRev string, newRev string) ([]SheetDiff, error) {
+	oldContent, err := gitShow(file, oldRev)
+	if err != nil {
+		return nil, err
+	}
+	var newContent []byte
+	if newRev == "" {
+		newContent, err = ioutil.ReadFile(file)
+	} else {
+		newContent, err = gitShow(file, newRev)
+	}
+	if err != nil {
+		return nil, err
+	}
+	newLabel := file
+	if newRev != "" {
+		newLabel = newRev + ":" + file
+	}
+	return c.diffContents(oldRev+":"+file, oldContent, newLabel, newContent)
+}
+
+// gitShow reads the file of the git revision.
+// The file is resolved relative to the top of the repository which has it, so that absolute paths
+// and paths outside of the current directory can be read.
+func gitShow(file string, rev string) ([]byte, error) {
+	abs, err := filepath.Abs(file)
+	if err != nil {
+		return nil, err
+	}
+	dir := filepath.Dir(abs)
+	if resolved, err := filepath.EvalSymlinks(dir); err == nil {
+		// the top of the repository is a path without symbolic links
+		dir = resolved
+	}
+	top, err := gitOutput(dir, "rev-parse", "--show-toplevel")
+	if err != nil {
+		return nil, err
+	}
+	rel, err := filepath.Rel(strings.TrimSpace(string(top)), filepath.Join(dir, filepath.Base(abs)))
+	if err != nil {
+		return nil, err
+	}
+	return gitOutput(dir, "show", rev+":"+filepath.ToSlash(rel))
+}
+
+// gitOutput runs the git command in dir and returns the output, and stderr is the message of the error
+func gitOutput(dir string, args ...string) ([]byte, error) {
+	var stderr bytes.Buffer
+	cmd := exec.Command("git", args...)
+	cmd.Dir = dir
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("git %s: %s", strings.Join(args, " "), strings.TrimSpace(stderr.String()))
+	}
+	return out, nil
+}
+
+func (c *Converter) diffContents(oldFile string, oldContent []byte, newFile string, newContent []byte) ([]SheetDiff, error) {
+	oldBook, err := c.convertXlsxContent(oldFile, oldContent)
+	if err != nil {
+		return nil, err
+	}
+	newBook, err := c.convertXlsxContent(newFile, newContent)
+	if err != nil {
+		return nil, err
+	}
+	return diffWorkbooks(oldBook, newBook), nil
+}
+
+// convertXlsxContent converts xlsx content into workbook, and empty content is a workbook without sheets
+func (c *Converter) convertXlsxContent(filename string, content []byte) (workbook, error) {
+	if len(content) == 0 {
+		return workbook{file: filename, sheets: XlsxMap{}, columns: map[string][]string{}, primaryKeys: map[string]string{}}, nil
+	}
+	xlsxFile, err := xlsx.OpenBinary(content)
+	if err != nil {
+		return workbook{}, &ConvertError{File: filename, Err: err}
+	}
+	return c.xlsx2Workbook(context.Background(), filename, xlsxFile)
+}
+
+// diffWorkbooks compares sheets of workbooks in alphabetical order of sheet names.
+// Sheets without changes are omitted.
+func diffWorkbooks(oldBook workbook, newBook workbook) []SheetDiff {
+	sheetNames := sortedSheetNames(oldBook.sheets)
+	for _, sheetName := range sortedSheetNames(newBook.sheets) {
+		if _, ok := oldBook.sheets[sheetName]; !ok {
+			sheetNames = append(sheetNames, sheetName)
+		}
+	}
+	sort.Strings(sheetNames)
+
+	ret := []SheetDiff{}
+	for _, sheetName := range sheetNames {
+		oldRows, inOld := oldBook.sheets[sheetName]
+		newRows, inNew := newBook.sheets[sheetName]
+
+		d := SheetDiff{Sheet: sheetName, Kind: DiffKindModified}
+		switch {
+		case !inOld:
+			d.Kind = DiffKindAdded
+		case !inNew:
+			d.Kind = DiffKindRemoved
+		}
+		if oldBook.primaryKeys[sheetName] == newBook.primaryKeys[sheetName] || !inOld || !inNew {
+			d.KeyColumn = oldBook.primaryKeys[sheetName]
+			if d.KeyColumn == "" {
+				d.KeyColumn = newBook.primaryKeys[sheetName]
+			}
+		}
+
+		columns := mergeColumnOrder(newBook.columns[sheetName], oldBook.columns[sheetName])
+		d.Rows = diffRows(d.KeyColumn, columns, oldRows, newRows)
+		if len(d.Rows) > 0 || d.Kind != DiffKindModified {
+			ret = append(ret, d)
+		}
+	}
+	return ret
+}
+
+// diffRows matches rows by the primary key column, or index if it is empty, and compares values of each column.
+// Removed and modified rows are in order of old rows, and added rows follow them in order of new rows.
+func diffRows(keyColumn string, columns []string, oldRows []map[string]interface{}, newRows []map[string]interface{}) []RowDiff {
+	rowKey := func(i int, row map[string]interface{}) string {
+		return strconv.Itoa(i + 1)
+	}
+	if keyColumn != "" {
+		if path, err := parseColumnPath(keyColumn); err == nil {
+			rowKey = func(i int, row map[string]interface{}) string {
+				return primaryKeyString(getPathValue(row, path))
+			}
+		}
+	}
+
+	paths := make([]columnPath, 0, len(columns))
+	for _, column := range columns {
+		if path, err := parseColumnPath(column); err == nil {
+			paths = append(paths, path)
+		}
+	}
+
+	newIndices := make(map[string]int, len(newRows))
+	for i, row := range newRows {
+		newIndices[rowKey(i, row)] = i
+	}
+
+	ret := []RowDiff{}
+	matched := make(map[string]bool, len(oldRows))
+	for i, oldRow := range oldRows {
+		key := rowKey(i, oldRow)
+		j, ok := newIndices[key]
+		if !ok {
+			ret = append(ret, RowDiff{Kind: DiffKindRemoved, Key: key, Old: oldRow})
+			continue
+		}
+		matched[key] = true
+
+		changes := []CellChange{}
+		for _, path := range paths {
+			oldValue, newValue := getPathValue(oldRow, path), getPathValue(newRows[j], path)
+			if !reflect.DeepEqual(oldValue, newValue) {
+				changes = append(changes, CellChange{Column: path.String(), Old: oldValue, New: newValue})
+			}
+		}
+		if len(changes) > 0 {
+			ret = append(ret, RowDiff{Kind: DiffKindModified, Key: key, Old: oldRow, New: newRows[j], Changes: changes})
+		}
+	}
+	for i, newRow := range newRows {
+		if key := rowKey(i, newRow); !matched[key] {
+			ret = append(ret, RowDiff{Kind: DiffKindAdded, Key: key, New: newRow})
+		}
+	}
+	return ret
+}
+
+// WriteDiff writes sheet diffs as text like unified diff.
+// Added and removed rows are written as json, and modified rows are written with changed columns.
+func WriteDiff(w io.Writer, oldLabel string, newLabel string, diffs []SheetDiff) error {
+	if len(diffs) == 0 {
+		return nil
+	}
+
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "--- %s\n+++ %s\n", oldLabel, newLabel)
+	for _, d := range diffs {
+		if d.Kind == DiffKindModified {
+			fmt.Fprintf(&buf, "@@ %s @@\n", d.Sheet)
+		} else {
+			fmt.Fprintf(&buf, "@@ %s (%s) @@\n", d.Sheet, d.Kind)
+		}
+
+		keyName := "row"
+		if d.KeyColumn != "" {
+			keyName = d.KeyColumn
+		}
+		for _, r := range d.Rows {
+			switch r.Kind {
+			case DiffKindAdded:
+				fmt.Fprintf(&buf, "+ %s=%s %s\n", keyName, r.Key, diffValue(r.New))
+			case DiffKindRemoved:
+				fmt.Fprintf(&buf, "- %s=%s %s\n", keyName, r.Key, diffValue(r.Old))
+			case DiffKindModified:
+				fmt.Fprintf(&buf, "~ %s=%s\n", keyName, r.Key)
+				for _, change := range r.Changes {
+					fmt.Fprintf(&buf, "    %s: %s -> %s\n", change.Column, diffValue(change.Old), diffValue(change.New))
+				}
+			}
+		}
+	}
+	_, err := w.Write(buf.Bytes())
+	return err
+}
+
+// WriteDiffJSON writes sheet diffs as json
+func WriteDiffJSON(w io.Writer, diffs []SheetDiff) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(diffs)
+}
+
+func diffValue(v interface{}) string {
+	bs, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Sprint(v)
+	}
+	return string(bs)
+}
+
+// TextConv writes each row of the xlsx file as a line of json in order of columns,
+// so that changes of xlsx can be shown by the line diff of git with diff.textconv.
+func (c *Converter) TextConv(w io.Writer, file string) error {
+	content, err := ioutil.ReadFile(file)
+	if err != nil {
+		return err
+	}
+	book, err := c.convertXlsxContent(file, content)
+	if err != nil {
+		return err
+	}
+
+	opts := EncoderOptions{Output: config.OutputConfig{KeyOrder: config.KeyOrderColumn}, ColumnOrder: book.columns}
+	var buf bytes.Buffer
+	for _, sheetName := range sortedSheetNames(book.sheets) {
+		fmt.Fprintf(&buf, "@@ %s @@\n", sheetName)
+		tree := columnTree(book.columns[sheetName])
+		for _, row := range book.sheets[sheetName] {
+			bs, err := json.Marshal(opts.orderObject(tree, "", row))
+			if err != nil {
+				return err
+			}
+			buf.Write(bs)
+			buf.WriteByte('\n')
+		}
+	}
+	_, err = w.Write(buf.Bytes())
+	return err
+}
@@ -0,0 +1,205 @@
+package cxtj
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path"
+	"reflect"
+	"testing"
+)
+
+func TestDiffWorkbooksByPrimaryKey(t *testing.T) {
+	c, err := NewConverter(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	oldBook, err := c.xlsx2Workbook(context.Background(), "old.xlsx", newTestXlsxFile(t, "characters", [][]string{
+		{"id", "name", "stats.hp"},
+		{"int!", "string", "int"},
+		{"ID", "Name", "HP"},
+		{"1", "alpha", "10"},
+		{"2", "beta", "20"},
+		{"3", "gamma", "30"},
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	newBook, err := c.xlsx2Workbook(context.Background(), "new.xlsx", newTestXlsxFile(t, "characters", [][]string{
+		{"id", "name", "stats.hp"},
+		{"int!", "string", "int"},
+		{"ID", "Name", "HP"},
+		{"3", "gamma", "30"},
+		{"1", "alpha", "15"},
+		{"4", "delta", "40"},
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	diffs := diffWorkbooks(oldBook, newBook)
+	if len(diffs) != 1 || diffs[0].Sheet != "characters" || diffs[0].KeyColumn != "id" {
+		t.Fatalf("invalid sheet diffs: %v", diffs)
+	}
+	rows := diffs[0].Rows
+	if len(rows) != 3 {
+		t.Fatalf("invalid row diffs: %v", rows)
+	}
+	if rows[0].Kind != DiffKindModified || rows[0].Key != "1" ||
+		!reflect.DeepEqual(rows[0].Changes, []CellChange{{Column: "stats.hp", Old: int64(10), New: int64(15)}}) {
+		t.Errorf("invalid modified row: %v", rows[0])
+	}
+	if rows[1].Kind != DiffKindRemoved || rows[1].Key != "2" {
+		t.Errorf("invalid removed row: %v", rows[1])
+	}
+	if rows[2].Kind != DiffKindAdded || rows[2].Key != "4" {
+		t.Errorf("invalid added row: %v", rows[2])
+	}
+
+	var buf bytes.Buffer
+	if err := WriteDiff(&buf, "old.xlsx", "new.xlsx", diffs); err != nil {
+		t.Fatal(err)
+	}
+	expect := `--- old.xlsx
++++ new.xlsx
+@@ characters @@
+~ id=1
+    stats.hp: 10 -> 15
+- id=2 {"id":2,"name":"beta","stats":{"hp":20}}
++ id=4 {"id":4,"name":"delta","stats":{"hp":40}}
+`
+	if buf.String() != expect {
+		t.Errorf("invalid diff text. expect:\n%s\nactual:\n%s", expect, buf.String())
+	}
+}
+
+func TestDiffWorkbooksByIndex(t *testing.T) {
+	c, err := NewConverter(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	oldBook, err := c.xlsx2Workbook(context.Background(), "old.xlsx", newTestXlsxFile(t, "sheet", [][]string{
+		{"name"},
+		{"string"},
+		{"Name"},
+		{"alpha"},
+		{"beta"},
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	newBook, err := c.xlsx2Workbook(context.Background(), "new.xlsx", newTestXlsxFile(t, "sheet", [][]string{
+		{"name"},
+		{"string"},
+		{"Name"},
+		{"alpha"},
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	diffs := diffWorkbooks(oldBook, newBook)
+	expect := []SheetDiff{{Sheet: "sheet", Kind: DiffKindModified, Rows: []RowDiff{
+		{Kind: DiffKindRemoved, Key: "2", Old: map[string]interface{}{"name": "beta"}},
+	}}}
+	if !reflect.DeepEqual(diffs, expect) {
+		t.Errorf("invalid diffs. expect: %v, actual: %v", expect, diffs)
+	}
+}
+
+func TestDiffFilesFromEmptyFile(t *testing.T) {
+	dir, _ := os.Getwd()
+	xlsxFile := path.Join(dir, "..", "test", "excels", "convert_test.xlsx")
+
+	c, err := NewConverter(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	diffs, err := c.DiffFiles(os.DevNull, xlsxFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(diffs) != 1 || diffs[0].Kind != DiffKindAdded || len(diffs[0].Rows) != 4 {
+		t.Errorf("all rows of the new file should be added: %v", diffs)
+	}
+
+	diffs, err = c.DiffFiles(xlsxFile, xlsxFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(diffs) != 0 {
+		t.Errorf("the same file should not have diffs: %v", diffs)
+	}
+}
+
+func TestDiffRevisionsOfAbsolutePath(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not found")
+	}
+	repo, err := ioutil.TempDir("", "cxtj")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(repo)
+
+	git := func(args ...string) {
+		cmd := exec.Command("git", append([]string{"-c", "user.name=cxtj", "-c", "user.email=cxtj@example.com"}, args...)...)
+		cmd.Dir = repo
+		if out, err := cmd.CombinedOutput(); err != nil {
+			t.Fatalf("git %v: %s", args, out)
+		}
+	}
+	// the file is in a sub directory of the repository which is not the current directory
+	xlsxFile := path.Join(repo, "excels", "characters.xlsx")
+	if err := os.MkdirAll(path.Dir(xlsxFile), 0755); err != nil {
+		t.Fatal(err)
+	}
+	commit := func(hp string) {
+		f := newTestXlsxFile(t, "characters", [][]string{
+			{"id", "hp"},
+			{"int!", "int"},
+			{"ID", "HP"},
+			{"1", hp},
+		})
+		if err := f.Save(xlsxFile); err != nil {
+			t.Fatal(err)
+		}
+		git("add", "-A")
+		git("commit", "-q", "-m", "hp "+hp)
+	}
+	git("init", "-q")
+	commit("10")
+	commit("20")
+
+	c, err := NewConverter(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	diffs, err := c.DiffRevisions(xlsxFile, "HEAD~1", "HEAD")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expect := []CellChange{{Column: "hp", Old: int64(10), New: int64(20)}}
+	if len(diffs) != 1 || len(diffs[0].Rows) != 1 || !reflect.DeepEqual(diffs[0].Rows[0].Changes, expect) {
+		t.Errorf("invalid diffs of revisions: %v", diffs)
+	}
+
+	diffs, err = c.DiffRevisions(xlsxFile, "HEAD", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(diffs) != 0 {
+		t.Errorf("the working tree file should not have diffs from HEAD: %v", diffs)
+	}
+
+	// the working tree file is labeled by the path without revision
+	if err := ioutil.WriteFile(xlsxFile, []byte("broken"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	_, err = c.DiffRevisions(xlsxFile, "HEAD", "")
+	if convertErr, ok := err.(*ConvertError); !ok || convertErr.File != xlsxFile {
+		t.Errorf("error of the working tree file should have the path. actual %v", err)
+	}
+}
@@ -0,0 +1,317 @@
+package cxtj
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	yaml "gopkg.in/yaml.v2"
+
+	"github.com/kama2vern/cxtj/config"
+)
+
+// Encoder writes converted data in an output format
+type Encoder interface {
+	// Encode writes XlsxMap or XlsxHeaderMap which has all converted sheets
+	Encode(w io.Writer, v interface{}) error
+	// EncodeSheet writes SheetDataList or SheetColumns of one sheet in --multiple-output mode
+	EncodeSheet(w io.Writer, sheetName string, v interface{}) error
+}
+
+// Output formats
+const (
+	FormatJSON = "json"
+	FormatYAML = "yaml"
+	FormatTOML = "toml"
+	FormatCSV  = "csv"
+)
+
+// EncoderOptions configures an encoder
+type EncoderOptions struct {
+	Output config.OutputConfig
+
+	// ColumnOrder is column names of each sheet in order of columns in the spreadsheet.
+	// It is used when the key order is column.
+	ColumnOrder map[string][]string
+
+	// PrimaryKeys is the primary key column of each sheet.
+	// It is used when the row shape is keyed.
+	PrimaryKeys map[string]string
+}
+
+// NewEncoderFunc creates an encoder with options
+type NewEncoderFunc func(opts EncoderOptions) Encoder
+
+type encoderEntry struct {
+	newEncoder NewEncoderFunc
+	ext        string
+}
+
+var encoders = map[string]encoderEntry{}
+var encoderExts = map[string]string{}
+
+func init() {
+	RegisterEncoder(FormatJSON, NewJSONEncoder, ".json")
+	RegisterEncoder(FormatYAML, NewYAMLEncoder, ".yaml", ".yml")
+	RegisterEncoder(FormatTOML, NewTOMLEncoder, ".toml")
+	RegisterEncoder(FormatCSV, NewCSVEncoder, ".csv")
+}
+
+// RegisterEncoder adds an encoder of the format.
+// The first of exts is used as the extension of the format, and all of exts are used to detect the format from a file name.
+func RegisterEncoder(format string, newEncoder NewEncoderFunc, exts ...string) {
+	entry := encoderEntry{newEncoder: newEncoder}
+	if len(exts) > 0 {
+		entry.ext = exts[0]
+	}
+	encoders[format] = entry
+	for _, ext := range exts {
+		encoderExts[ext] = format
+	}
+	config.RegisterOutputFormat(format)
+}
+
+// GetEncoder creates the registered encoder of the format
+func GetEncoder(format string, opts EncoderOptions) (Encoder, error) {
+	entry, ok := encoders[format]
+	if !ok {
+		return nil, fmt.Errorf("unknown output format: %s", format)
+	}
+	return entry.newEncoder(opts), nil
+}
+
+// FormatExt returns the file extension of the format like ".json"
+func FormatExt(format string) string {
+	return encoders[format].ext
+}
+
+// FormatFromFileName detects the output format from the extension of the file name
+func FormatFromFileName(fileName string) (string, bool) {
+	format, ok := encoderExts[strings.ToLower(filepath.Ext(fileName))]
+	return format, ok
+}
+
+// JSONEncoder writes data as json
+type JSONEncoder struct {
+	opts EncoderOptions
+}
+
+// NewJSONEncoder creates JSONEncoder
+func NewJSONEncoder(opts EncoderOptions) Encoder {
+	return &JSONEncoder{opts: opts}
+}
+
+func (e *JSONEncoder) write(w io.Writer, v interface{}) error {
+	enc := json.NewEncoder(newNewlineWriter(w, e.opts.Output.Newline))
+	if e.opts.Output.Pretty {
+		enc.SetIndent("", e.opts.Output.Indent)
+	}
+	return enc.Encode(v)
+}
+
+// Encode writes XlsxMap or XlsxHeaderMap as json object keyed by sheet name, or array of sheets
+func (e *JSONEncoder) Encode(w io.Writer, v interface{}) error {
+	return e.write(w, e.opts.order("", v))
+}
+
+// EncodeSheet writes one sheet as json
+func (e *JSONEncoder) EncodeSheet(w io.Writer, sheetName string, v interface{}) error {
+	return e.write(w, e.opts.order(sheetName, v))
+}
+
+// YAMLEncoder writes data as yaml
+type YAMLEncoder struct {
+	opts EncoderOptions
+}
+
+// NewYAMLEncoder creates YAMLEncoder
+func NewYAMLEncoder(opts EncoderOptions) Encoder {
+	return &YAMLEncoder{opts: opts}
+}
+
+func (e *YAMLEncoder) write(w io.Writer, v interface{}) error {
+	bs, err := yaml.Marshal(v)
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(yamlNewlines(bs, e.opts.Output.Newline))
+	return err
+}
+
+// Encode writes XlsxMap or XlsxHeaderMap as yaml mapping keyed by sheet name, or sequence of sheets
+func (e *YAMLEncoder) Encode(w io.Writer, v interface{}) error {
+	return e.write(w, e.opts.order("", v))
+}
+
+// EncodeSheet writes one sheet as yaml
+func (e *YAMLEncoder) EncodeSheet(w io.Writer, sheetName string, v interface{}) error {
+	return e.write(w, e.opts.order(sheetName, v))
+}
+
+// TOMLEncoder writes data as toml.
+// Each sheet is an array of tables or a table of keyed rows, and empty values are omitted because toml has no null.
+// Keys are sorted by the key order, and the shape should be object.
+type TOMLEncoder struct {
+	opts EncoderOptions
+}
+
+// NewTOMLEncoder creates TOMLEncoder
+func NewTOMLEncoder(opts EncoderOptions) Encoder {
+	return &TOMLEncoder{opts: opts}
+}
+
+func (e *TOMLEncoder) write(w io.Writer, v interface{}) error {
+	if e.opts.Output.Shape == config.OutputShapeArray {
+		return fmt.Errorf("toml format does not support array shape")
+	}
+
+	enc := toml.NewEncoder(newNewlineWriter(w, e.opts.Output.Newline))
+	enc.Indent = ""
+	if e.opts.Output.Pretty {
+		enc.Indent = e.opts.Output.Indent
+	}
+	return enc.Encode(tomlValue(v))
+}
+
+// Encode writes XlsxMap or XlsxHeaderMap as toml tables keyed by sheet name
+func (e *TOMLEncoder) Encode(w io.Writer, v interface{}) error {
+	return e.write(w, e.opts.order("", v))
+}
+
+// EncodeSheet writes one sheet as toml.
+// Toml document should be a table, so the sheet is written under the key of sheet name.
+func (e *TOMLEncoder) EncodeSheet(w io.Writer, sheetName string, v interface{}) error {
+	return e.write(w, orderedObject{{Key: sheetName, Value: e.opts.order(sheetName, v)}})
+}
+
+// CSVEncoder writes one sheet as csv.
+// The first line is column names, and data columns are sorted by the key order. Rows are not keyed by the row shape.
+// Nested objects and arrays are written as json in a cell, and keys of them are sorted by the key order.
+// Header is written as lines of column name, index and value type, and nested columns are flattened into paths.
+type CSVEncoder struct {
+	opts EncoderOptions
+}
+
+// NewCSVEncoder creates CSVEncoder
+func NewCSVEncoder(opts EncoderOptions) Encoder {
+	return &CSVEncoder{opts: opts}
+}
+
+// Encode writes XlsxMap or XlsxHeaderMap which has only one sheet
+func (e *CSVEncoder) Encode(w io.Writer, v interface{}) error {
+	switch m := v.(type) {
+	case XlsxMap:
+		if len(m) != 1 {
+			return fmt.Errorf("csv format supports only one sheet, but %d sheets are converted. use --multiple-output", len(m))
+		}
+		for sheetName, l := range m {
+			return e.EncodeSheet(w, sheetName, SheetDataList(l))
+		}
+	case XlsxHeaderMap:
+		if len(m) != 1 {
+			return fmt.Errorf("csv format supports only one sheet, but %d sheets are converted. use --multiple-output", len(m))
+		}
+		for sheetName, columns := range m {
+			return e.EncodeSheet(w, sheetName, SheetColumns(columns))
+		}
+	}
+	return fmt.Errorf("csv format does not support %T", v)
+}
+
+// EncodeSheet writes SheetDataList or SheetColumns as csv
+func (e *CSVEncoder) EncodeSheet(w io.Writer, sheetName string, v interface{}) error {
+	cw := newCSVWriter(w, e.opts.Output.Newline)
+	switch data := v.(type) {
+	case SheetDataList:
+		e.writeSheetDataList(cw, sheetName, data)
+	case SheetColumns:
+		e.writeSheetColumns(cw, data)
+	default:
+		return fmt.Errorf("csv format does not support %T", v)
+	}
+	return cw.Error()
+}
+
+func (e *CSVEncoder) writeSheetDataList(cw *csvWriter, sheetName string, l SheetDataList) {
+	keys := map[string]bool{}
+	for _, row := range l {
+		for k := range row {
+			keys[k] = true
+		}
+	}
+	columns := make([]string, 0, len(keys))
+	for k := range keys {
+		columns = append(columns, k)
+	}
+	tree := columnTree(e.opts.ColumnOrder[sheetName])
+	columns = orderedKeys(columns, tree[""], e.opts.Output.KeyOrder)
+
+	cw.Write(columns)
+	for _, row := range l {
+		record := make([]string, len(columns))
+		for i, column := range columns {
+			record[i] = csvValue(e.opts.orderValue(tree, column, row[column]))
+		}
+		cw.Write(record)
+	}
+}
+
+func (e *CSVEncoder) writeSheetColumns(cw *csvWriter, columns SheetColumns) {
+	cw.Write([]string{"column", "index", "valueType"})
+	writeFlattenColumns(cw, "", columns)
+}
+
+// writeFlattenColumns writes leaf columns in order of index with paths like stats.hp and rewards[].id
+func writeFlattenColumns(cw *csvWriter, prefix string, columns SheetColumns) {
+	names := make([]string, 0, len(columns))
+	for name := range columns {
+		names = append(names, name)
+	}
+	sort.Slice(names, func(i, j int) bool {
+		return columns[names[i]].Index < columns[names[j]].Index
+	})
+
+	for _, name := range names {
+		path := name
+		if prefix != "" {
+			path = prefix + "." + name
+		}
+		writeFlattenColumn(cw, path, columns[name])
+	}
+}
+
+func writeFlattenColumn(cw *csvWriter, path string, info ColumnInfo) {
+	switch {
+	case info.Fields != nil:
+		writeFlattenColumns(cw, path, info.Fields)
+	case info.Items != nil:
+		writeFlattenColumn(cw, path+"[]", *info.Items)
+	default:
+		cw.Write([]string{path, strconv.Itoa(info.Index), info.ValueType})
+	}
+}
+
+func csvValue(v interface{}) string {
+	switch value := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return value
+	case int64:
+		return strconv.FormatInt(value, 10)
+	case float64:
+		return strconv.FormatFloat(value, 'f', -1, 64)
+	case bool:
+		return strconv.FormatBool(value)
+	case orderedObject, []interface{}:
+		if bs, err := json.Marshal(value); err == nil {
+			return string(bs)
+		}
+	}
+	return fmt.Sprint(v)
+}
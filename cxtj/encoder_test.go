@@ -0,0 +1,442 @@
+package cxtj
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path"
+	"strings"
+	"testing"
+
+	"github.com/BurntSushi/toml"
+	yaml "gopkg.in/yaml.v2"
+
+	"github.com/kama2vern/cxtj/config"
+)
+
+func newEncoderTestXlsxMap() XlsxMap {
+	return XlsxMap{
+		"sheet": {
+			{"id": int64(1), "name": "alpha", "rate": 1.5, "enabled": true},
+			{"id": int64(2), "name": "beta, gamma", "rate": nil, "enabled": false},
+		},
+	}
+}
+
+func TestFormatFromFileName(t *testing.T) {
+	for fileName, expect := range map[string]string{
+		"out.json":     FormatJSON,
+		"out.YAML":     FormatYAML,
+		"out.yml":      FormatYAML,
+		"out.toml":     FormatTOML,
+		"dir/out.csv":  FormatCSV,
+		"{sheet}.json": FormatJSON,
+	} {
+		format, ok := FormatFromFileName(fileName)
+		if !ok || format != expect {
+			t.Errorf("invalid format of %s. expect: %s, actual: %s", fileName, expect, format)
+		}
+	}
+
+	for _, fileName := range []string{"out", "out.txt", "{sheet}.{ext}"} {
+		if format, ok := FormatFromFileName(fileName); ok {
+			t.Errorf("format of %s should not be detected, actual: %s", fileName, format)
+		}
+	}
+}
+
+func TestYAMLEncoder(t *testing.T) {
+	var buf bytes.Buffer
+	if err := NewYAMLEncoder(EncoderOptions{}).Encode(&buf, newEncoderTestXlsxMap()); err != nil {
+		t.Fatal(err)
+	}
+
+	result := map[string][]map[string]interface{}{}
+	if err := yaml.Unmarshal(buf.Bytes(), &result); err != nil {
+		t.Fatal(err)
+	}
+	if result["sheet"][0]["id"] != 1 || result["sheet"][1]["name"] != "beta, gamma" || result["sheet"][1]["rate"] != nil {
+		t.Errorf("Mismatch contents. actual %v", result["sheet"])
+	}
+}
+
+func TestTOMLEncoder(t *testing.T) {
+	var buf bytes.Buffer
+	if err := NewTOMLEncoder(EncoderOptions{}).Encode(&buf, newEncoderTestXlsxMap()); err != nil {
+		t.Fatal(err)
+	}
+
+	result := map[string][]map[string]interface{}{}
+	if _, err := toml.Decode(buf.String(), &result); err != nil {
+		t.Fatal(err)
+	}
+	if result["sheet"][0]["id"] != int64(1) || result["sheet"][0]["rate"] != 1.5 {
+		t.Errorf("Mismatch contents. actual %v", result["sheet"])
+	}
+	if _, ok := result["sheet"][1]["rate"]; ok {
+		t.Errorf("empty value should be omitted in toml. actual %v", result["sheet"][1])
+	}
+}
+
+func TestCSVEncoder(t *testing.T) {
+	var buf bytes.Buffer
+	if err := NewCSVEncoder(EncoderOptions{}).Encode(&buf, newEncoderTestXlsxMap()); err != nil {
+		t.Fatal(err)
+	}
+
+	except := "enabled,id,name,rate\ntrue,1,alpha,1.5\nfalse,2,\"beta, gamma\",\n"
+	if buf.String() != except {
+		t.Errorf("Mismatch csv. except %q, actual %q", except, buf.String())
+	}
+
+	m := newEncoderTestXlsxMap()
+	m["nextSheet"] = SheetDataList{}
+	if err := NewCSVEncoder(EncoderOptions{}).Encode(&buf, m); err == nil {
+		t.Error("csv of multiple sheets should be error")
+	}
+}
+
+func TestCSVEncoderHeader(t *testing.T) {
+	var buf bytes.Buffer
+	columns := SheetColumns{
+		"name": ColumnInfo{Index: 1, ValueType: "string"},
+		"id":   ColumnInfo{Index: 0, ValueType: "int"},
+	}
+	if err := NewCSVEncoder(EncoderOptions{}).EncodeSheet(&buf, "sheet", columns); err != nil {
+		t.Fatal(err)
+	}
+
+	except := "column,index,valueType\nid,0,int\nname,1,string\n"
+	if buf.String() != except {
+		t.Errorf("Mismatch csv. except %q, actual %q", except, buf.String())
+	}
+}
+
+func TestConvertIntoFormatDetectedFromExtension(t *testing.T) {
+	dir, _ := os.Getwd()
+	inputFiles := []string{
+		path.Join(dir, "..", "test", "excels", "convert_test.xlsx"),
+	}
+	outputDir, err := ioutil.TempDir("", "cxtj")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(outputDir)
+
+	c, err := NewConverter(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	outputFile := path.Join(outputDir, "convert_test.yaml")
+	if err := c.Convert(inputFiles, outputFile, false); err != nil {
+		t.Fatal(err)
+	}
+	bs, err := ioutil.ReadFile(outputFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	result := map[string][]map[string]interface{}{}
+	if err := yaml.Unmarshal(bs, &result); err != nil {
+		t.Fatal(err)
+	}
+	if len(result["sheet"]) != 4 {
+		t.Errorf("Invalid contents size. except %d, actual %d", 4, len(result["sheet"]))
+	}
+}
+
+func TestConvertIntoMultipleCSV(t *testing.T) {
+	dir, _ := os.Getwd()
+	inputFiles := []string{
+		path.Join(dir, "..", "test", "excels", "convert_test.xlsx"),
+		path.Join(dir, "..", "test", "excels", "convert_test2.xlsx"),
+	}
+	outputDir, err := ioutil.TempDir("", "cxtj")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(outputDir)
+
+	conf := *config.DefaultConfig
+	conf.Output.Format = FormatCSV
+
+	c, err := NewConverter(&conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Convert(inputFiles, outputDir, true); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, sheetName := range []string{"sheet", "nextSheet"} {
+		bs, err := ioutil.ReadFile(path.Join(outputDir, sheetName+".csv"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		lines := strings.Split(strings.TrimSpace(string(bs)), "\n")
+		if len(lines) != 5 {
+			t.Errorf("Invalid csv lines of %s. except %d, actual %d", sheetName, 5, len(lines))
+		}
+	}
+}
+
+func TestNewConverterWithUnknownFormat(t *testing.T) {
+	conf := *config.DefaultConfig
+	conf.Output.Format = "xml"
+	if _, err := NewConverter(&conf); err == nil {
+		t.Error("unknown output format should be error")
+	}
+}
+
+func TestJSONEncoderOutputOptions(t *testing.T) {
+	m := XlsxMap{
+		"sheet": {
+			{"name": "alpha", "id": int64(1)},
+		},
+	}
+	opts := EncoderOptions{
+		Output: config.OutputConfig{
+			Pretty:   true,
+			Indent:   "\t",
+			KeyOrder: config.KeyOrderColumn,
+			Shape:    config.OutputShapeArray,
+		},
+		ColumnOrder: map[string][]string{"sheet": {"name", "id"}},
+	}
+
+	var buf bytes.Buffer
+	if err := NewJSONEncoder(opts).Encode(&buf, m); err != nil {
+		t.Fatal(err)
+	}
+	except := "[\n\t{\n\t\t\"name\": \"sheet\",\n\t\t\"rows\": [\n\t\t\t{\n\t\t\t\t\"name\": \"alpha\",\n\t\t\t\t\"id\": 1\n\t\t\t}\n\t\t]\n\t}\n]\n"
+	if buf.String() != except {
+		t.Errorf("Mismatch json. except %q, actual %q", except, buf.String())
+	}
+}
+
+func TestJSONEncoderAlphabeticalKeyOrder(t *testing.T) {
+	opts := EncoderOptions{
+		ColumnOrder: map[string][]string{"sheet": {"name", "id"}},
+	}
+
+	var buf bytes.Buffer
+	if err := NewJSONEncoder(opts).EncodeSheet(&buf, "sheet", SheetDataList{{"name": "alpha", "id": int64(1)}}); err != nil {
+		t.Fatal(err)
+	}
+	except := "[{\"id\":1,\"name\":\"alpha\"}]\n"
+	if buf.String() != except {
+		t.Errorf("Mismatch json. except %q, actual %q", except, buf.String())
+	}
+}
+
+func TestTOMLEncoderColumnKeyOrder(t *testing.T) {
+	opts := EncoderOptions{
+		Output:      config.OutputConfig{KeyOrder: config.KeyOrderColumn},
+		ColumnOrder: map[string][]string{"sheet": {"name", "id", "stats.mp", "stats.hp"}},
+	}
+	m := XlsxMap{"sheet": {{"id": int64(1), "name": "alpha", "stats": map[string]interface{}{"hp": int64(10), "mp": int64(5)}}}}
+
+	var buf bytes.Buffer
+	if err := NewTOMLEncoder(opts).Encode(&buf, m); err != nil {
+		t.Fatal(err)
+	}
+	except := "[[sheet]]\nname = \"alpha\"\nid = 1\n[sheet.stats]\nmp = 5\nhp = 10\n"
+	if buf.String() != except {
+		t.Errorf("Mismatch toml. except %q, actual %q", except, buf.String())
+	}
+}
+
+func TestTOMLEncoderKeyWhichCannotBeFieldTag(t *testing.T) {
+	m := XlsxMap{
+		"weapons, long": {{"id": int64(1)}},
+		"z":             {{"id": int64(2)}},
+	}
+	var buf bytes.Buffer
+	if err := NewTOMLEncoder(EncoderOptions{}).Encode(&buf, m); err != nil {
+		t.Fatal(err)
+	}
+	sheets := map[string][]map[string]interface{}{}
+	if _, err := toml.Decode(buf.String(), &sheets); err != nil {
+		t.Fatal(err)
+	}
+	if sheets["weapons, long"][0]["id"] != int64(1) || sheets["z"][0]["id"] != int64(2) {
+		t.Errorf("Mismatch sheets in toml. actual %v", sheets)
+	}
+
+	opts := EncoderOptions{
+		Output:      config.OutputConfig{RowShape: config.RowShapeKeyed},
+		PrimaryKeys: map[string]string{"sheet": "name"},
+	}
+	m = XlsxMap{"sheet": {
+		{"name": "Sword, Long", "attack": int64(10)},
+		{"name": "Axe", "attack": int64(12)},
+	}}
+	buf.Reset()
+	if err := NewTOMLEncoder(opts).Encode(&buf, m); err != nil {
+		t.Fatal(err)
+	}
+	rows := map[string]map[string]map[string]interface{}{}
+	if _, err := toml.Decode(buf.String(), &rows); err != nil {
+		t.Fatal(err)
+	}
+	if rows["sheet"]["Sword, Long"]["attack"] != int64(10) || rows["sheet"]["Axe"]["attack"] != int64(12) {
+		t.Errorf("Mismatch keyed rows in toml. actual %v", rows)
+	}
+}
+
+func TestCSVEncoderNestedColumnKeyOrder(t *testing.T) {
+	opts := EncoderOptions{
+		Output:      config.OutputConfig{KeyOrder: config.KeyOrderColumn},
+		ColumnOrder: map[string][]string{"sheet": {"stats.mp", "name", "id", "stats.hp"}},
+	}
+	l := SheetDataList{{"id": int64(1), "name": "alpha", "stats": map[string]interface{}{"hp": int64(10), "mp": int64(5)}}}
+
+	var buf bytes.Buffer
+	if err := NewCSVEncoder(opts).EncodeSheet(&buf, "sheet", l); err != nil {
+		t.Fatal(err)
+	}
+	except := "stats,name,id\n\"{\"\"mp\"\":5,\"\"hp\"\":10}\",alpha,1\n"
+	if buf.String() != except {
+		t.Errorf("Mismatch csv. except %q, actual %q", except, buf.String())
+	}
+}
+
+func TestTOMLEncoderArrayShape(t *testing.T) {
+	opts := EncoderOptions{Output: config.OutputConfig{Shape: config.OutputShapeArray}}
+	var buf bytes.Buffer
+	if err := NewTOMLEncoder(opts).Encode(&buf, newEncoderTestXlsxMap()); err == nil {
+		t.Error("array shape should be error in toml format")
+	}
+}
+
+func TestEncodeKeyedRows(t *testing.T) {
+	opts := EncoderOptions{
+		Output:      config.OutputConfig{RowShape: config.RowShapeKeyed},
+		PrimaryKeys: map[string]string{"sheet": "id"},
+	}
+	m := newEncoderTestXlsxMap()
+	m["other"] = SheetDataList{{"id": int64(3)}}
+
+	var buf bytes.Buffer
+	if err := NewJSONEncoder(opts).Encode(&buf, m); err != nil {
+		t.Fatal(err)
+	}
+	except := `{"other":[{"id":3}],"sheet":{"1":{"enabled":true,"id":1,"name":"alpha","rate":1.5},"2":{"enabled":false,"id":2,"name":"beta, gamma","rate":null}}}` + "\n"
+	if buf.String() != except {
+		t.Errorf("Mismatch json. except %q, actual %q", except, buf.String())
+	}
+
+	buf.Reset()
+	if err := NewTOMLEncoder(opts).EncodeSheet(&buf, "sheet", m["sheet"]); err != nil {
+		t.Fatal(err)
+	}
+	result := map[string]map[string]map[string]interface{}{}
+	if _, err := toml.Decode(buf.String(), &result); err != nil {
+		t.Fatal(err)
+	}
+	if result["sheet"]["2"]["name"] != "beta, gamma" {
+		t.Errorf("Mismatch keyed rows in toml. actual %v", result)
+	}
+
+	opts.Output.RowShape = config.RowShapeArray
+	buf.Reset()
+	if err := NewJSONEncoder(opts).EncodeSheet(&buf, "other", m["other"]); err != nil {
+		t.Fatal(err)
+	}
+	if buf.String() != "[{\"id\":3}]\n" {
+		t.Errorf("rows should be an array by array row shape. actual %q", buf.String())
+	}
+}
+
+func TestConvertWithOutputConfig(t *testing.T) {
+	dir, _ := os.Getwd()
+	inputFiles := []string{
+		path.Join(dir, "..", "test", "excels", "convert_test.xlsx"),
+	}
+	outputDir, err := ioutil.TempDir("", "cxtj")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(outputDir)
+
+	conf := *config.DefaultConfig
+	conf.Output.Pretty = true
+	conf.Output.KeyOrder = config.KeyOrderColumn
+	conf.Output.Newline = config.NewlineCRLF
+	conf.Output.FileName = "{book}-{sheet}.{ext}"
+
+	c, err := NewConverter(&conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Convert(inputFiles, outputDir, true); err != nil {
+		t.Fatal(err)
+	}
+
+	bs, err := ioutil.ReadFile(path.Join(outputDir, "convert_test-sheet.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Count(string(bs), "\r\n") != strings.Count(string(bs), "\n") {
+		t.Error("all newlines should be crlf")
+	}
+	lines := strings.Split(string(bs), "\r\n")
+	if len(lines) < 3 || lines[2] != `    "id": 1,` || lines[3] != `    "characterId": 1001,` {
+		t.Errorf("keys should be in order of columns. actual %q", lines)
+	}
+}
+
+func TestEncodeMultiLineValueWithCRLF(t *testing.T) {
+	l := SheetDataList{
+		{"id": int64(1), "text": "line1\nline2\n"},
+		{"id": int64(2), "text": "line3\nline4"},
+	}
+	opts := EncoderOptions{Output: config.DefaultConfig.Output}
+	opts.Output.Newline = config.NewlineCRLF
+
+	testCases := []struct {
+		format string
+		decode func(bs []byte) ([]string, error)
+	}{
+		{"json", func(bs []byte) ([]string, error) {
+			var rows []map[string]interface{}
+			err := json.Unmarshal(bs, &rows)
+			return []string{rows[0]["text"].(string), rows[1]["text"].(string)}, err
+		}},
+		{"yaml", func(bs []byte) ([]string, error) {
+			var rows []map[string]interface{}
+			err := yaml.Unmarshal(bs, &rows)
+			return []string{rows[0]["text"].(string), rows[1]["text"].(string)}, err
+		}},
+		{"csv", func(bs []byte) ([]string, error) {
+			records, err := csv.NewReader(bytes.NewReader(bs)).ReadAll()
+			return []string{records[1][1], records[2][1]}, err
+		}},
+	}
+
+	for _, tc := range testCases {
+		encoder, err := GetEncoder(tc.format, opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var buf bytes.Buffer
+		if err := encoder.EncodeSheet(&buf, "sheet", l); err != nil {
+			t.Fatal(err)
+		}
+		if !strings.HasSuffix(buf.String(), "\r\n") {
+			t.Errorf("%s: lines should end with crlf. actual %q", tc.format, buf.String())
+		}
+		if strings.Contains(buf.String(), "line1\r\n") || strings.Contains(buf.String(), "line3\r\n") {
+			t.Errorf("%s: newlines in values should not be converted. actual %q", tc.format, buf.String())
+		}
+		texts, err := tc.decode(buf.Bytes())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if texts[0] != "line1\nline2\n" || texts[1] != "line3\nline4" {
+			t.Errorf("%s: multi-line values are broken. actual %q", tc.format, texts)
+		}
+	}
+}
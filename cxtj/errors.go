@@ -0,0 +1,73 @@
+package cxtj
+
+import (
+	"fmt"
+
+	"github.com/tealeg/xlsx"
+)
+
+// ConvertError is an error occurred while converting a xlsx file.
+// It carries the location of the error as much as known.
+// Row and Column are 1-based numbers as shown in Excel, and 0 means unknown.
+type ConvertError struct {
+	File   string
+	Sheet  string
+	Row    int
+	Column int
+	Err    error
+}
+
+// Cell returns the cell name of the error location in Excel format like "B5"
+func (e *ConvertError) Cell() string {
+	if e.Row <= 0 || e.Column <= 0 {
+		return ""
+	}
+	return xlsx.GetCellIDStringFromCoords(e.Column-1, e.Row-1)
+}
+
+func (e *ConvertError) Error() string {
+	location := e.File
+	if e.Sheet != "" {
+		if location != "" {
+			location += ": "
+		}
+		location += e.Sheet
+		if cell := e.Cell(); cell != "" {
+			location += "!" + cell
+		} else if e.Row > 0 {
+			location += fmt.Sprintf(" row %d", e.Row)
+		}
+	}
+
+	if location == "" {
+		return e.Err.Error()
+	}
+	return fmt.Sprintf("%s: %s", location, e.Err)
+}
+
+// Unwrap returns the underlying error
+func (e *ConvertError) Unwrap() error {
+	return e.Err
+}
+
+// newCellError creates ConvertError of the cell from 0-based row and column indices
+func newCellError(sheet string, rowIndex int, columnIndex int, err error) *ConvertError {
+	return &ConvertError{
+		Sheet:  sheet,
+		Row:    rowIndex + 1,
+		Column: columnIndex + 1,
+		Err:    err,
+	}
+}
+
+// withFile sets the xlsx file name into err
+func withFile(err error, file string) error {
+	if err == nil {
+		return nil
+	}
+	if e, ok := err.(*ConvertError); ok {
+		e.File = file
+		return e
+	}
+	return &ConvertError{File: file, Err: err}
+}
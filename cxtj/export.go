@@ -0,0 +1,513 @@
+package cxtj
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/tealeg/xlsx"
+
+	"github.com/kama2vern/cxtj/config"
+	"github.com/kama2vern/cxtj/logger"
+)
+
+// exportColumn is a leaf column written into the key, value-type and comment rows
+type exportColumn struct {
+	name      string
+	path      columnPath
+	valueType string
+	comment   string
+}
+
+// Export reads json of XlsxMap and writes it into a xlsx file, which is the inverse of Convert.
+// headerFile is json of XlsxHeaderMap written by --only-header, and columns are inferred from rows when it is empty.
+// Both object and array shapes of sheets, and keyed rows are accepted.
+func (c *Converter) Export(input string, headerFile string, output string) error {
+	bs, err := ioutil.ReadFile(input)
+	if err != nil {
+		return err
+	}
+	data, err := decodeExportData(bs)
+	if err != nil {
+		return fmt.Errorf("%s: %s", input, err)
+	}
+
+	header := XlsxHeaderMap{}
+	if headerFile != "" {
+		bs, err := ioutil.ReadFile(headerFile)
+		if err != nil {
+			return err
+		}
+		if header, err = decodeExportHeader(bs); err != nil {
+			return fmt.Errorf("%s: %s", headerFile, err)
+		}
+	}
+
+	xFile, err := c.ExportWorkbook(data, header)
+	if err != nil {
+		return err
+	}
+	if err := xFile.Save(output); err != nil {
+		return err
+	}
+	logger.Log("exported", output)
+	return nil
+}
+
+// ExportWorkbook builds a xlsx workbook from XlsxMap on memory, which is the inverse of ConvertWorkbook.
+// Columns of a sheet are laid out by the header if it has the sheet, otherwise they are inferred from rows.
+// The key, value-type and comment rows are placed by the excel formats of the config.
+func (c *Converter) ExportWorkbook(data XlsxMap, header XlsxHeaderMap) (*xlsx.File, error) {
+	xFile := xlsx.NewFile()
+	for _, sheetName := range sortedSheetNames(data) {
+		var columns []exportColumn
+		if sheetColumns, ok := header[sheetName]; ok {
+			var err error
+			if columns, err = headerExportColumns(sheetColumns); err != nil {
+				return nil, &ConvertError{Sheet: sheetName, Err: err}
+			}
+		} else {
+			columns = inferExportColumns(data[sheetName])
+		}
+
+		sheet, err := xFile.AddSheet(sheetName)
+		if err != nil {
+			return nil, &ConvertError{Sheet: sheetName, Err: err}
+		}
+		if err := c.writeExportSheet(sheet, columns, data[sheetName]); err != nil {
+			return nil, err
+		}
+	}
+	return xFile, nil
+}
+
+// writeExportSheet writes the header rows and data rows of columns into the empty sheet
+func (c *Converter) writeExportSheet(sheet *xlsx.Sheet, columns []exportColumn, rows []map[string]interface{}) error {
+	headerRows := map[int]func(column exportColumn) string{}
+	lastHeaderLine := 0
+	for _, excelFormat := range c.config.ExcelFormats {
+		if excelFormat.RowType != config.ExcelFormatRowTypeData && excelFormat.RowLine > lastHeaderLine {
+			lastHeaderLine = excelFormat.RowLine
+		}
+		switch excelFormat.RowType {
+		case config.ExcelFormatRowTypeKey:
+			headerRows[excelFormat.RowLine] = func(column exportColumn) string { return column.name }
+		case config.ExcelFormatRowTypeValueType:
+			headerRows[excelFormat.RowLine] = func(column exportColumn) string { return column.valueType }
+		case config.ExcelFormatRowTypeComment:
+			headerRows[excelFormat.RowLine] = func(column exportColumn) string { return column.comment }
+		}
+	}
+
+	line := 0
+	addRow := func() *xlsx.Row {
+		line++
+		row := sheet.AddRow()
+		if value, ok := headerRows[line]; ok {
+			for _, column := range columns {
+				row.AddCell().SetString(value(column))
+			}
+		}
+		return row
+	}
+
+	for line < c.config.DataRowLine()-1 {
+		addRow()
+	}
+	for i, data := range rows {
+		row := addRow()
+		for c.config.IsHeaderRowLine(line) {
+			row = addRow()
+		}
+		for j, column := range columns {
+			if err := c.setExportCell(row.AddCell(), column, getPathValue(data, column.path)); err != nil {
+				return &ConvertError{Sheet: sheet.Name, Row: line, Column: j + 1, Err: fmt.Errorf("row %d: %s", i+1, err)}
+			}
+		}
+	}
+	// header rows may be placed after data rows
+	for line < lastHeaderLine {
+		addRow()
+	}
+	return nil
+}
+
+// setExportCell sets the value into the cell so that it is converted into the same value by the value type
+func (c *Converter) setExportCell(cell *xlsx.Cell, column exportColumn, v interface{}) error {
+	if v == nil {
+		return nil
+	}
+
+	valueType, _ := parsePrimaryKeyValueType(column.valueType)
+	if elementType, delimiter, ok := ParseArrayValueType(valueType); ok {
+		elements, ok := v.([]interface{})
+		if !ok {
+			return fmt.Errorf("value of %s should be an array, but %T", column.name, v)
+		}
+		if delimiter == "" {
+			delimiter = c.config.ElementDelimiter()
+		}
+		values := make([]string, len(elements))
+		for i, element := range elements {
+			value := exportString(element)
+			if elementType == ValueTypeString {
+				value = escapeArrayElement(value, delimiter)
+			}
+			values[i] = value
+		}
+		cell.SetString(strings.Join(values, delimiter))
+		return nil
+	}
+
+	switch baseValueType(valueType) {
+	case ValueTypeInt, ValueTypeLong:
+		if i, ok := exportInteger(v); ok {
+			cell.SetInt64(i)
+			return nil
+		}
+	case ValueTypeFloat, ValueTypeDouble:
+		if f, ok := exportFloat(v); ok {
+			cell.SetFloat(f)
+			return nil
+		}
+	case ValueTypeBool:
+		if b, ok := v.(bool); ok {
+			cell.SetBool(b)
+			return nil
+		}
+	}
+	cell.SetString(exportString(v))
+	return nil
+}
+
+// escapeArrayElement escapes the delimiter and a backslash in an element, which is the inverse of splitArrayValue
+func escapeArrayElement(element string, delimiter string) string {
+	element = strings.Replace(element, "\\", "\\\\", -1)
+	return strings.Replace(element, delimiter, "\\"+delimiter, -1)
+}
+
+// exportString formats a value as the text of a cell
+func exportString(v interface{}) string {
+	switch value := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return value
+	case json.Number:
+		return value.String()
+	case float64:
+		return strconv.FormatFloat(value, 'f', -1, 64)
+	case map[string]interface{}, []interface{}:
+		if bs, err := json.Marshal(value); err == nil {
+			return string(bs)
+		}
+	}
+	return fmt.Sprint(v)
+}
+
+func exportInteger(v interface{}) (int64, bool) {
+	switch value := v.(type) {
+	case int64:
+		return value, true
+	case int:
+		return int64(value), true
+	case float64:
+		return int64(value), value == math.Trunc(value)
+	case json.Number:
+		i, err := value.Int64()
+		return i, err == nil
+	}
+	return 0, false
+}
+
+func exportFloat(v interface{}) (float64, bool) {
+	switch value := v.(type) {
+	case float64:
+		return value, true
+	case int64:
+		return float64(value), true
+	case int:
+		return float64(value), true
+	case json.Number:
+		f, err := value.Float64()
+		return f, err == nil
+	}
+	return 0, false
+}
+
+// headerExportColumns flattens nested columns into leaf columns in order of index.
+// Arrays are expanded into columns of each element like rewards[0].id and rewards[1].id.
+// A column name which is not a valid column path is an error.
+func headerExportColumns(columns SheetColumns) ([]exportColumn, error) {
+	ret := []exportColumn{}
+	if err := appendHeaderExportColumns(&ret, "", columns); err != nil {
+		return nil, err
+	}
+	return ret, nil
+}
+
+func appendHeaderExportColumns(ret *[]exportColumn, prefix string, columns SheetColumns) error {
+	names := make([]string, 0, len(columns))
+	for name := range columns {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	sort.SliceStable(names, func(i, j int) bool {
+		return columns[names[i]].Index < columns[names[j]].Index
+	})
+
+	for _, name := range names {
+		if name == "" {
+			continue
+		}
+		path := name
+		if prefix != "" {
+			path = prefix + "." + name
+		}
+		if err := appendHeaderExportColumn(ret, path, columns[name]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func appendHeaderExportColumn(ret *[]exportColumn, name string, info ColumnInfo) error {
+	switch {
+	case info.Fields != nil:
+		return appendHeaderExportColumns(ret, name, info.Fields)
+	case info.Items != nil:
+		for i := 0; i < info.Length; i++ {
+			if err := appendHeaderExportColumn(ret, fmt.Sprintf("%s[%d]", name, i), *info.Items); err != nil {
+				return err
+			}
+		}
+	default:
+		path, err := parseColumnPath(name)
+		if err != nil {
+			return err
+		}
+		valueType := info.ValueType
+		if info.PrimaryKey {
+			valueType += "!"
+		}
+		*ret = append(*ret, exportColumn{name: name, path: path, valueType: valueType, comment: info.Comment})
+	}
+	return nil
+}
+
+// inferExportColumns infers leaf columns and value types from rows when the header is not given.
+// Keys of objects are sorted by name, and an array of objects is expanded into columns of each element.
+// An array of the other values is an array value type like int[].
+func inferExportColumns(rows []map[string]interface{}) []exportColumn {
+	names := []string{}
+	valueTypes := map[string]string{}
+	for _, row := range rows {
+		inferExportObject(row, "", &names, valueTypes)
+	}
+
+	ret := make([]exportColumn, 0, len(names))
+	for _, name := range names {
+		path, err := parseColumnPath(name)
+		if err != nil {
+			continue
+		}
+		valueType := valueTypes[name]
+		if valueType == "" {
+			valueType = ValueTypeString
+		} else if valueType == "[]" {
+			valueType = ValueTypeString + "[]"
+		}
+		ret = append(ret, exportColumn{name: name, path: path, valueType: valueType})
+	}
+	return ret
+}
+
+func inferExportObject(m map[string]interface{}, prefix string, names *[]string, valueTypes map[string]string) {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	for _, k := range keys {
+		name := k
+		if prefix != "" {
+			name = prefix + "." + k
+		}
+		inferExportValue(m[k], name, names, valueTypes)
+	}
+}
+
+func inferExportValue(v interface{}, name string, names *[]string, valueTypes map[string]string) {
+	switch value := v.(type) {
+	case map[string]interface{}:
+		inferExportObject(value, name, names, valueTypes)
+		return
+	case []interface{}:
+		if isObjectArray(value) {
+			for i, element := range value {
+				inferExportValue(element, fmt.Sprintf("%s[%d]", name, i), names, valueTypes)
+			}
+			return
+		}
+		elementType := ""
+		for _, element := range value {
+			elementType = mergeInferredValueType(elementType, inferValueType(element))
+		}
+		addInferredColumn(name, elementType+"[]", names, valueTypes)
+		return
+	}
+	addInferredColumn(name, inferValueType(v), names, valueTypes)
+}
+
+// isObjectArray reports whether the array has an object element
+func isObjectArray(l []interface{}) bool {
+	for _, element := range l {
+		if _, ok := element.(map[string]interface{}); ok {
+			return true
+		}
+	}
+	return false
+}
+
+func addInferredColumn(name string, valueType string, names *[]string, valueTypes map[string]string) {
+	former, ok := valueTypes[name]
+	if !ok {
+		*names = append(*names, name)
+	}
+	if strings.HasSuffix(former, "[]") && strings.HasSuffix(valueType, "[]") {
+		valueTypes[name] = mergeInferredValueType(strings.TrimSuffix(former, "[]"), strings.TrimSuffix(valueType, "[]")) + "[]"
+		return
+	}
+	valueTypes[name] = mergeInferredValueType(former, valueType)
+}
+
+// inferValueType returns the value type of a value, or "" for null
+func inferValueType(v interface{}) string {
+	switch value := v.(type) {
+	case nil:
+		return ""
+	case bool:
+		return ValueTypeBool
+	case int64, int:
+		if i, _ := exportInteger(value); i < math.MinInt32 || i > math.MaxInt32 {
+			return ValueTypeLong
+		}
+		return ValueTypeInt
+	case float64:
+		return ValueTypeDouble
+	case json.Number:
+		if i, err := value.Int64(); err == nil {
+			return inferValueType(i)
+		}
+		return ValueTypeDouble
+	}
+	return ValueTypeString
+}
+
+// mergeInferredValueType returns the value type which can hold values of both value types
+func mergeInferredValueType(t1 string, t2 string) string {
+	switch {
+	case t1 == "" || t1 == t2:
+		return t2
+	case t2 == "":
+		return t1
+	}
+
+	rank := map[string]int{ValueTypeInt: 1, ValueTypeLong: 2, ValueTypeDouble: 3}
+	if rank[t1] > 0 && rank[t2] > 0 {
+		if rank[t1] > rank[t2] {
+			return t1
+		}
+		return t2
+	}
+	return ValueTypeString
+}
+
+// decodeExportData decodes json of XlsxMap in object or array shape.
+// Rows of a sheet are an array, or an object keyed by the primary key whose order is kept.
+func decodeExportData(bs []byte) (XlsxMap, error) {
+	sheets := map[string]json.RawMessage{}
+	if isJSONArray(bs) {
+		l := []struct {
+			Name string          `json:"name"`
+			Rows json.RawMessage `json:"rows"`
+		}{}
+		if err := json.Unmarshal(bs, &l); err != nil {
+			return nil, err
+		}
+		for _, sheet := range l {
+			sheets[sheet.Name] = sheet.Rows
+		}
+	} else if err := json.Unmarshal(bs, &sheets); err != nil {
+		return nil, err
+	}
+
+	ret := XlsxMap{}
+	for sheetName, raw := range sheets {
+		rows, err := decodeExportRows(raw)
+		if err != nil {
+			return nil, fmt.Errorf("sheet %s: %s", sheetName, err)
+		}
+		ret[sheetName] = rows
+	}
+	return ret, nil
+}
+
+func decodeExportRows(raw json.RawMessage) ([]map[string]interface{}, error) {
+	dec := json.NewDecoder(bytes.NewReader(raw))
+	dec.UseNumber()
+	if isJSONArray(raw) {
+		rows := []map[string]interface{}{}
+		err := dec.Decode(&rows)
+		return rows, err
+	}
+
+	// keyed rows
+	if _, err := dec.Token(); err != nil {
+		return nil, err
+	}
+	rows := []map[string]interface{}{}
+	for dec.More() {
+		if _, err := dec.Token(); err != nil {
+			return nil, err
+		}
+		row := map[string]interface{}{}
+		if err := dec.Decode(&row); err != nil {
+			return nil, err
+		}
+		rows = append(rows, row)
+	}
+	return rows, nil
+}
+
+// decodeExportHeader decodes json of XlsxHeaderMap in object or array shape
+func decodeExportHeader(bs []byte) (XlsxHeaderMap, error) {
+	ret := XlsxHeaderMap{}
+	if !isJSONArray(bs) {
+		err := json.Unmarshal(bs, &ret)
+		return ret, err
+	}
+
+	l := []struct {
+		Name    string       `json:"name"`
+		Columns SheetColumns `json:"columns"`
+	}{}
+	if err := json.Unmarshal(bs, &l); err != nil {
+		return nil, err
+	}
+	for _, sheet := range l {
+		ret[sheet.Name] = sheet.Columns
+	}
+	return ret, nil
+}
+
+func isJSONArray(bs []byte) bool {
+	trimmed := bytes.TrimSpace(bs)
+	return len(trimmed) > 0 && trimmed[0] == '['
+}
@@ -0,0 +1,201 @@
+package cxtj
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/kama2vern/cxtj/config"
+)
+
+// exportAndConvert writes the workbook and converts it again
+func exportAndConvert(t *testing.T, c *Converter, data XlsxMap, header XlsxHeaderMap) (XlsxMap, XlsxHeaderMap) {
+	xFile, err := c.ExportWorkbook(data, header)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	if err := xFile.Write(&buf); err != nil {
+		t.Fatal(err)
+	}
+
+	converted, err := c.ConvertReader(bytes.NewReader(buf.Bytes()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	convertedHeader, err := c.ConvertReaderIntoHeader(bytes.NewReader(buf.Bytes()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return converted, convertedHeader
+}
+
+func TestExportWorkbookWithHeader(t *testing.T) {
+	f := newTestXlsxFile(t, "sheet", [][]string{
+		{"id", "name", "rate", "enabled", "tags", "stats.hp", "rewards[0].id", "rewards[1].id"},
+		{"int!", "string", "double", "bool", "string[|]", "long", "int", "int"},
+		{"ID", "Name", "Rate", "Enabled", "Tags", "HP", "Reward", "Reward"},
+		{"1", "001", "0.5", "true", "a|b\\|c", "10000000000", "1", "2"},
+		{"2", "", "", "false", "", "", "3", ""},
+	})
+
+	c, err := NewConverter(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := c.ConvertWorkbook(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	header, err := c.ConvertWorkbookIntoHeader(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	converted, convertedHeader := exportAndConvert(t, c, data, header)
+	if !reflect.DeepEqual(converted, data) {
+		t.Errorf("exported data is different. expect: %v, actual: %v", data, converted)
+	}
+	if !reflect.DeepEqual(convertedHeader, header) {
+		t.Errorf("exported header is different. expect: %v, actual: %v", header, convertedHeader)
+	}
+}
+
+func TestExportWorkbookInfersColumns(t *testing.T) {
+	data := XlsxMap{
+		"sheet": {
+			{"id": int64(1), "name": "alpha", "rate": 0.5, "tags": []interface{}{"a", "b,c"}, "stats": map[string]interface{}{"hp": int64(10000000000)}},
+			{"id": int64(2), "name": "beta", "rate": int64(1), "tags": []interface{}{}, "stats": map[string]interface{}{"hp": nil}},
+		},
+	}
+
+	conf := *config.DefaultConfig
+	conf.ExcelFormats = []config.ExcelFormat{
+		{RowType: config.ExcelFormatRowTypeValueType, RowLine: 1},
+		{RowType: config.ExcelFormatRowTypeKey, RowLine: 3},
+		{RowType: config.ExcelFormatRowTypeData, RowLine: 5},
+	}
+	c, err := NewConverter(&conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	converted, header := exportAndConvert(t, c, data, nil)
+	expect := XlsxMap{
+		"sheet": {
+			{"id": int64(1), "name": "alpha", "rate": 0.5, "tags": []interface{}{"a", "b,c"}, "stats": map[string]interface{}{"hp": int64(10000000000)}},
+			{"id": int64(2), "name": "beta", "rate": float64(1), "tags": []interface{}{}, "stats": map[string]interface{}{"hp": nil}},
+		},
+	}
+	if !reflect.DeepEqual(converted, expect) {
+		t.Errorf("exported data is different. expect: %v, actual: %v", expect, converted)
+	}
+	for column, valueType := range map[string]string{"id": "int", "name": "string", "rate": "double", "tags": "string[]"} {
+		if header["sheet"][column].ValueType != valueType {
+			t.Errorf("invalid inferred value type of %s. expect: %s, actual: %s", column, valueType, header["sheet"][column].ValueType)
+		}
+	}
+}
+
+func TestExport(t *testing.T) {
+	dir, err := ioutil.TempDir("", "cxtj")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	input := filepath.Join(dir, "data.json")
+	output := filepath.Join(dir, "data.xlsx")
+	// keyed rows keep the order of keys
+	json := `{"characters": {"2": {"id": 2, "name": "beta"}, "1": {"id": 1, "name": "alpha"}}}`
+	if err := ioutil.WriteFile(input, []byte(json), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	c, err := NewConverter(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Export(input, "", output); err != nil {
+		t.Fatal(err)
+	}
+
+	f, err := os.Open(output)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	converted, err := c.ConvertReader(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expect := XlsxMap{
+		"characters": {
+			{"id": int64(2), "name": "beta"},
+			{"id": int64(1), "name": "alpha"},
+		},
+	}
+	if !reflect.DeepEqual(converted, expect) {
+		t.Errorf("exported data is different. expect: %v, actual: %v", expect, converted)
+	}
+}
+
+func TestExportWithHeaderFile(t *testing.T) {
+	dir, _ := os.Getwd()
+	xlsxFile := path.Join(dir, "..", "test", "excels", "convert_test.xlsx")
+
+	outputDir, err := ioutil.TempDir("", "cxtj")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(outputDir)
+	dataFile := filepath.Join(outputDir, "data.json")
+	headerFile := filepath.Join(outputDir, "header.json")
+	output := filepath.Join(outputDir, "data.xlsx")
+
+	c, err := NewConverter(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Convert([]string{xlsxFile}, dataFile, false); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.ConvertIntoHeader([]string{xlsxFile}, headerFile, false); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Export(dataFile, headerFile, output); err != nil {
+		t.Fatal(err)
+	}
+
+	expect, err := c.ReadHeader([]string{xlsxFile})
+	if err != nil {
+		t.Fatal(err)
+	}
+	actual, err := c.ReadHeader([]string{output})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(actual, expect) {
+		t.Errorf("exported header is different. expect: %v, actual: %v", expect, actual)
+	}
+}
+
+func TestExportWorkbookWithInvalidHeaderColumn(t *testing.T) {
+	c, err := NewConverter(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data := XlsxMap{"sheet": {{"id": int64(1)}}}
+	header := XlsxHeaderMap{"sheet": {
+		"id":     ColumnInfo{Index: 0, ValueType: "int"},
+		"bad[x]": ColumnInfo{Index: 1, ValueType: "int"},
+	}}
+	_, err = c.ExportWorkbook(data, header)
+	if convertErr, ok := err.(*ConvertError); !ok || convertErr.Sheet != "sheet" {
+		t.Errorf("invalid column of header should be ConvertError of the sheet. actual %v", err)
+	}
+}
@@ -0,0 +1,330 @@
+package cxtj
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/kama2vern/cxtj/config"
+	"github.com/kama2vern/cxtj/logger"
+)
+
+// workbook is converted data of one xlsx file
+type workbook struct {
+	file   string
+	sheets XlsxMap
+	// columns is column names of each sheet in order of columns
+	columns map[string][]string
+	// primaryKeys is the primary key column of each sheet which has it
+	primaryKeys map[string]string
+}
+
+// workbookHeader is converted header of one xlsx file
+type workbookHeader struct {
+	file   string
+	sheets XlsxHeaderMap
+}
+
+// sheetSource is one of the sheets of converted xlsx files
+type sheetSource struct {
+	file  string
+	sheet string
+}
+
+// DuplicateSheet is a sheet name which appears in more than one xlsx file
+type DuplicateSheet struct {
+	Sheet string
+	Files []string
+}
+
+func (d DuplicateSheet) String() string {
+	return fmt.Sprintf("%s (%s)", d.Sheet, strings.Join(d.Files, ", "))
+}
+
+// DuplicateSheetError is returned when duplicate sheets are found and the policy is error
+type DuplicateSheetError struct {
+	Duplicates []DuplicateSheet
+}
+
+func (e *DuplicateSheetError) Error() string {
+	list := make([]string, len(e.Duplicates))
+	for i, d := range e.Duplicates {
+		list[i] = d.String()
+	}
+	return fmt.Sprintf("duplicate sheet names are found: %s", strings.Join(list, ", "))
+}
+
+// sortedSheetNames returns sheet names of XlsxMap in alphabetical order
+func sortedSheetNames(m XlsxMap) []string {
+	ret := make([]string, 0, len(m))
+	for sheetName := range m {
+		ret = append(ret, sheetName)
+	}
+	sort.Strings(ret)
+	return ret
+}
+
+// sortedHeaderSheetNames returns sheet names of XlsxHeaderMap in alphabetical order
+func sortedHeaderSheetNames(m XlsxHeaderMap) []string {
+	ret := make([]string, 0, len(m))
+	for sheetName := range m {
+		ret = append(ret, sheetName)
+	}
+	sort.Strings(ret)
+	return ret
+}
+
+// namespacedSheetName returns sheet name prefixed by xlsx file name like "book.sheet"
+func namespacedSheetName(file string, sheet string) string {
+	book := strings.TrimSuffix(filepath.Base(file), filepath.Ext(file))
+	return book + "." + sheet
+}
+
+// resolveSheetNames decides the output sheet name of each source by the duplicate sheet policy.
+// Sources sharing the same key are duplicates.
+// The key is the sheet name for single output and the file path for multiple output.
+func (c *Converter) resolveSheetNames(sources []sheetSource, key func(src sheetSource, sheetName string) string) ([]string, []DuplicateSheet, error) {
+	groups := map[string][]int{}
+	keys := []string{}
+	for i, src := range sources {
+		k := key(src, src.sheet)
+		if _, ok := groups[k]; !ok {
+			keys = append(keys, k)
+		}
+		groups[k] = append(groups[k], i)
+	}
+
+	names := make([]string, len(sources))
+	duplicates := []DuplicateSheet{}
+	for _, k := range keys {
+		indices := groups[k]
+		for _, i := range indices {
+			names[i] = sources[i].sheet
+		}
+		if len(indices) < 2 {
+			continue
+		}
+
+		d := DuplicateSheet{Sheet: sources[indices[0]].sheet}
+		for _, i := range indices {
+			d.Files = append(d.Files, sources[i].file)
+		}
+		duplicates = append(duplicates, d)
+
+		if c.config.DuplicateSheet == config.DuplicateSheetPolicyNamespace {
+			for _, i := range indices {
+				names[i] = namespacedSheetName(sources[i].file, sources[i].sheet)
+			}
+		}
+	}
+
+	if len(duplicates) > 0 && c.config.DuplicateSheet == config.DuplicateSheetPolicyError {
+		return nil, duplicates, &DuplicateSheetError{Duplicates: duplicates}
+	}
+
+	// namespaced names may conflict with the other sheet again
+	if c.config.DuplicateSheet == config.DuplicateSheetPolicyNamespace {
+		resolved := map[string]sheetSource{}
+		for i, src := range sources {
+			k := key(src, names[i])
+			if other, ok := resolved[k]; ok {
+				return nil, duplicates, fmt.Errorf("namespaced sheet name %s conflicts between %s and %s", names[i], other.file, src.file)
+			}
+			resolved[k] = src
+		}
+	}
+
+	return names, duplicates, nil
+}
+
+// logDuplicateSheets outputs summary of the duplicate sheets
+func (c *Converter) logDuplicateSheets(duplicates []DuplicateSheet) {
+	if len(duplicates) == 0 {
+		return
+	}
+	logger.Log("warning", fmt.Sprintf("%d duplicate sheet name(s) are resolved by %s policy", len(duplicates), c.config.DuplicateSheet))
+	for _, d := range duplicates {
+		logger.Log("warning", d.String())
+	}
+}
+
+func workbookSheetSources(books []workbook) []sheetSource {
+	sources := []sheetSource{}
+	for _, book := range books {
+		for _, sheetName := range sortedSheetNames(book.sheets) {
+			sources = append(sources, sheetSource{file: book.file, sheet: sheetName})
+		}
+	}
+	return sources
+}
+
+func workbookHeaderSheetSources(books []workbookHeader) []sheetSource {
+	sources := []sheetSource{}
+	for _, book := range books {
+		for _, sheetName := range sortedHeaderSheetNames(book.sheets) {
+			sources = append(sources, sheetSource{file: book.file, sheet: sheetName})
+		}
+	}
+	return sources
+}
+
+func sheetNameKey(src sheetSource, sheetName string) string {
+	return sheetName
+}
+
+// mergeWorkbooks merges converted xlsx files into one in order of the files
+func (c *Converter) mergeWorkbooks(books []workbook) (workbook, []DuplicateSheet, error) {
+	sources := workbookSheetSources(books)
+	names, duplicates, err := c.resolveSheetNames(sources, sheetNameKey)
+	if err != nil {
+		return workbook{}, duplicates, err
+	}
+
+	ret := workbook{sheets: XlsxMap{}, columns: map[string][]string{}, primaryKeys: map[string]string{}}
+	i := 0
+	for _, book := range books {
+		for _, sheetName := range sortedSheetNames(book.sheets) {
+			if _, ok := ret.sheets[names[i]]; !ok {
+				ret.sheets[names[i]] = SheetDataList{}
+			} else if err := appendablePrimaryKey(ret.primaryKeys, names[i], book.primaryKeys[sheetName], ret.sheets[names[i]], book.sheets[sheetName]); err != nil {
+				return workbook{}, duplicates, &ConvertError{File: book.file, Sheet: sheetName, Err: err}
+			}
+			ret.sheets[names[i]] = append(ret.sheets[names[i]], book.sheets[sheetName]...)
+			ret.columns[names[i]] = mergeColumnOrder(ret.columns[names[i]], book.columns[sheetName])
+			if column, ok := book.primaryKeys[sheetName]; ok {
+				ret.primaryKeys[names[i]] = column
+			}
+			i++
+		}
+	}
+	return ret, duplicates, nil
+}
+
+// appendablePrimaryKey checks rows of a sheet can be appended to the rows of the same sheet name.
+// Both sheets should have the same primary key column, and keys of the appended rows should not be duplicate.
+func appendablePrimaryKey(primaryKeys map[string]string, sheetName string, column string, rows SheetDataList, appended SheetDataList) error {
+	if err := appendablePrimaryKeyColumn(primaryKeys, sheetName, column); err != nil {
+		return err
+	}
+	if column == "" {
+		return nil
+	}
+
+	path, err := parseColumnPath(column)
+	if err != nil {
+		return err
+	}
+	keys := make(map[string]bool, len(rows))
+	for _, row := range rows {
+		keys[primaryKeyString(getPathValue(row, path))] = true
+	}
+	for _, row := range appended {
+		key := getPathValue(row, path)
+		if keys[primaryKeyString(key)] {
+			return fmt.Errorf("duplicate primary key %v in appended sheet", key)
+		}
+	}
+	return nil
+}
+
+// appendablePrimaryKeyColumn checks the appended sheet has the same primary key column as the former sheets
+func appendablePrimaryKeyColumn(primaryKeys map[string]string, sheetName string, column string) error {
+	if primaryKeys[sheetName] != column {
+		return fmt.Errorf("primary key %q conflicts with %q of appended sheet", column, primaryKeys[sheetName])
+	}
+	return nil
+}
+
+// mergeColumnOrder appends columns of o2 which are not in o1
+func mergeColumnOrder(o1 []string, o2 []string) []string {
+	exists := make(map[string]bool, len(o1))
+	ret := make([]string, 0, len(o1)+len(o2))
+	for _, column := range o1 {
+		exists[column] = true
+		ret = append(ret, column)
+	}
+	for _, column := range o2 {
+		if !exists[column] {
+			exists[column] = true
+			ret = append(ret, column)
+		}
+	}
+	return ret
+}
+
+// mergeWorkbookHeaders merges converted headers of xlsx files into one XlsxHeaderMap in order of the files
+func (c *Converter) mergeWorkbookHeaders(books []workbookHeader) (XlsxHeaderMap, []DuplicateSheet, error) {
+	sources := workbookHeaderSheetSources(books)
+	names, duplicates, err := c.resolveSheetNames(sources, sheetNameKey)
+	if err != nil {
+		return nil, duplicates, err
+	}
+
+	ret := XlsxHeaderMap{}
+	i := 0
+	for _, book := range books {
+		for _, sheetName := range sortedHeaderSheetNames(book.sheets) {
+			merged, err := mergeSheetColumns(ret[names[i]], book.sheets[sheetName])
+			if err != nil {
+				return nil, duplicates, &ConvertError{File: book.file, Sheet: sheetName, Err: err}
+			}
+			ret[names[i]] = merged
+			i++
+		}
+	}
+	return ret, duplicates, nil
+}
+
+// mergeSheetColumns merges columns of appended sheets.
+// The column info of the former sheet has priority, but value types of the same column should be the same.
+// Nested columns are merged recursively.
+func mergeSheetColumns(m1 SheetColumns, m2 SheetColumns) (SheetColumns, error) {
+	ret := SheetColumns{}
+	for k, v := range m1 {
+		ret[k] = v
+	}
+	for k, v := range m2 {
+		former, ok := ret[k]
+		if !ok {
+			ret[k] = v
+			continue
+		}
+		merged, err := mergeColumnInfo(k, former, v)
+		if err != nil {
+			return nil, err
+		}
+		ret[k] = merged
+	}
+	return ret, nil
+}
+
+func mergeColumnInfo(name string, former ColumnInfo, v ColumnInfo) (ColumnInfo, error) {
+	if former.ValueType != v.ValueType {
+		return former, fmt.Errorf("value type of column %s conflicts with appended sheet. %s and %s", name, former.ValueType, v.ValueType)
+	}
+	if former.PrimaryKey != v.PrimaryKey {
+		return former, fmt.Errorf("primary key of column %s conflicts with appended sheet", name)
+	}
+
+	switch former.ValueType {
+	case ValueTypeObject:
+		fields, err := mergeSheetColumns(former.Fields, v.Fields)
+		if err != nil {
+			return former, err
+		}
+		former.Fields = fields
+	case ValueTypeArray:
+		if v.Length > former.Length {
+			former.Length = v.Length
+		}
+		if former.Items != nil && v.Items != nil {
+			items, err := mergeColumnInfo(name+"[]", *former.Items, *v.Items)
+			if err != nil {
+				return former, err
+			}
+			former.Items = &items
+		}
+	}
+	return former, nil
+}
@@ -0,0 +1,128 @@
+package cxtj
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/kama2vern/cxtj/config"
+)
+
+func newDuplicateTestWorkbooks() []workbook {
+	return []workbook{
+		workbook{
+			file: "a.xlsx",
+			sheets: XlsxMap{
+				"sheet":  {{"id": int64(1)}},
+				"master": {{"id": int64(10)}},
+			},
+		},
+		workbook{
+			file: "dir/b.xlsx",
+			sheets: XlsxMap{
+				"sheet": {{"id": int64(2)}, {"id": int64(3)}},
+			},
+		},
+	}
+}
+
+func newDuplicateTestConverter(t *testing.T, policy config.DuplicateSheetPolicy) *Converter {
+	conf := *config.DefaultConfig
+	conf.DuplicateSheet = policy
+	c, err := NewConverter(&conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return c
+}
+
+func TestMergeWorkbooksDuplicateError(t *testing.T) {
+	c := newDuplicateTestConverter(t, config.DuplicateSheetPolicyError)
+
+	_, duplicates, err := c.mergeWorkbooks(newDuplicateTestWorkbooks())
+	if _, ok := err.(*DuplicateSheetError); !ok {
+		t.Fatalf("duplicate sheet should be *DuplicateSheetError, actual: %v", err)
+	}
+	if len(duplicates) != 1 || duplicates[0].Sheet != "sheet" {
+		t.Fatalf("invalid duplicates: %v", duplicates)
+	}
+	if len(duplicates[0].Files) != 2 || duplicates[0].Files[0] != "a.xlsx" || duplicates[0].Files[1] != "dir/b.xlsx" {
+		t.Errorf("invalid files of duplicate sheet: %v", duplicates[0].Files)
+	}
+}
+
+func TestMergeWorkbooksDuplicateNamespace(t *testing.T) {
+	c := newDuplicateTestConverter(t, config.DuplicateSheetPolicyNamespace)
+
+	merged, duplicates, err := c.mergeWorkbooks(newDuplicateTestWorkbooks())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(duplicates) != 1 {
+		t.Errorf("invalid duplicates: %v", duplicates)
+	}
+
+	for sheetName, size := range map[string]int{"a.sheet": 1, "b.sheet": 2, "master": 1} {
+		if len(merged.sheets[sheetName]) != size {
+			t.Errorf("invalid contents size of %s. except %d, actual %d", sheetName, size, len(merged.sheets[sheetName]))
+		}
+	}
+	if _, ok := merged.sheets["sheet"]; ok {
+		t.Error("duplicate sheet should be namespaced")
+	}
+}
+
+func TestMergeWorkbooksDuplicateAppend(t *testing.T) {
+	c := newDuplicateTestConverter(t, config.DuplicateSheetPolicyAppend)
+
+	merged, _, err := c.mergeWorkbooks(newDuplicateTestWorkbooks())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rows := merged.sheets["sheet"]
+	if len(rows) != 3 {
+		t.Fatalf("invalid contents size. except %d, actual %d", 3, len(rows))
+	}
+	for i, id := range []int64{1, 2, 3} {
+		if rows[i]["id"] != id {
+			t.Errorf("rows should be appended in order of files. index %d, except %d, actual %v", i, id, rows[i]["id"])
+		}
+	}
+}
+
+func TestMergeWorkbookHeadersDuplicateAppendConflict(t *testing.T) {
+	c := newDuplicateTestConverter(t, config.DuplicateSheetPolicyAppend)
+
+	books := []workbookHeader{
+		workbookHeader{file: "a.xlsx", sheets: XlsxHeaderMap{"sheet": {"id": ColumnInfo{Index: 0, ValueType: "int"}}}},
+		workbookHeader{file: "b.xlsx", sheets: XlsxHeaderMap{"sheet": {"id": ColumnInfo{Index: 0, ValueType: "string"}}}},
+	}
+	if _, _, err := c.mergeWorkbookHeaders(books); err == nil {
+		t.Error("conflicting value types of appended sheets should be error")
+	}
+}
+
+func TestMergeWorkbooksDuplicateAppendPrimaryKey(t *testing.T) {
+	c := newDuplicateTestConverter(t, config.DuplicateSheetPolicyAppend)
+
+	books := newDuplicateTestWorkbooks()
+	books[0].primaryKeys = map[string]string{"sheet": "id"}
+	books[1].primaryKeys = map[string]string{"sheet": "id"}
+	merged, _, err := c.mergeWorkbooks(books)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if merged.primaryKeys["sheet"] != "id" {
+		t.Errorf("primary key should be merged. actual %v", merged.primaryKeys)
+	}
+
+	books[1].sheets["sheet"] = SheetDataList{{"id": int64(1)}}
+	if _, _, err := c.mergeWorkbooks(books); err == nil || !strings.Contains(err.Error(), "duplicate primary key 1") {
+		t.Errorf("duplicate primary key in appended sheet should be error, actual: %v", err)
+	}
+
+	books[1].primaryKeys = map[string]string{}
+	if _, _, err := c.mergeWorkbooks(books); err == nil {
+		t.Error("appended sheet without primary key should be error")
+	}
+}
@@ -0,0 +1,125 @@
+package cxtj
+
+import (
+	"bytes"
+	"encoding/csv"
+	"io"
+	"regexp"
+
+	"github.com/kama2vern/cxtj/config"
+)
+
+// newlineWriter converts "\n" into the configured newline characters.
+// It is only for formats whose values never have raw newlines like json and toml,
+// because every "\n" is converted.
+type newlineWriter struct {
+	w       io.Writer
+	newline []byte
+}
+
+func newNewlineWriter(w io.Writer, newline config.Newline) io.Writer {
+	if newline == config.NewlineLF {
+		return w
+	}
+	return &newlineWriter{w: w, newline: newline.Bytes()}
+}
+
+func (nw *newlineWriter) Write(p []byte) (int, error) {
+	converted := bytes.Replace(p, []byte("\n"), nw.newline, -1)
+	if _, err := nw.w.Write(converted); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// csvWriter writes csv records which end with the configured newline characters.
+// Newlines in quoted fields are kept as they are, unlike csv.Writer.UseCRLF.
+type csvWriter struct {
+	w       io.Writer
+	buf     bytes.Buffer
+	cw      *csv.Writer
+	newline []byte
+	err     error
+}
+
+func newCSVWriter(w io.Writer, newline config.Newline) *csvWriter {
+	c := &csvWriter{w: w, newline: newline.Bytes()}
+	c.cw = csv.NewWriter(&c.buf)
+	return c
+}
+
+// Write writes one record. The first error is kept and returned by Error.
+func (c *csvWriter) Write(record []string) {
+	if c.err != nil {
+		return
+	}
+	c.buf.Reset()
+	c.cw.Write(record)
+	c.cw.Flush()
+	if c.err = c.cw.Error(); c.err != nil {
+		return
+	}
+	bs := bytes.TrimSuffix(c.buf.Bytes(), []byte("\n"))
+	if _, c.err = c.w.Write(append(bs, c.newline...)); c.err != nil {
+		return
+	}
+}
+
+// Error returns the first error of writing records
+func (c *csvWriter) Error() error {
+	return c.err
+}
+
+// yamlBlockIndicator matches a line which starts a literal block scalar like "key: |-" or "- |2"
+var yamlBlockIndicator = regexp.MustCompile(`(^|: |- )\|[1-9]?([-+]?)$`)
+
+// yamlNewlines converts line breaks of yaml into the newline characters.
+// Line breaks in the content of literal block scalars are kept as they are, because they are a part of the string value.
+func yamlNewlines(bs []byte, newline config.Newline) []byte {
+	if newline == config.NewlineLF {
+		return bs
+	}
+
+	lines := bytes.SplitAfter(bs, []byte("\n"))
+	var buf bytes.Buffer
+	for i := 0; i < len(lines); i++ {
+		writeYAMLLine(&buf, lines[i], newline.Bytes())
+
+		m := yamlBlockIndicator.FindSubmatch(bytes.TrimSuffix(lines[i], []byte("\n")))
+		if m == nil {
+			continue
+		}
+		// content lines of the block are empty or indented more than the indicator line
+		indent := yamlIndent(lines[i])
+		last := i
+		for j := i + 1; j < len(lines) && len(lines[j]) > 0; j++ {
+			if len(bytes.TrimSpace(lines[j])) > 0 && yamlIndent(lines[j]) <= indent {
+				break
+			}
+			last = j
+		}
+		for j := i + 1; j <= last; j++ {
+			if j == last && string(m[2]) == "-" {
+				// the last line break is stripped from the value by "|-"
+				writeYAMLLine(&buf, lines[j], newline.Bytes())
+			} else {
+				buf.Write(lines[j])
+			}
+		}
+		i = last
+	}
+	return buf.Bytes()
+}
+
+func writeYAMLLine(buf *bytes.Buffer, line []byte, newline []byte) {
+	if !bytes.HasSuffix(line, []byte("\n")) {
+		buf.Write(line)
+		return
+	}
+	buf.Write(line[:len(line)-1])
+	buf.Write(newline)
+}
+
+func yamlIndent(line []byte) int {
+	return len(line) - len(bytes.TrimLeft(line, " "))
+}
@@ -0,0 +1,321 @@
+package cxtj
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+
+	yaml "gopkg.in/yaml.v2"
+
+	"github.com/kama2vern/cxtj/config"
+)
+
+// orderedField is a key and value of orderedObject
+type orderedField struct {
+	Key   string
+	Value interface{}
+}
+
+// orderedObject is an object which keeps the order of keys in json and yaml
+type orderedObject []orderedField
+
+// MarshalJSON writes keys in order of fields
+func (o orderedObject) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteByte('{')
+	for i, field := range o {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		key, err := json.Marshal(field.Key)
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(key)
+		buf.WriteByte(':')
+
+		value, err := json.Marshal(field.Value)
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(value)
+	}
+	buf.WriteByte('}')
+	return buf.Bytes(), nil
+}
+
+// MarshalYAML writes keys in order of fields
+func (o orderedObject) MarshalYAML() (interface{}, error) {
+	ms := make(yaml.MapSlice, len(o))
+	for i, field := range o {
+		ms[i] = yaml.MapItem{Key: field.Key, Value: field.Value}
+	}
+	return ms, nil
+}
+
+// orderedKeys returns keys in order of columns if the key order is column, otherwise in alphabetical order.
+// Keys which are not in columns are placed after them in alphabetical order.
+func orderedKeys(keys []string, columns []string, keyOrder config.KeyOrder) []string {
+	sort.Strings(keys)
+	if keyOrder != config.KeyOrderColumn || len(columns) == 0 {
+		return keys
+	}
+
+	exists := make(map[string]bool, len(keys))
+	for _, k := range keys {
+		exists[k] = true
+	}
+
+	ret := make([]string, 0, len(keys))
+	for _, column := range columns {
+		if exists[column] {
+			ret = append(ret, column)
+			delete(exists, column)
+		}
+	}
+	for _, k := range keys {
+		if exists[k] {
+			ret = append(ret, k)
+		}
+	}
+	return ret
+}
+
+// columnTree returns child keys of each path prefix in order of columns.
+// The prefix of the row is "", and the prefix of array elements ends with "[]" like "rewards[]".
+func columnTree(columns []string) map[string][]string {
+	tree := map[string][]string{}
+	exists := map[string]bool{}
+	for _, column := range columns {
+		path, err := parseColumnPath(column)
+		if err != nil {
+			continue
+		}
+
+		prefix := ""
+		for _, e := range path {
+			if e.isIndex {
+				prefix += "[]"
+				continue
+			}
+			child := e.key
+			if prefix != "" {
+				child = prefix + "." + e.key
+			}
+			if !exists[child] {
+				exists[child] = true
+				tree[prefix] = append(tree[prefix], e.key)
+			}
+			prefix = child
+		}
+	}
+	return tree
+}
+
+// orderValue converts nested objects of the value at the prefix into orderedObject
+func (o EncoderOptions) orderValue(tree map[string][]string, prefix string, v interface{}) interface{} {
+	switch value := v.(type) {
+	case map[string]interface{}:
+		return o.orderObject(tree, prefix, value)
+	case RowMap:
+		return o.orderObject(tree, prefix, value)
+	case []interface{}:
+		ret := make([]interface{}, len(value))
+		for i, element := range value {
+			ret[i] = o.orderValue(tree, prefix+"[]", element)
+		}
+		return ret
+	}
+	return v
+}
+
+func (o EncoderOptions) orderObject(tree map[string][]string, prefix string, m map[string]interface{}) orderedObject {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+
+	ret := make(orderedObject, 0, len(m))
+	for _, k := range orderedKeys(keys, tree[prefix], o.Output.KeyOrder) {
+		child := k
+		if prefix != "" {
+			child = prefix + "." + k
+		}
+		ret = append(ret, orderedField{Key: k, Value: o.orderValue(tree, child, m[k])})
+	}
+	return ret
+}
+
+// orderSheetDataList converts rows of the sheet into list of orderedObject.
+// Rows are an orderedObject keyed by the primary key in order of rows if the row shape is keyed.
+func (o EncoderOptions) orderSheetDataList(sheetName string, l []map[string]interface{}) interface{} {
+	tree := columnTree(o.ColumnOrder[sheetName])
+	if path, ok := o.primaryKeyPath(sheetName); ok {
+		ret := make(orderedObject, len(l))
+		for i, row := range l {
+			ret[i] = orderedField{Key: primaryKeyString(getPathValue(row, path)), Value: o.orderObject(tree, "", row)}
+		}
+		return ret
+	}
+
+	ret := make([]orderedObject, len(l))
+	for i, row := range l {
+		ret[i] = o.orderObject(tree, "", row)
+	}
+	return ret
+}
+
+// primaryKeyPath returns the path of the primary key column if rows of the sheet are keyed
+func (o EncoderOptions) primaryKeyPath(sheetName string) (columnPath, bool) {
+	column, ok := o.PrimaryKeys[sheetName]
+	if !ok || o.Output.RowShape != config.RowShapeKeyed {
+		return nil, false
+	}
+	path, err := parseColumnPath(column)
+	return path, err == nil
+}
+
+// tomlValue converts orderedObject into a struct whose fields are in order of keys recursively,
+// because the toml encoder sorts keys of maps but keeps the order of fields of structs.
+// An object which has any key including "," or a key of "" or "-" is a map sorted by keys,
+// because the key cannot be a field tag.
+func tomlValue(v interface{}) interface{} {
+	switch value := v.(type) {
+	case orderedObject:
+		fields := make([]reflect.StructField, 0, len(value))
+		values := make([]interface{}, 0, len(value))
+		m := make(map[string]interface{}, len(value))
+		isMap := false
+		for i, field := range value {
+			m[field.Key] = tomlValue(field.Value)
+			fields = append(fields, reflect.StructField{
+				Name: fmt.Sprintf("F%d", i),
+				Type: reflect.TypeOf((*interface{})(nil)).Elem(),
+				Tag:  reflect.StructTag("toml:" + strconv.Quote(field.Key)),
+			})
+			values = append(values, m[field.Key])
+			if strings.Contains(field.Key, ",") || field.Key == "" || field.Key == "-" {
+				isMap = true
+			}
+		}
+		if isMap {
+			return m
+		}
+
+		ret := reflect.New(reflect.StructOf(fields)).Elem()
+		for i, fieldValue := range values {
+			if fieldValue != nil {
+				ret.Field(i).Set(reflect.ValueOf(fieldValue))
+			}
+		}
+		return ret.Interface()
+	case []orderedObject:
+		ret := make([]interface{}, len(value))
+		for i, element := range value {
+			ret[i] = tomlValue(element)
+		}
+		return ret
+	case []interface{}:
+		ret := make([]interface{}, len(value))
+		for i, element := range value {
+			ret[i] = tomlValue(element)
+		}
+		return ret
+	}
+	return v
+}
+
+// orderSheetColumns converts SheetColumns into orderedObject.
+// Columns are sorted by index if the key order is column.
+func (o EncoderOptions) orderSheetColumns(columns map[string]ColumnInfo) orderedObject {
+	keys := make([]string, 0, len(columns))
+	for k := range columns {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	if o.Output.KeyOrder == config.KeyOrderColumn {
+		sort.SliceStable(keys, func(i, j int) bool {
+			return columns[keys[i]].Index < columns[keys[j]].Index
+		})
+	}
+
+	ret := make(orderedObject, len(keys))
+	for i, k := range keys {
+		ret[i] = orderedField{Key: k, Value: o.orderColumnInfo(columns[k])}
+	}
+	return ret
+}
+
+// orderColumnInfo orders nested columns of the column info.
+// Flat column info is returned as it is.
+func (o EncoderOptions) orderColumnInfo(info ColumnInfo) interface{} {
+	if info.Items == nil && info.Fields == nil {
+		return info
+	}
+
+	ret := orderedObject{
+		{Key: "index", Value: info.Index},
+		{Key: "valueType", Value: info.ValueType},
+	}
+	if info.Comment != "" {
+		ret = append(ret, orderedField{Key: "comment", Value: info.Comment})
+	}
+	if info.Length != 0 {
+		ret = append(ret, orderedField{Key: "length", Value: info.Length})
+	}
+	if info.Items != nil {
+		ret = append(ret, orderedField{Key: "items", Value: o.orderColumnInfo(*info.Items)})
+	}
+	if info.Fields != nil {
+		ret = append(ret, orderedField{Key: "fields", Value: o.orderSheetColumns(info.Fields)})
+	}
+	return ret
+}
+
+// orderSheets converts sheets into an object keyed by sheet name, or an array of sheets by the output shape
+func (o EncoderOptions) orderSheets(sheetNames []string, valueKey string, value func(sheetName string) interface{}) interface{} {
+	sort.Strings(sheetNames)
+
+	if o.Output.Shape == config.OutputShapeArray {
+		ret := make([]orderedObject, len(sheetNames))
+		for i, sheetName := range sheetNames {
+			ret[i] = orderedObject{
+				{Key: "name", Value: sheetName},
+				{Key: valueKey, Value: value(sheetName)},
+			}
+		}
+		return ret
+	}
+
+	ret := make(orderedObject, len(sheetNames))
+	for i, sheetName := range sheetNames {
+		ret[i] = orderedField{Key: sheetName, Value: value(sheetName)}
+	}
+	return ret
+}
+
+// order converts XlsxMap, XlsxHeaderMap, SheetDataList (or rows of XlsxMap) or SheetColumns into the structure to be encoded
+// by the output options. The other values are returned as they are.
+func (o EncoderOptions) order(sheetName string, v interface{}) interface{} {
+	switch data := v.(type) {
+	case XlsxMap:
+		return o.orderSheets(sortedSheetNames(data), "rows", func(sheetName string) interface{} {
+			return o.orderSheetDataList(sheetName, data[sheetName])
+		})
+	case XlsxHeaderMap:
+		return o.orderSheets(sortedHeaderSheetNames(data), "columns", func(sheetName string) interface{} {
+			return o.orderSheetColumns(data[sheetName])
+		})
+	case SheetDataList:
+		return o.orderSheetDataList(sheetName, data)
+	case []map[string]interface{}:
+		return o.orderSheetDataList(sheetName, data)
+	case SheetColumns:
+		return o.orderSheetColumns(data)
+	}
+	return v
+}
@@ -0,0 +1,317 @@
+package cxtj
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Value types of nested columns built from column paths
+const (
+	ValueTypeObject = "object"
+	ValueTypeArray  = "array"
+)
+
+// pathElement is a key of object or an index of array in a column path
+type pathElement struct {
+	key     string
+	index   int
+	isIndex bool
+}
+
+// columnPath is a parsed column name of the key row.
+// "stats.hp" is key stats and key hp, and "rewards[0].id" is key rewards, index 0 and key id.
+type columnPath []pathElement
+
+func (p columnPath) String() string {
+	var b strings.Builder
+	for i, e := range p {
+		if e.isIndex {
+			fmt.Fprintf(&b, "[%d]", e.index)
+			continue
+		}
+		if i > 0 {
+			b.WriteByte('.')
+		}
+		b.WriteString(e.key)
+	}
+	return b.String()
+}
+
+// parseColumnPath parses a column name into the path.
+// A name without "." and "[" is a path of the name itself, including an empty name.
+func parseColumnPath(name string) (columnPath, error) {
+	if !strings.ContainsAny(name, ".[]") {
+		return columnPath{{key: name}}, nil
+	}
+
+	path := columnPath{}
+	for _, segment := range strings.Split(name, ".") {
+		key := segment
+		if i := strings.IndexByte(segment, '['); i >= 0 {
+			key = segment[:i]
+		}
+		if key == "" || strings.ContainsAny(key, "[]") {
+			return nil, fmt.Errorf("invalid column path %q", name)
+		}
+		path = append(path, pathElement{key: key})
+
+		for rest := segment[len(key):]; rest != ""; {
+			end := strings.IndexByte(rest, ']')
+			if rest[0] != '[' || end < 0 {
+				return nil, fmt.Errorf("invalid column path %q", name)
+			}
+			index, err := strconv.Atoi(rest[1:end])
+			if err != nil || index < 0 || rest[1] == '+' {
+				return nil, fmt.Errorf("invalid index of column path %q", name)
+			}
+			path = append(path, pathElement{index: index, isIndex: true})
+			rest = rest[end+1:]
+		}
+	}
+	return path, nil
+}
+
+// columnNode is a node of the tree of column paths to check conflicts and build nested columns
+type columnNode struct {
+	valueType string
+	leaf      *ColumnInfo
+	fields    map[string]*columnNode
+	elements  map[int]*columnNode
+	index     int
+	path      columnPath
+}
+
+func newColumnNode(path columnPath, next pathElement, index int) *columnNode {
+	node := &columnNode{path: path, index: index}
+	if next.isIndex {
+		node.valueType = ValueTypeArray
+		node.elements = map[int]*columnNode{}
+	} else {
+		node.valueType = ValueTypeObject
+		node.fields = map[string]*columnNode{}
+	}
+	return node
+}
+
+// buildSheetColumns builds columns from column names of the key row with value types and comments.
+// Column paths make nested objects and arrays, and conflicting paths are *ConvertError with the column.
+// primaryKey is the index of the primary key column, or -1.
+func buildSheetColumns(keys []string, valueTypes []string, comments []string, primaryKey int) (SheetColumns, []columnPath, error) {
+	root := &columnNode{valueType: ValueTypeObject, fields: map[string]*columnNode{}}
+	paths := make([]columnPath, len(keys))
+
+	for j, key := range keys {
+		path, err := parseColumnPath(key)
+		if err != nil {
+			return nil, nil, &ConvertError{Column: j + 1, Err: err}
+		}
+		paths[j] = path
+
+		info := ColumnInfo{Index: j, PrimaryKey: j == primaryKey}
+		if j < len(valueTypes) {
+			info.ValueType = valueTypes[j]
+		}
+		if j < len(comments) {
+			info.Comment = comments[j]
+		}
+
+		// blank cells of the key row are the same empty key, and the last one wins as before
+		if key == "" {
+			leaf := info
+			root.fields[key] = &columnNode{leaf: &leaf, index: j, path: path}
+			continue
+		}
+
+		node := root
+		for i, e := range path {
+			isLast := i == len(path)-1
+			var child *columnNode
+			if e.isIndex {
+				if node.valueType != ValueTypeArray {
+					return nil, nil, &ConvertError{Column: j + 1, Err: fmt.Errorf("column path %s conflicts with object %s", path, path[:i])}
+				}
+				child = node.elements[e.index]
+			} else {
+				if node.valueType != ValueTypeObject {
+					return nil, nil, &ConvertError{Column: j + 1, Err: fmt.Errorf("column path %s conflicts with array %s", path, path[:i])}
+				}
+				child = node.fields[e.key]
+			}
+
+			if child != nil && (isLast || child.leaf != nil) {
+				return nil, nil, &ConvertError{Column: j + 1, Err: fmt.Errorf("column path %s conflicts with column %s", path, child.conflictingPath())}
+			}
+			if child == nil {
+				if isLast {
+					leaf := info
+					child = &columnNode{leaf: &leaf, index: j, path: path}
+				} else {
+					child = newColumnNode(path[:i+1], path[i+1], j)
+				}
+				if e.isIndex {
+					node.elements[e.index] = child
+				} else {
+					node.fields[e.key] = child
+				}
+			}
+			node = child
+		}
+	}
+
+	columns, err := root.fieldColumns()
+	if err != nil {
+		return nil, nil, err
+	}
+	return columns, paths, nil
+}
+
+// conflictingPath returns the path of a column under the node
+func (n *columnNode) conflictingPath() columnPath {
+	for n.leaf == nil {
+		next := n
+		for _, child := range n.fields {
+			if next == n || child.index < next.index {
+				next = child
+			}
+		}
+		for _, child := range n.elements {
+			if next == n || child.index < next.index {
+				next = child
+			}
+		}
+		n = next
+	}
+	return n.path
+}
+
+func (n *columnNode) fieldColumns() (SheetColumns, error) {
+	ret := make(SheetColumns, len(n.fields))
+	for key, child := range n.fields {
+		info, err := child.columnInfo()
+		if err != nil {
+			return nil, err
+		}
+		ret[key] = info
+	}
+	return ret, nil
+}
+
+func (n *columnNode) columnInfo() (ColumnInfo, error) {
+	if n.leaf != nil {
+		return *n.leaf, nil
+	}
+
+	info := ColumnInfo{Index: n.index, ValueType: n.valueType}
+	if n.valueType == ValueTypeObject {
+		fields, err := n.fieldColumns()
+		if err != nil {
+			return info, err
+		}
+		info.Fields = fields
+		return info, nil
+	}
+
+	info.Length = len(n.elements)
+	for i := 0; i < info.Length; i++ {
+		element, ok := n.elements[i]
+		if !ok {
+			return info, &ConvertError{Column: n.index + 1, Err: fmt.Errorf("array %s does not have element [%d]", n.path, i)}
+		}
+		items, err := element.columnInfo()
+		if err != nil {
+			return info, err
+		}
+		if info.Items == nil {
+			info.Items = &items
+		} else if !sameColumnShape(*info.Items, items) {
+			return info, &ConvertError{Column: element.index + 1, Err: fmt.Errorf("element [%d] of array %s has different columns from element [0]", i, n.path)}
+		}
+	}
+	return info, nil
+}
+
+// sameColumnShape reports whether columns have the same value types and nested columns regardless of indices
+func sameColumnShape(c1 ColumnInfo, c2 ColumnInfo) bool {
+	if c1.ValueType != c2.ValueType || c1.Length != c2.Length || len(c1.Fields) != len(c2.Fields) {
+		return false
+	}
+	for key, f1 := range c1.Fields {
+		f2, ok := c2.Fields[key]
+		if !ok || !sameColumnShape(f1, f2) {
+			return false
+		}
+	}
+	if (c1.Items == nil) != (c2.Items == nil) {
+		return false
+	}
+	return c1.Items == nil || sameColumnShape(*c1.Items, *c2.Items)
+}
+
+// setPathValue sets the value into the row at the path.
+// Nested objects and arrays are created by the shape of columns.
+func setPathValue(row map[string]interface{}, columns SheetColumns, path columnPath, value interface{}) {
+	object := row
+	fields := columns
+	var array []interface{}
+	var items *ColumnInfo
+
+	for i, e := range path {
+		var info ColumnInfo
+		var current interface{}
+		if e.isIndex {
+			info = *items
+			current = array[e.index]
+		} else {
+			info = fields[e.key]
+			current = object[e.key]
+		}
+
+		if i == len(path)-1 {
+			current = value
+		} else if current == nil {
+			if info.ValueType == ValueTypeArray {
+				current = make([]interface{}, info.Length)
+			} else {
+				current = map[string]interface{}{}
+			}
+		}
+
+		if e.isIndex {
+			array[e.index] = current
+		} else {
+			object[e.key] = current
+		}
+
+		switch info.ValueType {
+		case ValueTypeObject:
+			object, _ = current.(map[string]interface{})
+			fields = info.Fields
+		case ValueTypeArray:
+			array, _ = current.([]interface{})
+			items = info.Items
+		}
+	}
+}
+
+// getPathValue returns the value in the row at the path, or nil if it is not found
+func getPathValue(row map[string]interface{}, path columnPath) interface{} {
+	var current interface{} = row
+	for _, e := range path {
+		switch value := current.(type) {
+		case map[string]interface{}:
+			if e.isIndex {
+				return nil
+			}
+			current = value[e.key]
+		case []interface{}:
+			if !e.isIndex || e.index >= len(value) {
+				return nil
+			}
+			current = value[e.index]
+		default:
+			return nil
+		}
+	}
+	return current
+}
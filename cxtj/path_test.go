@@ -0,0 +1,178 @@
+package cxtj
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+
+	"github.com/kama2vern/cxtj/config"
+)
+
+func TestParseColumnPath(t *testing.T) {
+	for name, except := range map[string]columnPath{
+		"id":            {{key: "id"}},
+		"":              {{key: ""}},
+		"stats.hp":      {{key: "stats"}, {key: "hp"}},
+		"rewards[1].id": {{key: "rewards"}, {index: 1, isIndex: true}, {key: "id"}},
+		"grid[0][2]":    {{key: "grid"}, {index: 0, isIndex: true}, {index: 2, isIndex: true}},
+		"a.b[10].c.d":   {{key: "a"}, {key: "b"}, {index: 10, isIndex: true}, {key: "c"}, {key: "d"}},
+	} {
+		path, err := parseColumnPath(name)
+		if err != nil {
+			t.Errorf("%s: %s", name, err)
+			continue
+		}
+		if !reflect.DeepEqual(path, except) {
+			t.Errorf("Mismatch path of %s. except %v, actual %v", name, except, path)
+		}
+		if name != "" && path.String() != name {
+			t.Errorf("Mismatch string of path. except %s, actual %s", name, path.String())
+		}
+	}
+
+	for _, name := range []string{"stats.", ".hp", "a..b", "[0]", "a[]", "a[x]", "a[-1]", "a[0", "a]0[", "a[0]b"} {
+		if _, err := parseColumnPath(name); err == nil {
+			t.Errorf("invalid column path should be error: %s", name)
+		}
+	}
+}
+
+func TestBuildSheetColumnsConflict(t *testing.T) {
+	for _, tc := range []struct {
+		keys   []string
+		column int
+	}{
+		{keys: []string{"stats", "stats.hp"}, column: 2},
+		{keys: []string{"stats.hp", "stats"}, column: 2},
+		{keys: []string{"stats.hp", "stats.hp"}, column: 2},
+		{keys: []string{"id", "id"}, column: 2},
+		{keys: []string{"rewards[0]", "rewards.id"}, column: 2},
+		{keys: []string{"stats.hp", "stats[0]"}, column: 2},
+		{keys: []string{"rewards[0].id", "rewards[0]"}, column: 2},
+		{keys: []string{"rewards[0]", "rewards[2]"}, column: 1},
+		{keys: []string{"rewards[0].id", "rewards[1].name"}, column: 2},
+	} {
+		_, _, err := buildSheetColumns(tc.keys, nil, nil, -1)
+		convertErr, ok := err.(*ConvertError)
+		if !ok {
+			t.Errorf("conflicting paths %v should be *ConvertError, actual: %v", tc.keys, err)
+			continue
+		}
+		if convertErr.Column != tc.column {
+			t.Errorf("invalid column of conflict %v. except %d, actual %d: %s", tc.keys, tc.column, convertErr.Column, convertErr)
+		}
+	}
+
+	if _, _, err := buildSheetColumns([]string{"", "id", ""}, nil, nil, -1); err != nil {
+		t.Errorf("blank keys should not conflict: %s", err)
+	}
+}
+
+func newNestedTestXlsxRows() [][]string {
+	return [][]string{
+		{"id", "stats.hp", "stats.mp", "rewards[0].id", "rewards[0].count", "rewards[1].id", "rewards[1].count", "tags[0]", "tags[1]"},
+		{"int", "int", "int", "int", "int", "int", "int", "string", "string"},
+		{"ID", "HP", "MP", "Reward ID", "Reward Count", "Reward ID", "Reward Count", "Tag", "Tag"},
+		{"1", "100", "50", "10", "1", "11", "2", "fire", "rare"},
+		{"2", "200", "", "", "", "", "", "water", ""},
+	}
+}
+
+func TestConvertNestedColumns(t *testing.T) {
+	c, err := NewConverter(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	converted, err := c.ConvertWorkbook(newTestXlsxFile(t, "sheet", newNestedTestXlsxRows()))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	except := SheetDataList{
+		{
+			"id":    int64(1),
+			"stats": map[string]interface{}{"hp": int64(100), "mp": int64(50)},
+			"rewards": []interface{}{
+				map[string]interface{}{"id": int64(10), "count": int64(1)},
+				map[string]interface{}{"id": int64(11), "count": int64(2)},
+			},
+			"tags": []interface{}{"fire", "rare"},
+		},
+		{
+			"id":    int64(2),
+			"stats": map[string]interface{}{"hp": int64(200), "mp": nil},
+			"rewards": []interface{}{
+				map[string]interface{}{"id": nil, "count": nil},
+				map[string]interface{}{"id": nil, "count": nil},
+			},
+			"tags": []interface{}{"water", ""},
+		},
+	}
+	if !reflect.DeepEqual(SheetDataList(converted["sheet"]), except) {
+		t.Errorf("Mismatch contents. except %v, actual %v", except, converted["sheet"])
+	}
+}
+
+func TestConvertNestedColumnsIntoHeader(t *testing.T) {
+	c, err := NewConverter(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	header, err := c.ConvertWorkbookIntoHeader(newTestXlsxFile(t, "sheet", newNestedTestXlsxRows()))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	opts := EncoderOptions{Output: config.OutputConfig{KeyOrder: config.KeyOrderColumn}}
+	var buf bytes.Buffer
+	if err := NewJSONEncoder(opts).Encode(&buf, header); err != nil {
+		t.Fatal(err)
+	}
+	except := `{"sheet":{"id":{"index":0,"valueType":"int","comment":"ID"},` +
+		`"stats":{"index":1,"valueType":"object","fields":{"hp":{"index":1,"valueType":"int","comment":"HP"},"mp":{"index":2,"valueType":"int","comment":"MP"}}},` +
+		`"rewards":{"index":3,"valueType":"array","length":2,"items":{"index":3,"valueType":"object","fields":{"id":{"index":3,"valueType":"int","comment":"Reward ID"},"count":{"index":4,"valueType":"int","comment":"Reward Count"}}}},` +
+		`"tags":{"index":7,"valueType":"array","length":2,"items":{"index":7,"valueType":"string","comment":"Tag"}}}}` + "\n"
+	if buf.String() != except {
+		t.Errorf("Mismatch header. except %s, actual %s", except, buf.String())
+	}
+}
+
+func TestNestedColumnOrder(t *testing.T) {
+	row := map[string]interface{}{
+		"stats":   map[string]interface{}{"mp": int64(50), "hp": int64(100)},
+		"rewards": []interface{}{map[string]interface{}{"id": int64(10), "count": int64(1)}},
+	}
+	opts := EncoderOptions{
+		Output:      config.OutputConfig{KeyOrder: config.KeyOrderColumn},
+		ColumnOrder: map[string][]string{"sheet": {"stats.hp", "stats.mp", "rewards[0].id", "rewards[0].count"}},
+	}
+
+	var buf bytes.Buffer
+	if err := NewJSONEncoder(opts).EncodeSheet(&buf, "sheet", SheetDataList{row}); err != nil {
+		t.Fatal(err)
+	}
+	except := `[{"stats":{"hp":100,"mp":50},"rewards":[{"id":10,"count":1}]}]` + "\n"
+	if buf.String() != except {
+		t.Errorf("Mismatch json. except %s, actual %s", except, buf.String())
+	}
+}
+
+func TestNestedColumnsCSV(t *testing.T) {
+	c, err := NewConverter(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	header, err := c.ConvertWorkbookIntoHeader(newTestXlsxFile(t, "sheet", newNestedTestXlsxRows()))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+	if err := NewCSVEncoder(EncoderOptions{}).EncodeSheet(&buf, "sheet", SheetColumns(header["sheet"])); err != nil {
+		t.Fatal(err)
+	}
+	except := "column,index,valueType\nid,0,int\nstats.hp,1,int\nstats.mp,2,int\nrewards[].id,3,int\nrewards[].count,4,int\ntags[],7,string\n"
+	if buf.String() != except {
+		t.Errorf("Mismatch csv. except %q, actual %q", except, buf.String())
+	}
+}
@@ -0,0 +1,170 @@
+package cxtj
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/tealeg/xlsx"
+
+	"github.com/kama2vern/cxtj/config"
+)
+
+// ValueTypeReferencePrefix is the prefix of reference value types like "ref:characters.id"
+const ValueTypeReferencePrefix = "ref:"
+
+// Reference is a column of another sheet which the values of a column should be found in
+type Reference struct {
+	Sheet  string
+	Column string
+}
+
+func (r Reference) String() string {
+	return r.Sheet + "." + r.Column
+}
+
+// ParseReferenceValueType parses a reference value type like "ref:characters.id" into the referenced column.
+// Cells of the reference are long values, or the value type declared after the column like "ref:characters.name:string".
+func ParseReferenceValueType(valueType string) (ref Reference, cellValueType string, ok bool) {
+	if !strings.HasPrefix(valueType, ValueTypeReferencePrefix) {
+		return Reference{}, "", false
+	}
+
+	target := strings.TrimPrefix(valueType, ValueTypeReferencePrefix)
+	cellValueType = ValueTypeLong
+	if i := strings.LastIndexByte(target, ':'); i >= 0 {
+		cellValueType = target[i+1:]
+		target = target[:i]
+		switch cellValueType {
+		case ValueTypeInt, ValueTypeLong, ValueTypeFloat, ValueTypeDouble, ValueTypeBool, ValueTypeString:
+		default:
+			return Reference{}, "", false
+		}
+	}
+
+	i := strings.IndexByte(target, '.')
+	if i <= 0 || i == len(target)-1 {
+		return Reference{}, "", false
+	}
+	return Reference{Sheet: target[:i], Column: target[i+1:]}, cellValueType, true
+}
+
+// DanglingReferenceError is a value which is not found in the referenced column
+type DanglingReferenceError struct {
+	Reference Reference
+	Value     interface{}
+}
+
+func (e *DanglingReferenceError) Error() string {
+	return fmt.Sprintf("%v is not found in %s", e.Value, e.Reference)
+}
+
+// checkedSheet is a converted sheet with row lines to check references
+type checkedSheet struct {
+	file   string
+	name   string
+	header *sheetHeader
+	rows   []sheetRow
+}
+
+// Check converts xlsx files or directories and reports every reference to a missing value
+// as *ConvertError with the location of the cell. Sheets of the same name in multiple files are
+// referenced as one sheet. A file which cannot be opened as xlsx is an error.
+func (c *Converter) Check(inputDirsOrFiles []string) ([]*ConvertError, error) {
+	inputFiles, err := c.traversalInputFiles(inputDirsOrFiles)
+	if err != nil {
+		return nil, err
+	}
+
+	sheets := []checkedSheet{}
+	for _, inputFile := range inputFiles {
+		xlsxFile, err := xlsx.OpenFile(inputFile)
+		if err != nil {
+			return nil, &ConvertError{File: inputFile, Err: err}
+		}
+		for _, s := range xlsxFile.Sheets {
+			header, rows, err := c.sheetRows(s)
+			if err != nil {
+				return nil, withFile(err, inputFile)
+			}
+			if header != nil {
+				sheets = append(sheets, checkedSheet{file: inputFile, name: s.Name, header: header, rows: rows})
+			}
+		}
+	}
+
+	return c.checkReferences(sheets), nil
+}
+
+// checkReferences reports dangling references of the sheets in order of files, sheets, rows and columns
+func (c *Converter) checkReferences(sheets []checkedSheet) []*ConvertError {
+	valueTypeLine := 0
+	if excelFormat, err := c.config.GetExcelFormatByRowType(config.ExcelFormatRowTypeValueType); err == nil {
+		valueTypeLine = excelFormat.RowLine
+	}
+
+	targets := map[Reference]map[string]bool{}
+	ret := []*ConvertError{}
+	for _, sheet := range sheets {
+		refs := make([]*Reference, len(sheet.header.valueTypes))
+		for j, valueType := range sheet.header.valueTypes {
+			ref, _, ok := ParseReferenceValueType(valueType)
+			if !ok {
+				continue
+			}
+			if _, ok := targets[ref]; !ok {
+				targets[ref] = referencedValues(sheets, ref)
+			}
+			if targets[ref] == nil {
+				ret = append(ret, &ConvertError{File: sheet.file, Sheet: sheet.name, Row: valueTypeLine, Column: j + 1,
+					Err: fmt.Errorf("referenced column %s is not found", ref)})
+				continue
+			}
+			refs[j] = &ref
+		}
+
+		for _, row := range sheet.rows {
+			for j, ref := range refs {
+				if ref == nil {
+					continue
+				}
+				value := getPathValue(row.values, sheet.header.paths[j])
+				if value == nil || value == "" || targets[*ref][referenceKey(value)] {
+					continue
+				}
+				ret = append(ret, &ConvertError{File: sheet.file, Sheet: sheet.name, Row: row.line, Column: j + 1,
+					Err: &DanglingReferenceError{Reference: *ref, Value: value}})
+			}
+		}
+	}
+	return ret
+}
+
+// referencedValues collects values of the referenced column from all sheets of the name.
+// It returns nil when the column is not found.
+func referencedValues(sheets []checkedSheet, ref Reference) map[string]bool {
+	var ret map[string]bool
+	for _, sheet := range sheets {
+		if sheet.name != ref.Sheet {
+			continue
+		}
+		for j, key := range sheet.header.keys {
+			if key != ref.Column {
+				continue
+			}
+			if ret == nil {
+				ret = map[string]bool{}
+			}
+			for _, row := range sheet.rows {
+				if value := getPathValue(row.values, sheet.header.paths[j]); value != nil {
+					ret[referenceKey(value)] = true
+				}
+			}
+		}
+	}
+	return ret
+}
+
+// referenceKey makes values comparable regardless of value types like int and long
+func referenceKey(value interface{}) string {
+	return fmt.Sprint(value)
+}
@@ -0,0 +1,127 @@
+package cxtj
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestParseReferenceValueType(t *testing.T) {
+	cases := []struct {
+		valueType     string
+		ref           Reference
+		cellValueType string
+		ok            bool
+	}{
+		{"ref:characters.id", Reference{Sheet: "characters", Column: "id"}, "long", true},
+		{"ref:characters.name:string", Reference{Sheet: "characters", Column: "name"}, "string", true},
+		{"ref:items.stats.id", Reference{Sheet: "items", Column: "stats.id"}, "long", true},
+		{"ref:characters", Reference{}, "", false},
+		{"ref:.id", Reference{}, "", false},
+		{"ref:characters.id:unknown", Reference{}, "", false},
+		{"int", Reference{}, "", false},
+	}
+
+	for _, c := range cases {
+		ref, cellValueType, ok := ParseReferenceValueType(c.valueType)
+		if ref != c.ref || cellValueType != c.cellValueType || ok != c.ok {
+			t.Errorf("invalid parsed reference. valueType: %s, actual: %v %q %v", c.valueType, ref, cellValueType, ok)
+		}
+	}
+}
+
+func TestCheckReferences(t *testing.T) {
+	dir, err := ioutil.TempDir("", "cxtj")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	characters := newTestXlsxFile(t, "characters", [][]string{
+		{"id", "name"},
+		{"int", "string"},
+		{"ID", "Name"},
+		{"1001", "alice"},
+		{"1002", "bob"},
+	})
+	if err := characters.Save(filepath.Join(dir, "characters.xlsx")); err != nil {
+		t.Fatal(err)
+	}
+
+	parties := newTestXlsxFile(t, "parties", [][]string{
+		{"id", "leaderId", "members[0].characterId", "leaderName", "itemId"},
+		{"int", "ref:characters.id", "ref:characters.id", "ref:characters.name:string", "ref:items.id"},
+		{"ID", "Leader", "Member", "Leader name", "Item"},
+		{"1", "1001", "1002", "alice", "1"},
+		{"2", "1003", "", "carol", ""},
+	})
+	if err := parties.Save(filepath.Join(dir, "parties.xlsx")); err != nil {
+		t.Fatal(err)
+	}
+
+	c, err := NewConverter(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	converted, err := c.ConvertWorkbook(parties)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if converted["parties"][0]["leaderId"] != int64(1001) || converted["parties"][0]["leaderName"] != "alice" {
+		t.Errorf("reference cells should be converted by their value type. actual %v", converted["parties"][0])
+	}
+
+	errs, err := c.Check([]string{dir})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expect := []string{
+		filepath.Join(dir, "parties.xlsx") + ": parties!E2: referenced column items.id is not found",
+		filepath.Join(dir, "parties.xlsx") + ": parties!B5: 1003 is not found in characters.id",
+		filepath.Join(dir, "parties.xlsx") + ": parties!D5: carol is not found in characters.name",
+	}
+	if len(errs) != len(expect) {
+		t.Fatalf("invalid number of dangling references. expect: %d, actual: %v", len(expect), errs)
+	}
+	for i, e := range errs {
+		if e.Error() != expect[i] {
+			t.Errorf("invalid dangling reference. expect: %s, actual: %s", expect[i], e)
+		}
+	}
+	if _, ok := errs[1].Err.(*DanglingReferenceError); !ok {
+		t.Errorf("dangling reference should be *DanglingReferenceError, actual: %T", errs[1].Err)
+	}
+}
+
+func TestCheckBrokenFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "cxtj")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	characters := newTestXlsxFile(t, "characters", [][]string{
+		{"id"},
+		{"int"},
+		{"ID"},
+		{"1001"},
+	})
+	if err := characters.Save(filepath.Join(dir, "characters.xlsx")); err != nil {
+		t.Fatal(err)
+	}
+	brokenFile := filepath.Join(dir, "parties.xlsx")
+	if err := ioutil.WriteFile(brokenFile, []byte("broken"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	c, err := NewConverter(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = c.Check([]string{dir})
+	if convertErr, ok := err.(*ConvertError); !ok || convertErr.File != brokenFile {
+		t.Errorf("a file which cannot be opened as xlsx should fail the check. actual %v", err)
+	}
+}
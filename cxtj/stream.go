@@ -0,0 +1,491 @@
+package cxtj
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/tealeg/xlsx"
+
+	"github.com/kama2vern/cxtj/config"
+)
+
+// errStopStream stops reading rows of a sheet
+var errStopStream = errors.New("stop stream")
+
+// streamSheet is one of the sheets of xlsx files with its header read before streaming rows
+type streamSheet struct {
+	sheetSource
+	header *sheetHeader
+}
+
+// streamBooks opens xlsx files for streaming.
+// The last opened file is kept open, because sheets of the same file are usually read in a row.
+type streamBooks struct {
+	book *streamBook
+}
+
+func (b *streamBooks) open(file string) (*streamBook, error) {
+	if b.book != nil && b.book.file == file {
+		return b.book, nil
+	}
+	b.Close()
+
+	book, err := openStreamBook(file)
+	if err != nil {
+		return nil, err
+	}
+	b.book = book
+	return book, nil
+}
+
+// Close closes the opened xlsx file
+func (b *streamBooks) Close() error {
+	if b.book == nil {
+		return nil
+	}
+	err := b.book.Close()
+	b.book = nil
+	return err
+}
+
+// ConvertStream executes as the same logic as Convert, but reads rows of each sheet from the xml of xlsx files
+// and writes each row into the output as soon as it is converted, so that memory usage does not grow
+// with the number of rows. Only shared strings and styles of a xlsx file and primary keys of a sheet are kept on memory.
+// The output format should be json, and header rows should be before the data row line.
+// Each output file is written into a temporary file and renamed on success, so that it is not left
+// partially written if a row fails to be converted.
+func (c *Converter) ConvertStream(inputDirsOrFiles []string, output string, isMultipleOutput bool) error {
+	out, err := c.newOutputTarget(output, isMultipleOutput)
+	if err != nil {
+		return err
+	}
+	if out.format != FormatJSON {
+		return fmt.Errorf("streaming supports only json format, but the output format is %s", out.format)
+	}
+	dataRowLine := c.config.DataRowLine()
+	for _, excelFormat := range c.config.ExcelFormats {
+		if excelFormat.RowType != config.ExcelFormatRowTypeData && excelFormat.RowLine >= dataRowLine {
+			return fmt.Errorf("streaming requires header rows before the data row line %d, but %s row is line %d", dataRowLine, excelFormat.RowType, excelFormat.RowLine)
+		}
+	}
+
+	inputFiles, err := c.traversalInputFiles(inputDirsOrFiles)
+	if err != nil {
+		return err
+	}
+
+	books := &streamBooks{}
+	defer books.Close()
+	sheets, err := c.streamSheets(books, inputFiles)
+	if err != nil {
+		return err
+	}
+
+	if out.isMultiple {
+		return c.writeStreamEachSheet(out, books, sheets)
+	}
+	return c.writeStream(out, books, sheets)
+}
+
+// streamSheets reads headers of all sheets of xlsx files in the same order as workbookSheetSources.
+// A file which cannot be read or opened as xlsx is an error.
+func (c *Converter) streamSheets(books *streamBooks, inputFiles []string) ([]streamSheet, error) {
+	ret := []streamSheet{}
+	for _, inputFile := range inputFiles {
+		book, err := books.open(inputFile)
+		if err != nil {
+			return nil, err
+		}
+
+		sheetNames := append([]string{}, book.sheetNames...)
+		sort.Strings(sheetNames)
+		for _, sheetName := range sheetNames {
+			header, err := c.streamSheetRows(book, sheetName, nil)
+			if err != nil {
+				return nil, withFile(err, inputFile)
+			}
+			ret = append(ret, streamSheet{sheetSource: sheetSource{file: inputFile, sheet: sheetName}, header: header})
+		}
+	}
+	return ret, nil
+}
+
+// streamSheetRows reads the header of the sheet, and calls fn with each converted data row in order of rows.
+// Rows which have all empty values are skipped, and the header has no columns for an empty sheet.
+// Only the header is read when fn is nil.
+func (c *Converter) streamSheetRows(book *streamBook, sheetName string, fn func(header *sheetHeader, row sheetRow) error) (*sheetHeader, error) {
+	dataRowLine := c.config.DataRowLine()
+	headerRows := map[int][]string{}
+	lastLine := 0
+	var header *sheetHeader
+	primaryKeys := primaryKeyLines{}
+
+	err := book.readRows(sheetName, func(line int, cells []xlsx.Cell) error {
+		lastLine = line
+		if line < dataRowLine {
+			if c.config.IsHeaderRowLine(line) {
+				headerRows[line] = streamCellValues(cells)
+			}
+			return nil
+		}
+
+		if header == nil {
+			var err error
+			if header, err = c.streamSheetHeader(sheetName, headerRows, lastLine); err != nil {
+				return err
+			}
+		}
+		if fn == nil {
+			return errStopStream
+		}
+
+		row, isEmptyRow, err := c.convertRow(sheetName, header, line-1, func(j int) (string, error) {
+			if j >= len(cells) {
+				return "", nil
+			}
+			return c.cellValue(&cells[j], header.valueTypes[j])
+		})
+		if err != nil {
+			return err
+		}
+		if isEmptyRow {
+			return nil
+		}
+		if err := primaryKeys.add(sheetName, header, line-1, row); err != nil {
+			return err
+		}
+		return fn(header, sheetRow{line: line, values: row})
+	})
+	if err != nil && err != errStopStream {
+		return nil, err
+	}
+
+	if header == nil {
+		return c.streamSheetHeader(sheetName, headerRows, lastLine)
+	}
+	return header, nil
+}
+
+// streamCellValues returns stored values of cells
+func streamCellValues(cells []xlsx.Cell) []string {
+	ret := make([]string, len(cells))
+	for i, cell := range cells {
+		ret[i] = cell.Value
+	}
+	return ret
+}
+
+// streamSheetHeader builds the header from header rows read before the data row line.
+// A header row which is not stored in the xml is empty like tealeg/xlsx, unless the sheet ends before the key row.
+// An empty sheet which has no rows has no columns.
+func (c *Converter) streamSheetHeader(sheetName string, headerRows map[int][]string, lastLine int) (*sheetHeader, error) {
+	if lastLine == 0 {
+		return emptySheetHeader(), nil
+	}
+
+	keyExcelFormat, err := c.config.GetExcelFormatByRowType(config.ExcelFormatRowTypeKey)
+	if err != nil {
+		return nil, &ConvertError{Sheet: sheetName, Err: err}
+	}
+	if keyExcelFormat.RowLine > lastLine {
+		return nil, &ConvertError{Sheet: sheetName, Row: keyExcelFormat.RowLine, Err: fmt.Errorf("key row is not found")}
+	}
+
+	headerRow := func(rowType config.ExcelFormatRowType) []string {
+		excelFormat, err := c.config.GetExcelFormatByRowType(rowType)
+		if err != nil {
+			return nil
+		}
+		return headerRows[excelFormat.RowLine]
+	}
+	keys := headerRow(config.ExcelFormatRowTypeKey)
+	if keys == nil {
+		keys = []string{}
+	}
+	return c.newSheetHeader(sheetName, keys, headerRow(config.ExcelFormatRowTypeValueType), headerRow(config.ExcelFormatRowTypeComment))
+}
+
+// streamOptions merges column order and primary keys of sheets which have the same output sheet name
+// as mergeWorkbooks. sheets is grouped by the output sheet name.
+func (c *Converter) streamOptions(sheets []streamSheet, names []string) (EncoderOptions, map[string][]streamSheet, error) {
+	opts := EncoderOptions{Output: c.config.Output, ColumnOrder: map[string][]string{}, PrimaryKeys: map[string]string{}}
+	groups := map[string][]streamSheet{}
+	for i, sheet := range sheets {
+		column := sheet.header.primaryKeyColumn()
+		if _, ok := groups[names[i]]; ok {
+			if err := appendablePrimaryKeyColumn(opts.PrimaryKeys, names[i], column); err != nil {
+				return opts, nil, &ConvertError{File: sheet.file, Sheet: sheet.sheet, Err: err}
+			}
+		}
+		groups[names[i]] = append(groups[names[i]], sheet)
+
+		keys := []string{}
+		if sheet.header != nil {
+			keys = sheet.header.keys
+		}
+		opts.ColumnOrder[names[i]] = mergeColumnOrder(opts.ColumnOrder[names[i]], keys)
+		if column != "" {
+			opts.PrimaryKeys[names[i]] = column
+		}
+	}
+	return opts, groups, nil
+}
+
+func streamSheetSources(sheets []streamSheet) []sheetSource {
+	ret := make([]sheetSource, len(sheets))
+	for i, sheet := range sheets {
+		ret[i] = sheet.sheetSource
+	}
+	return ret
+}
+
+// writeStream writes all sheets into one json file in the same format as JSONEncoder.Encode
+func (c *Converter) writeStream(out *outputTarget, books *streamBooks, sheets []streamSheet) error {
+	names, duplicates, err := c.resolveSheetNames(streamSheetSources(sheets), sheetNameKey)
+	if err != nil {
+		return err
+	}
+	c.logDuplicateSheets(duplicates)
+
+	opts, groups, err := c.streamOptions(sheets, names)
+	if err != nil {
+		return err
+	}
+	sheetNames := make([]string, 0, len(groups))
+	for sheetName := range groups {
+		sheetNames = append(sheetNames, sheetName)
+	}
+	sort.Strings(sheetNames)
+
+	return c.writeFileAtomically(out.path, func(w io.Writer) error {
+		s := newJSONStreamWriter(w, c.config.Output)
+		isArray := c.config.Output.Shape == config.OutputShapeArray
+		if isArray {
+			s.begin('[')
+		} else {
+			s.begin('{')
+		}
+		for _, sheetName := range sheetNames {
+			if isArray {
+				s.element()
+				s.begin('{')
+				if err := s.key("name"); err != nil {
+					return err
+				}
+				if err := s.value(sheetName); err != nil {
+					return err
+				}
+				if err := s.key("rows"); err != nil {
+					return err
+				}
+			} else if err := s.key(sheetName); err != nil {
+				return err
+			}
+
+			if err := c.writeStreamRows(s, books, opts, sheetName, groups[sheetName]); err != nil {
+				return err
+			}
+			if isArray {
+				s.end('}')
+			}
+		}
+		if isArray {
+			s.end(']')
+		} else {
+			s.end('}')
+		}
+		return s.flush()
+	})
+}
+
+// writeStreamEachSheet writes each sheet into its own json file in the same format as JSONEncoder.EncodeSheet
+func (c *Converter) writeStreamEachSheet(out *outputTarget, books *streamBooks, sheets []streamSheet) error {
+	names, duplicates, err := c.resolveSheetNames(streamSheetSources(sheets), func(src sheetSource, sheetName string) string {
+		return c.outputFilePath(out, src.file, sheetName)
+	})
+	if err != nil {
+		return err
+	}
+	c.logDuplicateSheets(duplicates)
+
+	opts, _, err := c.streamOptions(sheets, names)
+	if err != nil {
+		return err
+	}
+	outputFiles := []string{}
+	outputSheetNames := map[string]string{}
+	outputs := map[string][]streamSheet{}
+	for i, sheet := range sheets {
+		outputFile := c.outputFilePath(out, sheet.file, names[i])
+		if _, ok := outputs[outputFile]; !ok {
+			outputFiles = append(outputFiles, outputFile)
+			outputSheetNames[outputFile] = names[i]
+		}
+		outputs[outputFile] = append(outputs[outputFile], sheet)
+	}
+
+	for _, outputFile := range outputFiles {
+		err := c.writeFileAtomically(outputFile, func(w io.Writer) error {
+			s := newJSONStreamWriter(w, c.config.Output)
+			if err := c.writeStreamRows(s, books, opts, outputSheetNames[outputFile], outputs[outputFile]); err != nil {
+				return err
+			}
+			return s.flush()
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// writeStreamRows writes rows of sheets which have the same output sheet name as an array,
+// or an object keyed by the primary key if the row shape is keyed.
+// Primary keys of appended sheets should not be duplicate like appendablePrimaryKey.
+func (c *Converter) writeStreamRows(s *jsonStreamWriter, books *streamBooks, opts EncoderOptions, sheetName string, sheets []streamSheet) error {
+	tree := columnTree(opts.ColumnOrder[sheetName])
+	keyPath, isKeyed := opts.primaryKeyPath(sheetName)
+	if isKeyed {
+		s.begin('{')
+	} else {
+		s.begin('[')
+	}
+
+	// appendedKeys is primary keys of the former sheets
+	var appendedKeys map[string]bool
+	if len(sheets) > 1 {
+		appendedKeys = map[string]bool{}
+	}
+	for _, sheet := range sheets {
+		book, err := books.open(sheet.file)
+		if err != nil {
+			return err
+		}
+
+		keys := []string{}
+		_, err = c.streamSheetRows(book, sheet.sheet, func(header *sheetHeader, row sheetRow) error {
+			if appendedKeys != nil && header.primaryKey >= 0 {
+				key := getPathValue(row.values, header.paths[header.primaryKey])
+				if appendedKeys[primaryKeyString(key)] {
+					return &ConvertError{Sheet: sheet.sheet, Err: fmt.Errorf("duplicate primary key %v in appended sheet", key)}
+				}
+				keys = append(keys, primaryKeyString(key))
+			}
+
+			if isKeyed {
+				if err := s.key(primaryKeyString(getPathValue(row.values, keyPath))); err != nil {
+					return err
+				}
+			} else {
+				s.element()
+			}
+			return s.value(opts.orderObject(tree, "", row.values))
+		})
+		if err != nil {
+			return withFile(err, sheet.file)
+		}
+		for _, key := range keys {
+			appendedKeys[key] = true
+		}
+	}
+
+	if isKeyed {
+		s.end('}')
+	} else {
+		s.end(']')
+	}
+	return nil
+}
+
+// jsonStreamWriter writes elements of json objects and arrays one by one
+// in the same format as json.Encoder used by JSONEncoder.
+type jsonStreamWriter struct {
+	w *bufio.Writer
+	// indent is the indent of pretty output, or empty for compact output
+	indent string
+	// counts is the number of written elements of each open object or array
+	counts []int
+}
+
+func newJSONStreamWriter(w io.Writer, output config.OutputConfig) *jsonStreamWriter {
+	s := &jsonStreamWriter{w: bufio.NewWriter(newNewlineWriter(w, output.Newline))}
+	if output.Pretty {
+		s.indent = output.Indent
+	}
+	return s
+}
+
+// begin opens an object or an array by '{' or '['
+func (s *jsonStreamWriter) begin(open byte) {
+	s.w.WriteByte(open)
+	s.counts = append(s.counts, 0)
+}
+
+// end closes the object or the array by '}' or ']'
+func (s *jsonStreamWriter) end(close byte) {
+	count := s.counts[len(s.counts)-1]
+	s.counts = s.counts[:len(s.counts)-1]
+	if count > 0 {
+		s.newline()
+	}
+	s.w.WriteByte(close)
+}
+
+func (s *jsonStreamWriter) newline() {
+	if s.indent == "" {
+		return
+	}
+	s.w.WriteByte('\n')
+	s.w.WriteString(strings.Repeat(s.indent, len(s.counts)))
+}
+
+// element starts the next element of the array
+func (s *jsonStreamWriter) element() {
+	if s.counts[len(s.counts)-1] > 0 {
+		s.w.WriteByte(',')
+	}
+	s.counts[len(s.counts)-1]++
+	s.newline()
+}
+
+// key starts the next element of the object by the key
+func (s *jsonStreamWriter) key(k string) error {
+	s.element()
+	bs, err := json.Marshal(k)
+	if err != nil {
+		return err
+	}
+	s.w.Write(bs)
+	s.w.WriteByte(':')
+	if s.indent != "" {
+		s.w.WriteByte(' ')
+	}
+	return nil
+}
+
+// value writes v as a value of the current element
+func (s *jsonStreamWriter) value(v interface{}) error {
+	var bs []byte
+	var err error
+	if s.indent == "" {
+		bs, err = json.Marshal(v)
+	} else {
+		bs, err = json.MarshalIndent(v, strings.Repeat(s.indent, len(s.counts)), s.indent)
+	}
+	if err != nil {
+		return err
+	}
+	_, err = s.w.Write(bs)
+	return err
+}
+
+// flush ends the json with a newline like json.Encoder and writes buffered data
+func (s *jsonStreamWriter) flush() error {
+	s.w.WriteByte('\n')
+	return s.w.Flush()
+}
@@ -0,0 +1,409 @@
+package cxtj
+
+import (
+	"archive/zip"
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/tealeg/xlsx"
+
+	"github.com/kama2vern/cxtj/config"
+)
+
+func TestConvertStreamIsSameAsConvert(t *testing.T) {
+	dir, _ := os.Getwd()
+	inputFiles := []string{
+		path.Join(dir, "..", "test", "excels", "convert_test.xlsx"),
+		path.Join(dir, "..", "test", "excels", "convert_test2.xlsx"),
+		path.Join(dir, "..", "test", "excels", "data01.xlsx"),
+		path.Join(dir, "..", "test", "excels", "master.xlsx"),
+	}
+	outputDir, err := ioutil.TempDir("", "cxtj")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(outputDir)
+
+	testCases := []struct {
+		name   string
+		config func(conf *config.Config)
+	}{
+		{"default", func(conf *config.Config) {}},
+		{"pretty", func(conf *config.Config) {
+			conf.Output.Pretty = true
+			conf.Output.Indent = "  "
+		}},
+		{"array", func(conf *config.Config) {
+			conf.Output.Shape = config.OutputShapeArray
+			conf.Output.Pretty = true
+			conf.Output.Indent = "\t"
+		}},
+		{"column", func(conf *config.Config) {
+			conf.Output.KeyOrder = config.KeyOrderColumn
+		}},
+		{"keyed", func(conf *config.Config) {
+			conf.Output.RowShape = config.RowShapeKeyed
+			conf.Output.Pretty = true
+			conf.Output.Indent = "  "
+			conf.PrimaryKeys = map[string]string{"sheet": "id"}
+		}},
+	}
+
+	for _, tc := range testCases {
+		for _, isMultipleOutput := range []bool{false, true} {
+			conf := *config.DefaultConfig
+			tc.config(&conf)
+			c, err := NewConverter(&conf)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			expect := path.Join(outputDir, tc.name, "convert")
+			actual := path.Join(outputDir, tc.name, "stream")
+			if !isMultipleOutput {
+				expect += ".json"
+				actual += ".json"
+			}
+			if err := c.Convert(inputFiles, expect, isMultipleOutput); err != nil {
+				t.Fatal(err)
+			}
+			if err := c.ConvertStream(inputFiles, actual, isMultipleOutput); err != nil {
+				t.Fatalf("%s: %s", tc.name, err)
+			}
+
+			expectFiles := []string{expect}
+			if isMultipleOutput {
+				expectFiles, _ = filepath.Glob(path.Join(expect, "*"))
+				actualFiles, _ := filepath.Glob(path.Join(actual, "*"))
+				if len(actualFiles) != len(expectFiles) {
+					t.Errorf("%s: the number of output files is different. expect %d, actual %d", tc.name, len(expectFiles), len(actualFiles))
+				}
+			}
+			for _, expectFile := range expectFiles {
+				actualFile := path.Join(actual, path.Base(expectFile))
+				if !isMultipleOutput {
+					actualFile = actual
+				}
+				expectBytes, err := ioutil.ReadFile(expectFile)
+				if err != nil {
+					t.Fatal(err)
+				}
+				actualBytes, err := ioutil.ReadFile(actualFile)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if !bytes.Equal(actualBytes, expectBytes) {
+					t.Errorf("%s: %s of streaming is different from Convert", tc.name, path.Base(expectFile))
+				}
+			}
+		}
+	}
+}
+
+func TestConvertStreamFormatsStringColumnsSameAsConvert(t *testing.T) {
+	outputDir, err := ioutil.TempDir("", "cxtj")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(outputDir)
+
+	inputFile := path.Join(outputDir, "formats.xlsx")
+	writeTestXlsxZip(t, inputFile, `<?xml version="1.0" encoding="UTF-8"?>
+<sst xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main">
+<si><t>id</t></si><si><t>price</t></si>
+<si><t>int</t></si><si><t>string</t></si>
+<si><t>ID</t></si><si><t>Price</t></si>
+</sst>`, `<?xml version="1.0" encoding="UTF-8"?>
+<styleSheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main">
+<cellXfs count="2"><xf numFmtId="0"/><xf numFmtId="2"/></cellXfs>
+</styleSheet>`, `<?xml version="1.0" encoding="UTF-8"?>
+<worksheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main">
+<sheetData>
+<row r="1"><c r="A1" t="s"><v>0</v></c><c r="B1" t="s"><v>1</v></c></row>
+<row r="2"><c r="A2" t="s"><v>2</v></c><c r="B2" t="s"><v>3</v></c></row>
+<row r="3"><c r="A3" t="s"><v>4</v></c><c r="B3" t="s"><v>5</v></c></row>
+<row r="4"><c r="A4"><v>1</v></c><c r="B4" s="1"><v>1.5</v></c></row>
+</sheetData>
+</worksheet>`)
+
+	c, err := NewConverter(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expect := path.Join(outputDir, "convert.json")
+	actual := path.Join(outputDir, "stream.json")
+	if err := c.Convert([]string{inputFile}, expect, false); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.ConvertStream([]string{inputFile}, actual, false); err != nil {
+		t.Fatal(err)
+	}
+
+	expectBytes, err := ioutil.ReadFile(expect)
+	if err != nil {
+		t.Fatal(err)
+	}
+	actualBytes, err := ioutil.ReadFile(actual)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(actualBytes, expectBytes) || !bytes.Contains(actualBytes, []byte(`"price":"1.50"`)) {
+		t.Errorf("formatted number of string column of streaming is different from Convert. expect %s, actual %s", expectBytes, actualBytes)
+	}
+}
+
+func TestConvertStreamErrors(t *testing.T) {
+	dir, _ := os.Getwd()
+	inputFiles := []string{path.Join(dir, "..", "test", "excels", "convert_test.xlsx")}
+	outputDir, err := ioutil.TempDir("", "cxtj")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(outputDir)
+
+	c, err := NewConverter(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := c.ConvertStream(inputFiles, path.Join(outputDir, "output.yaml"), false); err == nil {
+		t.Error("streaming into yaml should be error")
+	}
+
+	conf := *config.DefaultConfig
+	conf.ExcelFormats = []config.ExcelFormat{
+		{RowType: config.ExcelFormatRowTypeKey, RowLine: 1},
+		{RowType: config.ExcelFormatRowTypeData, RowLine: 2},
+		{RowType: config.ExcelFormatRowTypeValueType, RowLine: 3},
+	}
+	c, err = NewConverter(&conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := c.ConvertStream(inputFiles, path.Join(outputDir, "output.json"), false); err == nil {
+		t.Error("header row after the data row line should be error")
+	}
+}
+
+func TestConvertStreamKeepsOutputOnError(t *testing.T) {
+	outputDir, err := ioutil.TempDir("", "cxtj")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(outputDir)
+
+	inputFile := path.Join(outputDir, "input.xlsx")
+	f := newTestXlsxFile(t, "sheet", [][]string{
+		{"id"},
+		{"int"},
+		{"ID"},
+		{"1"},
+		{"abc"},
+	})
+	if err := f.Save(inputFile); err != nil {
+		t.Fatal(err)
+	}
+	outputFile := path.Join(outputDir, "output.json")
+	if err := ioutil.WriteFile(outputFile, []byte("previous"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	c, err := NewConverter(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := c.ConvertStream([]string{inputFile}, outputFile, false); err == nil {
+		t.Fatal("invalid int value should be error")
+	}
+	bs, err := ioutil.ReadFile(outputFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(bs) != "previous" {
+		t.Errorf("output should not be written partially. actual %q", bs)
+	}
+	if files, _ := filepath.Glob(path.Join(outputDir, ".*")); len(files) != 0 {
+		t.Errorf("temporary files should be removed. actual %v", files)
+	}
+}
+
+// writeTestXlsxZip writes a xlsx file which has one sheet of the sheet xml.
+// styles.xml is written only if styles is not empty.
+func writeTestXlsxZip(t *testing.T, filename string, sharedStrings string, styles string, sheet string) {
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	files := []struct{ name, content string }{
+		{"xl/workbook.xml", `<?xml version="1.0" encoding="UTF-8"?>
+<workbook xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main" xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships">
+<sheets><sheet name="sheet" sheetId="1" r:id="rId1"/></sheets></workbook>`},
+		{"xl/_rels/workbook.xml.rels", `<?xml version="1.0" encoding="UTF-8"?>
+<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">
+<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/worksheet" Target="worksheets/sheet1.xml"/>
+</Relationships>`},
+		{"xl/sharedStrings.xml", sharedStrings},
+		{"xl/worksheets/sheet1.xml", sheet},
+	}
+	if styles != "" {
+		files = append(files, struct{ name, content string }{"xl/styles.xml", styles})
+	}
+	for _, f := range files {
+		w, err := zw.Create(f.name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := w.Write([]byte(f.content)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(filename, buf.Bytes(), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestStreamBookReadRows(t *testing.T) {
+	outputDir, err := ioutil.TempDir("", "cxtj")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(outputDir)
+
+	filename := path.Join(outputDir, "stream.xlsx")
+	writeTestXlsxZip(t, filename, `<?xml version="1.0" encoding="UTF-8"?>
+<sst xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main">
+<si><t>id</t></si>
+<si><r><t>na</t></r><r><t>me</t></r></si>
+<si><t>名前</t><rPh sb="0" eb="2"><t>ナマエ</t></rPh></si>
+</sst>`, "", `<?xml version="1.0" encoding="UTF-8"?>
+<worksheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main">
+<sheetData>
+<row r="1" spans="1:3"><c r="A1" t="s"><v>0</v></c><c r="B1" t="s"><v>1</v></c></row>
+<row r="3"><c r="A3"><v>1</v></c><c r="C3" t="s"><v>2</v></c></row>
+<row r="4"><c r="B4" t="inlineStr"><is><t>inline</t></is></c><c r="C4" t="b"><v>1</v></c></row>
+</sheetData>
+</worksheet>`)
+
+	book, err := openStreamBook(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer book.Close()
+	if !reflect.DeepEqual(book.sheetNames, []string{"sheet"}) {
+		t.Errorf("invalid sheet names: %v", book.sheetNames)
+	}
+
+	rows := map[int][]string{}
+	err = book.readRows("sheet", func(line int, cells []xlsx.Cell) error {
+		rows[line] = streamCellValues(cells)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expect := map[int][]string{
+		1: {"id", "name", ""},
+		3: {"1", "", "名前"},
+		4: {"", "inline", "1"},
+	}
+	if !reflect.DeepEqual(rows, expect) {
+		t.Errorf("invalid rows. expect %v, actual %v", expect, rows)
+	}
+}
+
+func TestStreamBookNumberFormats(t *testing.T) {
+	outputDir, err := ioutil.TempDir("", "cxtj")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(outputDir)
+
+	filename := path.Join(outputDir, "styles.xlsx")
+	writeTestXlsxZip(t, filename, `<?xml version="1.0" encoding="UTF-8"?>
+<sst xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main"></sst>`, `<?xml version="1.0" encoding="UTF-8"?>
+<styleSheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main">
+<numFmts count="1"><numFmt numFmtId="164" formatCode="0.000"/></numFmts>
+<cellXfs count="3"><xf numFmtId="0"/><xf numFmtId="2"/><xf numFmtId="164"/></cellXfs>
+</styleSheet>`, `<?xml version="1.0" encoding="UTF-8"?>
+<worksheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main">
+<sheetData>
+<row r="1"><c r="A1" s="0"><v>1.5</v></c><c r="B1" s="1"><v>1.5</v></c><c r="C1" s="2"><v>1.5</v></c></row>
+</sheetData>
+</worksheet>`)
+
+	book, err := openStreamBook(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer book.Close()
+	actual := []string{}
+	err = book.readRows("sheet", func(line int, cells []xlsx.Cell) error {
+		for _, cell := range cells {
+			value, err := cell.String()
+			if err != nil {
+				return err
+			}
+			actual = append(actual, value)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// values of string columns are formatted by number formats in the same way as tealeg/xlsx
+	xFile, err := xlsx.OpenFile(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expect := []string{}
+	for _, cell := range xFile.Sheets[0].Rows[0].Cells {
+		value, err := cell.String()
+		if err != nil {
+			t.Fatal(err)
+		}
+		expect = append(expect, value)
+	}
+	if !reflect.DeepEqual(actual, expect) || actual[1] != "1.50" {
+		t.Errorf("invalid formatted values. expect %v, actual %v", expect, actual)
+	}
+}
+
+func BenchmarkConvertHeavy(b *testing.B) {
+	benchmarkConvertHeavy(b, func(c *Converter, inputFiles []string, output string) error {
+		return c.Convert(inputFiles, output, false)
+	})
+}
+
+func BenchmarkConvertStreamHeavy(b *testing.B) {
+	benchmarkConvertHeavy(b, func(c *Converter, inputFiles []string, output string) error {
+		return c.ConvertStream(inputFiles, output, false)
+	})
+}
+
+func benchmarkConvertHeavy(b *testing.B, convert func(c *Converter, inputFiles []string, output string) error) {
+	dir, _ := os.Getwd()
+	inputFiles := []string{path.Join(dir, "..", "test", "excels", "heavy.xlsx")}
+	outputDir, err := ioutil.TempDir("", "cxtj")
+	if err != nil {
+		b.Fatal(err)
+	}
+	defer os.RemoveAll(outputDir)
+
+	c, err := NewConverter(nil)
+	if err != nil {
+		b.Fatal(err)
+	}
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if err := convert(c, inputFiles, path.Join(outputDir, "heavy.json")); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
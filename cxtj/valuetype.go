@@ -0,0 +1,207 @@
+package cxtj
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// Value types which can be declared in the value-type row
+const (
+	ValueTypeInt    = "int"
+	ValueTypeLong   = "long"
+	ValueTypeFloat  = "float"
+	ValueTypeDouble = "double"
+	ValueTypeBool   = "bool"
+	ValueTypeString = "string"
+)
+
+// isKnownValueType reports whether the value type is one of the supported types.
+// Unknown or empty value types are converted as string for compatibility.
+func isKnownValueType(valueType string) bool {
+	switch baseValueType(valueType) {
+	case ValueTypeInt, ValueTypeLong, ValueTypeFloat, ValueTypeDouble, ValueTypeBool, ValueTypeString:
+		return true
+	}
+	_, _, ok := ParseArrayValueType(valueType)
+	return ok
+}
+
+// ParseArrayValueType parses an array value type like "int[]" into the element type.
+// The delimiter of elements can be declared in brackets like "string[|]", and it is empty for "int[]".
+func ParseArrayValueType(valueType string) (elementType string, delimiter string, ok bool) {
+	open := strings.IndexByte(valueType, '[')
+	if open < 0 || !strings.HasSuffix(valueType, "]") {
+		return "", "", false
+	}
+	elementType = valueType[:open]
+	delimiter = valueType[open+1 : len(valueType)-1]
+	if strings.ContainsAny(delimiter, "[]\\") {
+		return "", "", false
+	}
+	switch elementType {
+	case ValueTypeInt, ValueTypeLong, ValueTypeFloat, ValueTypeDouble, ValueTypeBool, ValueTypeString:
+		return elementType, delimiter, true
+	}
+	return "", "", false
+}
+
+// parsePrimaryKeyValueType parses a value type with "!" suffix like "int!" which declares the primary key
+func parsePrimaryKeyValueType(valueType string) (string, bool) {
+	if !strings.HasSuffix(valueType, "!") {
+		return valueType, false
+	}
+	return strings.TrimSuffix(valueType, "!"), true
+}
+
+// primaryKeyString makes primary keys comparable and usable as object keys regardless of value types
+func primaryKeyString(key interface{}) string {
+	return fmt.Sprint(key)
+}
+
+// isStringValueType reports whether the value type is output as string
+func isStringValueType(valueType string) bool {
+	return !isKnownValueType(valueType) || baseValueType(valueType) == ValueTypeString
+}
+
+// baseValueType returns the value type of cells of a reference value type, otherwise the value type itself
+func baseValueType(valueType string) string {
+	if _, t, ok := ParseReferenceValueType(valueType); ok {
+		return t
+	}
+	return valueType
+}
+
+// isArrayValueType reports whether the value type is an array
+func isArrayValueType(valueType string) bool {
+	_, _, ok := ParseArrayValueType(valueType)
+	return ok
+}
+
+// convertArrayValue splits a raw cell value by the delimiter and converts each element into the element type.
+// A backslash escapes the delimiter and a backslash itself. Elements of string are kept as they are,
+// and the other elements are trimmed and should not be empty.
+func convertArrayValue(elementType string, delimiter string, value string) ([]interface{}, error) {
+	ret := []interface{}{}
+	for i, element := range splitArrayValue(value, delimiter) {
+		if elementType == ValueTypeString {
+			ret = append(ret, element)
+			continue
+		}
+
+		if strings.TrimSpace(element) == "" {
+			return nil, fmt.Errorf("element %d of %q is empty", i+1, value)
+		}
+		v, err := convertValue(elementType, element)
+		if err != nil {
+			return nil, fmt.Errorf("element %d of %q: %s", i+1, value, err)
+		}
+		ret = append(ret, v)
+	}
+	return ret, nil
+}
+
+// splitArrayValue splits the value by the delimiter and unescapes "\\<delimiter>" and "\\\\".
+// A backslash followed by the other characters is kept as it is.
+func splitArrayValue(value string, delimiter string) []string {
+	ret := []string{}
+	var b strings.Builder
+	for i := 0; i < len(value); {
+		switch {
+		case value[i] == '\\' && strings.HasPrefix(value[i+1:], delimiter):
+			b.WriteString(delimiter)
+			i += 1 + len(delimiter)
+		case value[i] == '\\' && strings.HasPrefix(value[i+1:], "\\"):
+			b.WriteByte('\\')
+			i += 2
+		case strings.HasPrefix(value[i:], delimiter):
+			ret = append(ret, b.String())
+			b.Reset()
+			i += len(delimiter)
+		default:
+			b.WriteByte(value[i])
+			i++
+		}
+	}
+	return append(ret, b.String())
+}
+
+// convertValue converts a raw cell value into the go value of the declared value type.
+// An empty value is converted into "" for string and nil (null) for other types.
+func convertValue(valueType string, value string) (interface{}, error) {
+	if isStringValueType(valueType) {
+		return value, nil
+	}
+
+	v := strings.TrimSpace(value)
+	if v == "" {
+		return nil, nil
+	}
+
+	valueType = baseValueType(valueType)
+	switch valueType {
+	case ValueTypeInt:
+		return parseInteger(v, 32)
+	case ValueTypeLong:
+		return parseInteger(v, 64)
+	case ValueTypeFloat, ValueTypeDouble:
+		f, err := strconv.ParseFloat(v, 64)
+		if err != nil || math.IsNaN(f) || math.IsInf(f, 0) {
+			return nil, fmt.Errorf("%q is not a valid %s value", value, valueType)
+		}
+		return f, nil
+	case ValueTypeBool:
+		b, err := strconv.ParseBool(v)
+		if err != nil {
+			return nil, fmt.Errorf("%q is not a valid %s value", value, valueType)
+		}
+		return b, nil
+	}
+	return value, nil
+}
+
+// parseInteger parses integer value.
+// Numeric cells sometimes hold integral values as float like "100.0" or "1e3", so they are also accepted.
+func parseInteger(v string, bitSize int) (int64, error) {
+	typeName := ValueTypeInt
+	if bitSize == 64 {
+		typeName = ValueTypeLong
+	}
+
+	i, err := strconv.ParseInt(v, 10, bitSize)
+	if err == nil {
+		return i, nil
+	}
+
+	f, ferr := strconv.ParseFloat(v, 64)
+	if ferr != nil || f != math.Trunc(f) ||
+		f < -math.Pow(2, float64(bitSize-1)) || f >= math.Pow(2, float64(bitSize-1)) {
+		return 0, fmt.Errorf("%q is not a valid %s value", v, typeName)
+	}
+	return int64(f), nil
+}
+
+// isEmptyValue reports whether the raw cell value is empty for the value type
+func isEmptyValue(valueType string, value string) bool {
+	if isStringValueType(valueType) {
+		return value == ""
+	}
+	return strings.TrimSpace(value) == ""
+}
+
+// zeroValue returns the zero value of the value type
+func zeroValue(valueType string) interface{} {
+	switch baseValueType(valueType) {
+	case ValueTypeInt, ValueTypeLong:
+		return int64(0)
+	case ValueTypeFloat, ValueTypeDouble:
+		return float64(0)
+	case ValueTypeBool:
+		return false
+	}
+	if isArrayValueType(valueType) {
+		return []interface{}{}
+	}
+	return ""
+}
@@ -0,0 +1,134 @@
+package cxtj
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestConvertValue(t *testing.T) {
+	cases := []struct {
+		valueType string
+		value     string
+		expect    interface{}
+	}{
+		{"int", "100", int64(100)},
+		{"int", "-1", int64(-1)},
+		{"int", "100.0", int64(100)},
+		{"int", "", nil},
+		{"long", "9007199254740993", int64(9007199254740993)},
+		{"float", "1.5", float64(1.5)},
+		{"double", "2", float64(2)},
+		{"bool", "TRUE", true},
+		{"bool", "0", false},
+		{"string", "100", "100"},
+		{"string", "", ""},
+		{"unknown", "abc", "abc"},
+		{"", "abc", "abc"},
+	}
+
+	for _, c := range cases {
+		actual, err := convertValue(c.valueType, c.value)
+		if err != nil {
+			t.Errorf("unexpected error. valueType: %s, value: %s, error: %s", c.valueType, c.value, err)
+			continue
+		}
+		if actual != c.expect {
+			t.Errorf("invalid converted value. valueType: %s, value: %s, expect: %#v, actual: %#v", c.valueType, c.value, c.expect, actual)
+		}
+	}
+}
+
+func TestConvertValueMismatch(t *testing.T) {
+	cases := []struct {
+		valueType string
+		value     string
+	}{
+		{"int", "abc"},
+		{"int", "1.5"},
+		{"int", "2147483648"},
+		{"long", "9223372036854775808"},
+		{"float", "NaN"},
+		{"float", "1.2.3"},
+		{"bool", "yes"},
+	}
+
+	for _, c := range cases {
+		if _, err := convertValue(c.valueType, c.value); err == nil {
+			t.Errorf("mismatched value should be error. valueType: %s, value: %s", c.valueType, c.value)
+		}
+	}
+}
+
+func TestParseArrayValueType(t *testing.T) {
+	cases := []struct {
+		valueType   string
+		elementType string
+		delimiter   string
+		ok          bool
+	}{
+		{"int[]", "int", "", true},
+		{"string[|]", "string", "|", true},
+		{"float[ / ]", "float", " / ", true},
+		{"int", "", "", false},
+		{"unknown[]", "", "", false},
+		{"int[\\]", "", "", false},
+		{"int[]]", "", "", false},
+	}
+
+	for _, c := range cases {
+		elementType, delimiter, ok := ParseArrayValueType(c.valueType)
+		if elementType != c.elementType || delimiter != c.delimiter || ok != c.ok {
+			t.Errorf("invalid parsed array value type. valueType: %s, actual: %q %q %v", c.valueType, elementType, delimiter, ok)
+		}
+	}
+}
+
+func TestConvertArrayValue(t *testing.T) {
+	cases := []struct {
+		elementType string
+		delimiter   string
+		value       string
+		expect      []interface{}
+	}{
+		{"int", ",", "1, 2,3", []interface{}{int64(1), int64(2), int64(3)}},
+		{"float", ",", "1.5", []interface{}{float64(1.5)}},
+		{"bool", "|", "true|0", []interface{}{true, false}},
+		{"string", ",", "a, b", []interface{}{"a", " b"}},
+		{"string", ",", "a,,b", []interface{}{"a", "", "b"}},
+		{"string", ",", `a\,b,c`, []interface{}{"a,b", "c"}},
+		{"string", ",", `a\\,b`, []interface{}{`a\`, "b"}},
+		{"string", ",", `C:\path`, []interface{}{`C:\path`}},
+		{"string", "::", "a::b:c", []interface{}{"a", "b:c"}},
+	}
+
+	for _, c := range cases {
+		actual, err := convertArrayValue(c.elementType, c.delimiter, c.value)
+		if err != nil {
+			t.Errorf("unexpected error. value: %s, error: %s", c.value, err)
+			continue
+		}
+		if !reflect.DeepEqual(actual, c.expect) {
+			t.Errorf("invalid converted array. value: %s, expect: %#v, actual: %#v", c.value, c.expect, actual)
+		}
+	}
+}
+
+func TestConvertArrayValueMismatch(t *testing.T) {
+	cases := []struct {
+		elementType string
+		value       string
+		message     string
+	}{
+		{"int", "1,abc,3", `element 2 of "1,abc,3": `},
+		{"int", "1,,3", `element 2 of "1,,3" is empty`},
+		{"bool", "true,", `element 2 of "true," is empty`},
+	}
+
+	for _, c := range cases {
+		_, err := convertArrayValue(c.elementType, ",", c.value)
+		if err == nil || !strings.HasPrefix(err.Error(), c.message) {
+			t.Errorf("invalid error of mismatched element. value: %s, expect: %s, actual: %v", c.value, c.message, err)
+		}
+	}
+}
@@ -0,0 +1,220 @@
+package cxtj
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+
+	"github.com/kama2vern/cxtj/logger"
+)
+
+// DefaultWatchDebounce is the time to wait for a burst of saves before reconversion
+const DefaultWatchDebounce = 500 * time.Millisecond
+
+// isExcelLockFile reports whether the file is a lock file created by Excel while a workbook is open like "~$book.xlsx"
+func isExcelLockFile(filename string) bool {
+	return strings.HasPrefix(filepath.Base(filename), "~$")
+}
+
+// watchTargets is input paths to be watched
+type watchTargets struct {
+	// dirs is input directories, whose xlsx files are watched recursively
+	dirs []string
+	// files is input xlsx files
+	files map[string]bool
+}
+
+// newWatchTargets adds watches of input directories recursively and parent directories of input files.
+// Directories are watched instead of files because editors often save a file by replacing it.
+func (c *Converter) newWatchTargets(watcher *fsnotify.Watcher, inputDirsOrFiles []string) (*watchTargets, error) {
+	targets := &watchTargets{files: map[string]bool{}}
+	for _, inputDirOrFile := range inputDirsOrFiles {
+		fi, err := os.Stat(inputDirOrFile)
+		if err != nil {
+			return nil, err
+		}
+
+		if fi.IsDir() {
+			targets.dirs = append(targets.dirs, filepath.Clean(inputDirOrFile))
+			if err := addWatchDirs(watcher, inputDirOrFile); err != nil {
+				return nil, err
+			}
+			continue
+		}
+
+		targets.files[filepath.Clean(inputDirOrFile)] = true
+		if err := watcher.Add(filepath.Dir(inputDirOrFile)); err != nil {
+			return nil, err
+		}
+	}
+	return targets, nil
+}
+
+// addWatchDirs adds watches of the directory and its subdirectories
+func addWatchDirs(watcher *fsnotify.Watcher, dir string) error {
+	return filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() {
+			return watcher.Add(path)
+		}
+		return nil
+	})
+}
+
+// isInputDir reports whether the path is in one of input directories
+func (t *watchTargets) isInputDir(path string) bool {
+	for _, dir := range t.dirs {
+		if strings.HasPrefix(path, dir+string(filepath.Separator)) {
+			return true
+		}
+	}
+	return false
+}
+
+// isWatchTarget reports whether the changed path is an input xlsx file
+func (c *Converter) isWatchTarget(targets *watchTargets, path string) bool {
+	path = filepath.Clean(path)
+	if !c.isExcelFile(path) {
+		return false
+	}
+	return targets.files[path] || targets.isInputDir(path)
+}
+
+// Watch converts xlsx files or directories like Convert, and then reconverts xlsx files which are changed
+// until stop is closed. Changes are debounced, and only changed workbooks are converted again.
+// Errors of conversion are logged and watching continues, and the previous result of the workbook is kept.
+func (c *Converter) Watch(inputDirsOrFiles []string, output string, isMultipleOutput bool, stop <-chan struct{}) error {
+	out, err := c.newOutputTarget(output, isMultipleOutput)
+	if err != nil {
+		return err
+	}
+
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return err
+	}
+	defer watcher.Close()
+
+	targets, err := c.newWatchTargets(watcher, inputDirsOrFiles)
+	if err != nil {
+		return err
+	}
+
+	inputFiles, err := c.traversalInputFiles(inputDirsOrFiles)
+	if err != nil {
+		return err
+	}
+	converted := map[string]workbook{}
+	changed := map[string]bool{}
+	for _, inputFile := range inputFiles {
+		changed[filepath.Clean(inputFile)] = true
+	}
+	if err := c.reconvert(out, inputDirsOrFiles, converted, changed); err != nil {
+		logger.Log("error", err.Error())
+	}
+
+	debounce := c.watchDebounce
+	if debounce <= 0 {
+		debounce = DefaultWatchDebounce
+	}
+	timer := time.NewTimer(debounce)
+	timer.Stop()
+
+	pending := map[string]bool{}
+	for {
+		select {
+		case <-stop:
+			return nil
+		case event, ok := <-watcher.Events:
+			if !ok {
+				return nil
+			}
+			if event.Op&fsnotify.Create != 0 && targets.isInputDir(filepath.Clean(event.Name)) {
+				// a directory created or moved into the input directory may already have xlsx files
+				if fi, err := os.Stat(event.Name); err == nil && fi.IsDir() {
+					if err := addWatchDirs(watcher, event.Name); err != nil {
+						logger.Log("error", err.Error())
+					}
+					files, _ := c.traversalInputFiles([]string{event.Name})
+					for _, file := range files {
+						pending[filepath.Clean(file)] = true
+					}
+					timer.Reset(debounce)
+					continue
+				}
+			}
+			if event.Op == fsnotify.Chmod || !c.isWatchTarget(targets, event.Name) {
+				continue
+			}
+			pending[filepath.Clean(event.Name)] = true
+			timer.Reset(debounce)
+		case err, ok := <-watcher.Errors:
+			if !ok {
+				return nil
+			}
+			logger.Log("error", err.Error())
+		case <-timer.C:
+			if err := c.reconvert(out, inputDirsOrFiles, converted, pending); err != nil {
+				logger.Log("error", err.Error())
+			}
+			pending = map[string]bool{}
+		}
+	}
+}
+
+// reconvert converts the changed xlsx files into converted and writes outputs of them.
+// A removed file is removed from converted, and a file which cannot be converted like a half-saved xlsx
+// keeps its previous workbook.
+func (c *Converter) reconvert(out *outputTarget, inputDirsOrFiles []string, converted map[string]workbook, changed map[string]bool) error {
+	paths := make([]string, 0, len(changed))
+	for path := range changed {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+
+	for _, path := range paths {
+		if _, err := os.Stat(path); os.IsNotExist(err) {
+			if _, ok := converted[path]; ok {
+				delete(converted, path)
+				logger.Log("removed", path)
+			}
+			continue
+		}
+
+		book, err := c.convertXlsxFile(context.Background(), path)
+		if err != nil {
+			logger.Log("error", err.Error())
+			delete(changed, path)
+			continue
+		}
+		converted[path] = book
+		logger.Log("converted", path)
+	}
+
+	inputFiles, err := c.traversalInputFiles(inputDirsOrFiles)
+	if err != nil {
+		return err
+	}
+	books := make([]workbook, 0, len(inputFiles))
+	for _, inputFile := range inputFiles {
+		if book, ok := converted[filepath.Clean(inputFile)]; ok {
+			books = append(books, book)
+		}
+	}
+	if len(books) == 0 {
+		return fmt.Errorf("no xlsx file is converted")
+	}
+
+	if out.isMultiple {
+		return c.writeWorkbooksEachSheet(out, books, changed)
+	}
+	return c.writeWorkbooks(out, books)
+}
@@ -0,0 +1,167 @@
+package cxtj
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestIsExcelFileIgnoresLockFile(t *testing.T) {
+	c, err := NewConverter(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for file, expect := range map[string]bool{
+		"dir/book.xlsx":   true,
+		"dir/~$book.xlsx": false,
+		"~$book.xlsx":     false,
+		"dir/book.xls":    false,
+	} {
+		if c.isExcelFile(file) != expect {
+			t.Errorf("invalid excel file detection of %s. expect: %v", file, expect)
+		}
+	}
+}
+
+// waitForFile waits until the file satisfies the condition
+func waitForFile(t *testing.T, file string, cond func(bs []byte) bool) {
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		if bs, err := ioutil.ReadFile(file); err == nil && cond(bs) {
+			return
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	t.Fatalf("timeout waiting for %s", file)
+}
+
+func copyTestFile(t *testing.T, src string, dst string) {
+	bs, err := ioutil.ReadFile(src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(dst, bs, 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestWatch(t *testing.T) {
+	dir, _ := os.Getwd()
+	excels := path.Join(dir, "..", "test", "excels")
+
+	inputDir, err := ioutil.TempDir("", "cxtj")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(inputDir)
+	outputDir, err := ioutil.TempDir("", "cxtj")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(outputDir)
+
+	copyTestFile(t, filepath.Join(excels, "convert_test.xlsx"), filepath.Join(inputDir, "a.xlsx"))
+
+	c, err := NewConverter(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.watchDebounce = 50 * time.Millisecond
+
+	stop := make(chan struct{})
+	done := make(chan error)
+	go func() {
+		done <- c.Watch([]string{inputDir}, outputDir, true, stop)
+	}()
+
+	sheetFile := filepath.Join(outputDir, "sheet.json")
+	nextSheetFile := filepath.Join(outputDir, "nextSheet.json")
+	waitForFile(t, sheetFile, func(bs []byte) bool { return len(bs) > 0 })
+	initial, err := os.Stat(sheetFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// lock files are ignored, and a new workbook is converted without writing the others
+	ioutil.WriteFile(filepath.Join(inputDir, "~$a.xlsx"), []byte("lock"), 0644)
+	copyTestFile(t, filepath.Join(excels, "convert_test2.xlsx"), filepath.Join(inputDir, "b.xlsx"))
+	waitForFile(t, nextSheetFile, func(bs []byte) bool {
+		l := SheetDataList{}
+		return json.Unmarshal(bs, &l) == nil && len(l) > 0
+	})
+	if fi, err := os.Stat(sheetFile); err != nil || !fi.ModTime().Equal(initial.ModTime()) {
+		t.Errorf("output of unchanged workbook should not be written again")
+	}
+
+	// a removed workbook is dropped, and a changed workbook is converted again
+	os.Remove(nextSheetFile)
+	os.Remove(filepath.Join(inputDir, "b.xlsx"))
+	copyTestFile(t, filepath.Join(excels, "convert_test2.xlsx"), filepath.Join(inputDir, "a.xlsx"))
+	waitForFile(t, nextSheetFile, func(bs []byte) bool {
+		l := SheetDataList{}
+		return json.Unmarshal(bs, &l) == nil && len(l) == 4
+	})
+
+	close(stop)
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestReconvertKeepsWorkbookOfBrokenFile(t *testing.T) {
+	dir, _ := os.Getwd()
+	excels := path.Join(dir, "..", "test", "excels")
+
+	inputDir, err := ioutil.TempDir("", "cxtj")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(inputDir)
+	outputDir, err := ioutil.TempDir("", "cxtj")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(outputDir)
+
+	inputFile := filepath.Join(inputDir, "a.xlsx")
+	copyTestFile(t, filepath.Join(excels, "convert_test.xlsx"), inputFile)
+
+	c, err := NewConverter(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	out, err := c.newOutputTarget(filepath.Join(outputDir, "output.json"), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	converted := map[string]workbook{}
+	if err := c.reconvert(out, []string{inputDir}, converted, map[string]bool{inputFile: true}); err != nil {
+		t.Fatal(err)
+	}
+	expect, err := ioutil.ReadFile(filepath.Join(outputDir, "output.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// a half-saved workbook is not a valid xlsx
+	if err := ioutil.WriteFile(inputFile, []byte("garbage"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.reconvert(out, []string{inputDir}, converted, map[string]bool{inputFile: true}); err != nil {
+		t.Fatal(err)
+	}
+	if len(converted[inputFile].sheets["sheet"]) != 4 {
+		t.Errorf("previous workbook should be kept. actual %v", converted[inputFile].sheets)
+	}
+	actual, err := ioutil.ReadFile(filepath.Join(outputDir, "output.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(actual) != string(expect) {
+		t.Errorf("output should be the previous workbook. expect %s, actual %s", expect, actual)
+	}
+}
@@ -0,0 +1,122 @@
+package cxtj
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"runtime"
+	"strings"
+	"sync"
+)
+
+// WorkerOptions configures concurrency workers
+type WorkerOptions struct {
+	// Jobs is the number of workers. The number of cpu is used when it is 0 or less.
+	Jobs int
+	// KeepGoing processes all of targets even if some of them fail, and all failures are returned.
+	// Otherwise the remaining targets are canceled on the first failure.
+	KeepGoing bool
+}
+
+func (o WorkerOptions) jobs(size int) int {
+	jobs := o.Jobs
+	if jobs <= 0 {
+		jobs = runtime.NumCPU()
+	}
+	if jobs > size {
+		jobs = size
+	}
+	return jobs
+}
+
+// WorkerResult is a result of proc function for one of the targets.
+// Data is the value returned by proc such as workbook or workbookHeader.
+type WorkerResult struct {
+	Index  int
+	Target string
+	Data   interface{}
+	Err    error
+}
+
+// WorkerErrors is failures of targets in order of targets, which is returned in keep going mode
+type WorkerErrors []error
+
+func (e WorkerErrors) Error() string {
+	list := make([]string, len(e))
+	for i, err := range e {
+		list[i] = err.Error()
+	}
+	return fmt.Sprintf("%d target(s) failed: %s", len(e), strings.Join(list, "; "))
+}
+
+// DispatchConcurrencyWorkers launches workers to execute proc function with targets.
+// proc can return any type of result, so that every per-target job runs through the same workers.
+// The results are returned in order of targets regardless of the finished order of workers,
+// and Err of each result is the error of the target. Targets which are not processed by cancellation
+// have the error of the context.
+// The returned error is the error of the earliest failed target, or WorkerErrors in keep going mode.
+// It is the error of ctx when ctx is done before all targets are processed.
+func DispatchConcurrencyWorkers(ctx context.Context, targets []string, opts WorkerOptions, proc func(context.Context, string) (interface{}, error)) ([]WorkerResult, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	size := len(targets)
+	indices := make(chan int, size)
+	for i := range targets {
+		indices <- i
+	}
+	close(indices)
+
+	results := make([]WorkerResult, size)
+	var failed sync.Once
+	var wg sync.WaitGroup
+	for i := 0; i < opts.jobs(size); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for index := range indices {
+				result := WorkerResult{Index: index, Target: targets[index]}
+				if err := ctx.Err(); err != nil {
+					result.Err = err
+				} else {
+					result.Data, result.Err = proc(ctx, targets[index])
+				}
+				results[index] = result
+
+				if result.Err != nil && !opts.KeepGoing {
+					failed.Do(cancel)
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	return results, mergeWorkerErrors(results, opts.KeepGoing)
+}
+
+// mergeWorkerErrors returns the error of the earliest failed target except cancellation by the other failure,
+// or all errors in keep going mode. Errors which wrap the cancellation are treated as cancellation as well.
+func mergeWorkerErrors(results []WorkerResult, keepGoing bool) error {
+	errs := WorkerErrors{}
+	var canceled error
+	for _, result := range results {
+		if result.Err == nil {
+			continue
+		}
+		if errors.Is(result.Err, context.Canceled) || errors.Is(result.Err, context.DeadlineExceeded) {
+			if canceled == nil {
+				canceled = result.Err
+			}
+			continue
+		}
+		if !keepGoing {
+			return result.Err
+		}
+		errs = append(errs, result.Err)
+	}
+
+	if len(errs) > 0 {
+		return errs
+	}
+	return canceled
+}
@@ -0,0 +1,115 @@
+package cxtj
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestDispatchConcurrencyWorkersInOrderOfTargets(t *testing.T) {
+	targets := []string{"a", "b", "c", "d"}
+	results, err := DispatchConcurrencyWorkers(context.Background(), targets, WorkerOptions{}, func(ctx context.Context, target string) (interface{}, error) {
+		return strings.ToUpper(target), nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, result := range results {
+		if result.Index != i || result.Target != targets[i] {
+			t.Errorf("result %d is not in order of targets: %v", i, result)
+		}
+		if result.Data != strings.ToUpper(targets[i]) {
+			t.Errorf("invalid data of %s: %v", targets[i], result.Data)
+		}
+	}
+}
+
+func TestDispatchConcurrencyWorkersCancelsOnFailure(t *testing.T) {
+	targets := []string{"a", "b", "c", "d"}
+	results, err := DispatchConcurrencyWorkers(context.Background(), targets, WorkerOptions{Jobs: 1}, func(ctx context.Context, target string) (interface{}, error) {
+		if target == "b" {
+			return nil, fmt.Errorf("failed %s", target)
+		}
+		return XlsxMap{}, nil
+	})
+	if err == nil || err.Error() != "failed b" {
+		t.Fatalf("the error of the failed target should be returned: %v", err)
+	}
+	if results[0].Err != nil {
+		t.Errorf("target a should succeed: %v", results[0].Err)
+	}
+	for _, result := range results[2:] {
+		if result.Err != context.Canceled {
+			t.Errorf("target %s should be canceled: %v", result.Target, result.Err)
+		}
+	}
+}
+
+func TestDispatchConcurrencyWorkersSkipsWrappedCancellation(t *testing.T) {
+	targets := []string{"a", "b"}
+	_, err := DispatchConcurrencyWorkers(context.Background(), targets, WorkerOptions{Jobs: 2}, func(ctx context.Context, target string) (interface{}, error) {
+		if target == "b" {
+			return nil, fmt.Errorf("failed %s", target)
+		}
+		<-ctx.Done()
+		return nil, &ConvertError{File: target, Err: ctx.Err()}
+	})
+	if err == nil || err.Error() != "failed b" {
+		t.Errorf("the error of the failed target should be returned instead of the wrapped cancellation: %v", err)
+	}
+}
+
+func TestDispatchConcurrencyWorkersKeepGoing(t *testing.T) {
+	targets := []string{"a", "b", "c", "d"}
+	_, err := DispatchConcurrencyWorkers(context.Background(), targets, WorkerOptions{Jobs: 1, KeepGoing: true}, func(ctx context.Context, target string) (interface{}, error) {
+		if target == "b" || target == "d" {
+			return nil, fmt.Errorf("failed %s", target)
+		}
+		return XlsxMap{}, nil
+	})
+	errs, ok := err.(WorkerErrors)
+	if !ok || len(errs) != 2 || errs[0].Error() != "failed b" || errs[1].Error() != "failed d" {
+		t.Errorf("all failures should be returned: %v", err)
+	}
+}
+
+func TestDispatchConcurrencyWorkersLimitsJobs(t *testing.T) {
+	var mu sync.Mutex
+	running, maxRunning := 0, 0
+	targets := []string{"a", "b", "c", "d", "e", "f"}
+	_, err := DispatchConcurrencyWorkers(context.Background(), targets, WorkerOptions{Jobs: 2}, func(ctx context.Context, target string) (interface{}, error) {
+		mu.Lock()
+		running++
+		if running > maxRunning {
+			maxRunning = running
+		}
+		mu.Unlock()
+
+		time.Sleep(10 * time.Millisecond)
+
+		mu.Lock()
+		running--
+		mu.Unlock()
+		return XlsxMap{}, nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if maxRunning > 2 {
+		t.Errorf("%d workers run at the same time with 2 jobs", maxRunning)
+	}
+}
+
+func TestDispatchConcurrencyWorkersWithCanceledContext(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err := DispatchConcurrencyWorkers(ctx, []string{"a"}, WorkerOptions{}, func(ctx context.Context, target string) (interface{}, error) {
+		return XlsxMap{}, nil
+	})
+	if err != context.Canceled {
+		t.Errorf("the error of the context should be returned: %v", err)
+	}
+}
@@ -0,0 +1,452 @@
+package cxtj
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"path"
+	"strconv"
+	"strings"
+
+	"github.com/tealeg/xlsx"
+)
+
+const (
+	xlsxWorkbookPath      = "xl/workbook.xml"
+	xlsxWorkbookRelsPath  = "xl/_rels/workbook.xml.rels"
+	xlsxSharedStringsPath = "xl/sharedStrings.xml"
+	xlsxStylesPath        = "xl/styles.xml"
+)
+
+// streamBook reads sheets of a xlsx file row by row from the xml in the zip,
+// so that rows of a sheet are not loaded on memory at once. Only shared strings and styles are loaded on memory.
+// Cells have the value and the number format like tealeg/xlsx, but dates of 1904 date system are not supported.
+type streamBook struct {
+	file string
+	zip  *zip.ReadCloser
+
+	// sheetNames is names of sheets in order of the workbook
+	sheetNames []string
+	// sheetPaths is the path of the sheet xml in the zip of each sheet name
+	sheetPaths map[string]string
+
+	// sharedStrings is loaded by the first read of rows
+	sharedStrings []string
+	// numberFormats is the number format of each cell style
+	numberFormats []string
+}
+
+type xlsxStreamWorkbook struct {
+	Sheets []struct {
+		Name string `xml:"name,attr"`
+		ID   string `xml:"http://schemas.openxmlformats.org/officeDocument/2006/relationships id,attr"`
+	} `xml:"sheets>sheet"`
+}
+
+type xlsxStreamStyles struct {
+	CellXfs []struct{} `xml:"cellXfs>xf"`
+}
+
+type xlsxStreamRelationships struct {
+	Relationships []struct {
+		ID     string `xml:"Id,attr"`
+		Target string `xml:"Target,attr"`
+	} `xml:"Relationship"`
+}
+
+// openStreamBook opens the xlsx file and reads the list of sheets.
+// A file which cannot be read or is not a valid xlsx is a ConvertError.
+func openStreamBook(filename string) (*streamBook, error) {
+	r, err := zip.OpenReader(filename)
+	if err != nil {
+		return nil, &ConvertError{File: filename, Err: err}
+	}
+	book := &streamBook{file: filename, zip: r, sheetPaths: map[string]string{}}
+	if err := book.readWorkbook(); err != nil {
+		r.Close()
+		return nil, &ConvertError{File: filename, Err: err}
+	}
+	if err := book.readStyles(); err != nil {
+		r.Close()
+		return nil, &ConvertError{File: filename, Err: err}
+	}
+	return book, nil
+}
+
+// Close closes the zip of the xlsx file
+func (b *streamBook) Close() error {
+	return b.zip.Close()
+}
+
+func (b *streamBook) decodeFile(name string, v interface{}) error {
+	f, err := b.openFile(name)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return xml.NewDecoder(f).Decode(v)
+}
+
+func (b *streamBook) hasFile(name string) bool {
+	for _, f := range b.zip.File {
+		if f.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+func (b *streamBook) readFile(name string) ([]byte, error) {
+	f, err := b.openFile(name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return ioutil.ReadAll(f)
+}
+
+func (b *streamBook) openFile(name string) (io.ReadCloser, error) {
+	for _, f := range b.zip.File {
+		if f.Name == name {
+			return f.Open()
+		}
+	}
+	return nil, fmt.Errorf("%s is not found in %s", name, b.file)
+}
+
+// readWorkbook reads sheet names and paths of sheet xml from the workbook and its relationships
+func (b *streamBook) readWorkbook() error {
+	var wb xlsxStreamWorkbook
+	if err := b.decodeFile(xlsxWorkbookPath, &wb); err != nil {
+		return err
+	}
+	var rels xlsxStreamRelationships
+	if err := b.decodeFile(xlsxWorkbookRelsPath, &rels); err != nil {
+		return err
+	}
+
+	targets := map[string]string{}
+	for _, rel := range rels.Relationships {
+		target := path.Join(path.Dir(xlsxWorkbookPath), rel.Target)
+		if strings.HasPrefix(rel.Target, "/") {
+			target = strings.TrimPrefix(rel.Target, "/")
+		}
+		targets[rel.ID] = target
+	}
+
+	for _, sheet := range wb.Sheets {
+		target, ok := targets[sheet.ID]
+		if !ok {
+			return fmt.Errorf("sheet %s is not found in %s", sheet.Name, b.file)
+		}
+		b.sheetNames = append(b.sheetNames, sheet.Name)
+		b.sheetPaths[sheet.Name] = target
+	}
+	return nil
+}
+
+// readStyles reads the number format of each cell style by tealeg/xlsx, so that cells are formatted in the same way.
+// tealeg/xlsx resolves number formats only for cells of sheets, so a workbook which has the styles
+// and a cell of each style is read instead of the whole xlsx file.
+func (b *streamBook) readStyles() error {
+	if !b.hasFile(xlsxStylesPath) {
+		return nil
+	}
+	var styles xlsxStreamStyles
+	if err := b.decodeFile(xlsxStylesPath, &styles); err != nil {
+		return err
+	}
+	if len(styles.CellXfs) == 0 {
+		return nil
+	}
+	stylesXML, err := b.readFile(xlsxStylesPath)
+	if err != nil {
+		return err
+	}
+
+	var sheet bytes.Buffer
+	sheet.WriteString(`<?xml version="1.0" encoding="UTF-8"?>
+<worksheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main"><sheetData><row r="1">`)
+	for i := range styles.CellXfs {
+		fmt.Fprintf(&sheet, `<c r="%s" s="%d"><v>0</v></c>`, xlsx.GetCellIDStringFromCoords(i, 0), i)
+	}
+	sheet.WriteString(`</row></sheetData></worksheet>`)
+
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for _, f := range []struct {
+		name    string
+		content []byte
+	}{
+		{xlsxWorkbookPath, []byte(`<?xml version="1.0" encoding="UTF-8"?>
+<workbook xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main" xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships">
+<sheets><sheet name="styles" sheetId="1" r:id="rId1"/></sheets></workbook>`)},
+		{xlsxWorkbookRelsPath, []byte(`<?xml version="1.0" encoding="UTF-8"?>
+<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">
+<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/worksheet" Target="worksheets/sheet1.xml"/>
+</Relationships>`)},
+		{xlsxStylesPath, stylesXML},
+		{"xl/worksheets/sheet1.xml", sheet.Bytes()},
+	} {
+		w, err := zw.Create(f.name)
+		if err != nil {
+			return err
+		}
+		if _, err := w.Write(f.content); err != nil {
+			return err
+		}
+	}
+	if err := zw.Close(); err != nil {
+		return err
+	}
+
+	xFile, err := xlsx.OpenBinary(buf.Bytes())
+	if err != nil {
+		return err
+	}
+	b.numberFormats = make([]string, len(styles.CellXfs))
+	if len(xFile.Sheets) == 0 || len(xFile.Sheets[0].Rows) == 0 {
+		return nil
+	}
+	for i, cell := range xFile.Sheets[0].Rows[0].Cells {
+		if i < len(b.numberFormats) {
+			b.numberFormats[i] = cell.NumFmt
+		}
+	}
+	return nil
+}
+
+// loadSharedStrings reads shared strings in the same way as tealeg/xlsx.
+// A rich text is the concatenation of its runs, and phonetic runs are ignored.
+func (b *streamBook) loadSharedStrings() error {
+	if b.sharedStrings != nil || !b.hasFile(xlsxSharedStringsPath) {
+		return nil
+	}
+	f, err := b.openFile(xlsxSharedStringsPath)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	ret := []string{}
+	var text, runs strings.Builder
+	hasRuns := false
+	var stack []string
+	dec := xml.NewDecoder(f)
+	for {
+		token, err := dec.RawToken()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+
+		switch t := token.(type) {
+		case xml.StartElement:
+			stack = append(stack, t.Name.Local)
+			if t.Name.Local == "si" {
+				text.Reset()
+				runs.Reset()
+				hasRuns = false
+			} else if t.Name.Local == "r" && len(stack) == 3 && stack[1] == "si" {
+				hasRuns = true
+			}
+		case xml.EndElement:
+			stack = stack[:len(stack)-1]
+			if t.Name.Local == "si" {
+				if hasRuns {
+					ret = append(ret, runs.String())
+				} else {
+					ret = append(ret, text.String())
+				}
+			}
+		case xml.CharData:
+			switch {
+			case len(stack) == 3 && stack[1] == "si" && stack[2] == "t":
+				text.Write(t)
+			case len(stack) == 4 && stack[1] == "si" && stack[2] == "r" && stack[3] == "t":
+				runs.Write(t)
+			}
+		}
+	}
+	b.sharedStrings = ret
+	return nil
+}
+
+// readRows calls fn with the 1-based row line and cells of each row of the sheet in order of rows.
+// Missing cells are empty, and rows which are not stored in the xml are not passed.
+// cells is reused for the next row, so fn should copy it to keep.
+func (b *streamBook) readRows(sheetName string, fn func(line int, cells []xlsx.Cell) error) error {
+	sheetPath, ok := b.sheetPaths[sheetName]
+	if !ok {
+		return fmt.Errorf("sheet %s is not found in %s", sheetName, b.file)
+	}
+	if err := b.loadSharedStrings(); err != nil {
+		return err
+	}
+	f, err := b.openFile(sheetPath)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	r := xlsxRowReader{sharedStrings: b.sharedStrings, numberFormats: b.numberFormats}
+	dec := xml.NewDecoder(f)
+	for {
+		token, err := dec.RawToken()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		done, err := r.read(token)
+		if err != nil {
+			return &ConvertError{Sheet: sheetName, Row: r.line, Err: err}
+		}
+		if done {
+			if err := fn(r.line, r.cells); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// xlsxRowReader builds rows from tokens of the sheet xml.
+// The width of a row is its spans attribute or the last stored cell like tealeg/xlsx.
+type xlsxRowReader struct {
+	sharedStrings []string
+	numberFormats []string
+
+	line  int
+	width int
+	cells []xlsx.Cell
+
+	// column, cellType, style and value are of the current cell
+	column   int
+	cellType string
+	style    int
+	value    strings.Builder
+	// inValue is true in the v element, or the t element of an inline string except phonetic runs
+	inValue    bool
+	inPhonetic bool
+}
+
+// read processes the token and reports whether a row is completed
+func (r *xlsxRowReader) read(token xml.Token) (bool, error) {
+	switch t := token.(type) {
+	case xml.StartElement:
+		switch t.Name.Local {
+		case "row":
+			return false, r.startRow(t)
+		case "c":
+			return false, r.startCell(t)
+		case "v":
+			r.inValue = true
+		case "t":
+			r.inValue = r.cellType == "inlineStr" && !r.inPhonetic
+		case "rPh":
+			r.inPhonetic = true
+		}
+	case xml.EndElement:
+		switch t.Name.Local {
+		case "row":
+			if r.width > 0 {
+				for len(r.cells) < r.width {
+					r.cells = append(r.cells, xlsx.Cell{})
+				}
+				r.cells = r.cells[:r.width]
+			}
+			return true, nil
+		case "c":
+			return false, r.endCell()
+		case "v", "t":
+			r.inValue = false
+		case "rPh":
+			r.inPhonetic = false
+		}
+	case xml.CharData:
+		if r.inValue {
+			r.value.Write(t)
+		}
+	}
+	return false, nil
+}
+
+func (r *xlsxRowReader) startRow(t xml.StartElement) error {
+	line := r.line + 1
+	r.width = 0
+	r.column = -1
+	r.cells = r.cells[:0]
+	for _, attr := range t.Attr {
+		switch attr.Name.Local {
+		case "r":
+			n, err := strconv.Atoi(attr.Value)
+			if err != nil {
+				return fmt.Errorf("invalid row number %q", attr.Value)
+			}
+			line = n
+		case "spans":
+			if strings.Count(attr.Value, ":") == 1 {
+				if n, err := strconv.Atoi(attr.Value[strings.Index(attr.Value, ":")+1:]); err == nil {
+					r.width = n
+				}
+			}
+		}
+	}
+	r.line = line
+	return nil
+}
+
+func (r *xlsxRowReader) startCell(t xml.StartElement) error {
+	r.column++
+	r.cellType = ""
+	r.style = 0
+	r.value.Reset()
+	for _, attr := range t.Attr {
+		switch attr.Name.Local {
+		case "r":
+			x, _, err := xlsx.GetCoordsFromCellIDString(attr.Value)
+			if err != nil {
+				return fmt.Errorf("invalid cell %q", attr.Value)
+			}
+			r.column = x
+		case "t":
+			r.cellType = attr.Value
+		case "s":
+			style, err := strconv.Atoi(attr.Value)
+			if err != nil {
+				return fmt.Errorf("invalid cell style %q", attr.Value)
+			}
+			r.style = style
+		}
+	}
+	return nil
+}
+
+func (r *xlsxRowReader) endCell() error {
+	value := r.value.String()
+	if r.cellType != "inlineStr" {
+		value = strings.Trim(value, " \t\n\r")
+	}
+	if r.cellType == "s" && value != "" {
+		index, err := strconv.Atoi(value)
+		if err != nil || index < 0 || index >= len(r.sharedStrings) {
+			return fmt.Errorf("invalid shared string index %q", value)
+		}
+		value = r.sharedStrings[index]
+	}
+
+	for len(r.cells) <= r.column {
+		r.cells = append(r.cells, xlsx.Cell{})
+	}
+	r.cells[r.column] = xlsx.Cell{Value: value}
+	if r.style >= 0 && r.style < len(r.numberFormats) {
+		r.cells[r.column].NumFmt = r.numberFormats[r.style]
+	}
+	return nil
+}
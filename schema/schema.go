@@ -0,0 +1,228 @@
+// Package schema generates JSON Schema of sheets from the header of xlsx files,
+// and validates converted json by them.
+package schema
+
+import (
+	"encoding/json"
+	"math"
+	"sort"
+
+	"github.com/kama2vern/cxtj/config"
+	"github.com/kama2vern/cxtj/cxtj"
+)
+
+// Draft is the JSON Schema version of generated documents
+const Draft = "http://json-schema.org/draft-07/schema#"
+
+// JSON Schema types
+const (
+	TypeObject  = "object"
+	TypeArray   = "array"
+	TypeString  = "string"
+	TypeInteger = "integer"
+	TypeNumber  = "number"
+	TypeBoolean = "boolean"
+	TypeNull    = "null"
+)
+
+// Schema is a subset of JSON Schema which is enough to describe converted sheets
+type Schema struct {
+	Schema               string             `json:"$schema,omitempty"`
+	Title                string             `json:"title,omitempty"`
+	Description          string             `json:"description,omitempty"`
+	Type                 TypeList           `json:"type,omitempty"`
+	Enum                 []interface{}      `json:"enum,omitempty"`
+	Minimum              *int64             `json:"minimum,omitempty"`
+	Maximum              *int64             `json:"maximum,omitempty"`
+	AnyOf                []*Schema          `json:"anyOf,omitempty"`
+	Items                *Schema            `json:"items,omitempty"`
+	MaxItems             *int               `json:"maxItems,omitempty"`
+	Properties           map[string]*Schema `json:"properties,omitempty"`
+	Required             []string           `json:"required,omitempty"`
+	PatternProperties    map[string]*Schema `json:"patternProperties,omitempty"`
+	AdditionalProperties *bool              `json:"additionalProperties,omitempty"`
+}
+
+// anyPropertyPattern is the pattern of patternProperties which matches all keys
+const anyPropertyPattern = "^.*$"
+
+// TypeList is the type keyword which is a string or an array of strings
+type TypeList []string
+
+// MarshalJSON writes a string if the list has only one type
+func (l TypeList) MarshalJSON() ([]byte, error) {
+	if len(l) == 1 {
+		return json.Marshal(l[0])
+	}
+	return json.Marshal([]string(l))
+}
+
+// UnmarshalJSON reads a string or an array of strings
+func (l *TypeList) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err == nil {
+		*l = TypeList{s}
+		return nil
+	}
+	var ss []string
+	if err := json.Unmarshal(data, &ss); err != nil {
+		return err
+	}
+	*l = TypeList(ss)
+	return nil
+}
+
+// FromSheetColumns creates the schema of a sheet, which is an array of rows.
+// Types of columns are decided by the value types and the empty cell config
+// because empty cells are output as null, "" or the zero value, or omitted.
+func FromSheetColumns(sheetName string, columns cxtj.SheetColumns, emptyCell config.EmptyCell) *Schema {
+	return &Schema{
+		Schema: Draft,
+		Title:  sheetName,
+		Type:   TypeList{TypeArray},
+		Items:  objectSchema(columns, emptyCell),
+	}
+}
+
+// FromKeyedSheetColumns creates the schema of a sheet, which is an object of rows keyed by the primary key
+func FromKeyedSheetColumns(sheetName string, columns cxtj.SheetColumns, emptyCell config.EmptyCell) *Schema {
+	return &Schema{
+		Schema:            Draft,
+		Title:             sheetName,
+		Type:              TypeList{TypeObject},
+		PatternProperties: map[string]*Schema{anyPropertyPattern: objectSchema(columns, emptyCell)},
+	}
+}
+
+// FromHeader creates the schema of each sheet
+func FromHeader(header cxtj.XlsxHeaderMap, emptyCell config.EmptyCell) map[string]*Schema {
+	ret := make(map[string]*Schema, len(header))
+	for sheetName, columns := range header {
+		ret[sheetName] = FromSheetColumns(sheetName, columns, emptyCell)
+	}
+	return ret
+}
+
+// FromHeaderByOutput creates the schema of each sheet by the empty cell and the row shape of the output config.
+// Sheets which have a primary key are keyed objects if the row shape is keyed.
+func FromHeaderByOutput(header cxtj.XlsxHeaderMap, output config.OutputConfig) map[string]*Schema {
+	ret := make(map[string]*Schema, len(header))
+	for sheetName, columns := range header {
+		if output.RowShape == config.RowShapeKeyed && hasPrimaryKey(columns) {
+			ret[sheetName] = FromKeyedSheetColumns(sheetName, columns, output.EmptyCell)
+		} else {
+			ret[sheetName] = FromSheetColumns(sheetName, columns, output.EmptyCell)
+		}
+	}
+	return ret
+}
+
+// hasPrimaryKey reports whether one of columns including nested columns is the primary key
+func hasPrimaryKey(columns cxtj.SheetColumns) bool {
+	for _, info := range columns {
+		if info.PrimaryKey || hasPrimaryKey(info.Fields) {
+			return true
+		}
+	}
+	return false
+}
+
+func objectSchema(columns cxtj.SheetColumns, emptyCell config.EmptyCell) *Schema {
+	additionalProperties := false
+	ret := &Schema{
+		Type:                 TypeList{TypeObject},
+		Properties:           make(map[string]*Schema, len(columns)),
+		Required:             []string{},
+		AdditionalProperties: &additionalProperties,
+	}
+	for _, name := range sortedColumnNames(columns) {
+		ret.Properties[name] = columnSchema(columns[name], emptyCell)
+		if emptyCell != config.EmptyCellOmit || columns[name].PrimaryKey || hasPrimaryKey(columns[name].Fields) {
+			ret.Required = append(ret.Required, name)
+		}
+	}
+	return ret
+}
+
+func columnSchema(info cxtj.ColumnInfo, emptyCell config.EmptyCell) *Schema {
+	switch {
+	case info.Fields != nil:
+		ret := objectSchema(info.Fields, emptyCell)
+		ret.Description = info.Comment
+		return ret
+	case info.Items != nil:
+		// appended sheets may have shorter arrays, so only the maximum is limited
+		length := info.Length
+		items := columnSchema(*info.Items, emptyCell)
+		if emptyCell == config.EmptyCellOmit {
+			// an element is null when the cell or all of the cells are omitted
+			items.Type = append(items.Type, TypeNull)
+		}
+		return &Schema{
+			Description: info.Comment,
+			Type:        TypeList{TypeArray},
+			Items:       items,
+			MaxItems:    &length,
+		}
+	}
+
+	ret := valueTypeSchema(info.ValueType)
+	if info.PrimaryKey {
+		// primary keys are never empty
+		ret.Description = info.Comment
+		return ret
+	}
+	isString := ret.Type[0] == TypeString
+	isArray := ret.Type[0] == TypeArray
+
+	switch emptyCell {
+	case config.EmptyCellDefault:
+		// empty cells of array value types are empty arrays
+		if !isString && !isArray {
+			ret.Type = append(ret.Type, TypeNull)
+		}
+	case config.EmptyCellNull:
+		ret.Type = append(ret.Type, TypeNull)
+	case config.EmptyCellEmptyString:
+		if !isString {
+			ret = &Schema{AnyOf: []*Schema{ret, {Enum: []interface{}{""}}}}
+		}
+	}
+	ret.Description = info.Comment
+	return ret
+}
+
+func valueTypeSchema(valueType string) *Schema {
+	if elementType, _, ok := cxtj.ParseArrayValueType(valueType); ok {
+		return &Schema{Type: TypeList{TypeArray}, Items: valueTypeSchema(elementType)}
+	}
+	if _, cellValueType, ok := cxtj.ParseReferenceValueType(valueType); ok {
+		return valueTypeSchema(cellValueType)
+	}
+
+	switch valueType {
+	case cxtj.ValueTypeInt:
+		min, max := int64(math.MinInt32), int64(math.MaxInt32)
+		return &Schema{Type: TypeList{TypeInteger}, Minimum: &min, Maximum: &max}
+	case cxtj.ValueTypeLong:
+		return &Schema{Type: TypeList{TypeInteger}}
+	case cxtj.ValueTypeFloat, cxtj.ValueTypeDouble:
+		return &Schema{Type: TypeList{TypeNumber}}
+	case cxtj.ValueTypeBool:
+		return &Schema{Type: TypeList{TypeBoolean}}
+	}
+	// unknown value types are output as string
+	return &Schema{Type: TypeList{TypeString}}
+}
+
+// sortedColumnNames returns column names in order of columns
+func sortedColumnNames(columns cxtj.SheetColumns) []string {
+	ret := make([]string, 0, len(columns))
+	for name := range columns {
+		ret = append(ret, name)
+	}
+	sort.Slice(ret, func(i, j int) bool {
+		return columns[ret[i]].Index < columns[ret[j]].Index
+	})
+	return ret
+}
@@ -0,0 +1,82 @@
+package schema
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	"github.com/kama2vern/cxtj/config"
+	"github.com/kama2vern/cxtj/cxtj"
+)
+
+func TestFromSheetColumns(t *testing.T) {
+	columns := cxtj.SheetColumns{
+		"id":   {Index: 0, ValueType: "int", Comment: "ID"},
+		"name": {Index: 1, ValueType: "string"},
+		"rate": {Index: 2, ValueType: "double"},
+	}
+
+	bs, err := json.Marshal(FromSheetColumns("sheet", columns, config.EmptyCellDefault))
+	if err != nil {
+		t.Fatal(err)
+	}
+	except := `{"$schema":"http://json-schema.org/draft-07/schema#","title":"sheet","type":"array","items":{"type":"object",` +
+		`"properties":{"id":{"description":"ID","type":["integer","null"],"minimum":-2147483648,"maximum":2147483647},` +
+		`"name":{"type":"string"},"rate":{"type":["number","null"]}},"required":["id","name","rate"],"additionalProperties":false}}`
+	if string(bs) != except {
+		t.Errorf("Mismatch schema. except %s, actual %s", except, string(bs))
+	}
+}
+
+func TestFromSheetColumnsByEmptyCell(t *testing.T) {
+	columns := cxtj.SheetColumns{
+		"id":   {Index: 0, ValueType: "int"},
+		"name": {Index: 1, ValueType: "string"},
+	}
+
+	s := FromSheetColumns("sheet", columns, config.EmptyCellZero)
+	if len(s.Items.Properties["id"].Type) != 1 || len(s.Items.Properties["name"].Type) != 1 {
+		t.Errorf("columns should not be nullable by zero. actual %v, %v", s.Items.Properties["id"].Type, s.Items.Properties["name"].Type)
+	}
+
+	s = FromSheetColumns("sheet", columns, config.EmptyCellNull)
+	if len(s.Items.Properties["name"].Type) != 2 {
+		t.Errorf("string column should be nullable by null. actual %v", s.Items.Properties["name"].Type)
+	}
+
+	s = FromSheetColumns("sheet", columns, config.EmptyCellOmit)
+	if len(s.Items.Required) != 0 {
+		t.Errorf("columns should not be required by omit. actual %v", s.Items.Required)
+	}
+}
+
+func TestTypeListUnmarshal(t *testing.T) {
+	s := &Schema{}
+	if err := json.Unmarshal([]byte(`{"type":"integer","items":{"type":["string","null"]}}`), s); err != nil {
+		t.Fatal(err)
+	}
+	if len(s.Type) != 1 || s.Type[0] != TypeInteger || len(s.Items.Type) != 2 {
+		t.Errorf("invalid types. actual %v, %v", s.Type, s.Items.Type)
+	}
+}
+
+func TestFromSheetColumnsArrayValueType(t *testing.T) {
+	columns := cxtj.SheetColumns{
+		"tags": {Index: 0, ValueType: "string[]"},
+		"ids":  {Index: 1, ValueType: "int[|]"},
+	}
+
+	s := FromSheetColumns("sheet", columns, config.EmptyCellDefault)
+	ids := s.Items.Properties["ids"]
+	if !reflect.DeepEqual(ids.Type, TypeList{TypeArray}) || ids.Items == nil || !reflect.DeepEqual(ids.Items.Type, TypeList{TypeInteger}) || ids.Items.Maximum == nil {
+		t.Errorf("int[] should be an array of integer. actual %+v", ids)
+	}
+	if tags := s.Items.Properties["tags"]; tags.Items == nil || !reflect.DeepEqual(tags.Items.Type, TypeList{TypeString}) {
+		t.Errorf("string[] should be an array of string. actual %+v", tags)
+	}
+
+	s = FromSheetColumns("sheet", columns, config.EmptyCellNull)
+	if !reflect.DeepEqual(s.Items.Properties["ids"].Type, TypeList{TypeArray, TypeNull}) {
+		t.Errorf("array column should be nullable by null. actual %v", s.Items.Properties["ids"].Type)
+	}
+}
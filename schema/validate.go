@@ -0,0 +1,272 @@
+package schema
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/big"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/kama2vern/cxtj/config"
+)
+
+// ValidationError is a value which does not match the schema
+type ValidationError struct {
+	// Path is JSON Pointer of the value like /sheet/0/id
+	Path    string
+	Message string
+}
+
+func (e ValidationError) Error() string {
+	path := e.Path
+	if path == "" {
+		path = "/"
+	}
+	return fmt.Sprintf("%s: %s", path, e.Message)
+}
+
+// Decode reads json keeping numbers as json.Number to check integers and ranges
+func Decode(r io.Reader) (interface{}, error) {
+	dec := json.NewDecoder(r)
+	dec.UseNumber()
+	var v interface{}
+	if err := dec.Decode(&v); err != nil {
+		return nil, err
+	}
+	return v, nil
+}
+
+// Validate checks the value decoded by Decode against the schema
+func (s *Schema) Validate(v interface{}) []ValidationError {
+	return s.validate("", v)
+}
+
+// ValidateOutput checks the value of converted json which has all sheets against the schema of each sheet.
+// The value is an object keyed by sheet name, or an array of objects which have name and rows by the output shape.
+func ValidateOutput(schemas map[string]*Schema, v interface{}, shape config.OutputShape) []ValidationError {
+	sheets := map[string]interface{}{}
+	paths := map[string]string{}
+
+	switch shape {
+	case config.OutputShapeArray:
+		l, ok := v.([]interface{})
+		if !ok {
+			return []ValidationError{{Message: fmt.Sprintf("%s should be array of sheets", describe(v))}}
+		}
+		for i, item := range l {
+			path := "/" + strconv.Itoa(i)
+			sheet, ok := item.(map[string]interface{})
+			if !ok {
+				return []ValidationError{{Path: path, Message: fmt.Sprintf("%s should be object which has name and rows", describe(item))}}
+			}
+			name, ok := sheet["name"].(string)
+			if !ok {
+				return []ValidationError{{Path: path + "/name", Message: "name of sheet should be string"}}
+			}
+			sheets[name] = sheet["rows"]
+			paths[name] = path + "/rows"
+		}
+	default:
+		m, ok := v.(map[string]interface{})
+		if !ok {
+			return []ValidationError{{Message: fmt.Sprintf("%s should be object keyed by sheet name", describe(v))}}
+		}
+		for name, rows := range m {
+			sheets[name] = rows
+			paths[name] = "/" + escapePointer(name)
+		}
+	}
+
+	names := make([]string, 0, len(schemas)+len(sheets))
+	for name := range schemas {
+		names = append(names, name)
+	}
+	for name := range sheets {
+		if _, ok := schemas[name]; !ok {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	errs := []ValidationError{}
+	for _, name := range names {
+		s, hasSchema := schemas[name]
+		rows, hasSheet := sheets[name]
+		switch {
+		case !hasSheet:
+			errs = append(errs, ValidationError{Message: fmt.Sprintf("sheet %s is missing", name)})
+		case !hasSchema:
+			errs = append(errs, ValidationError{Path: paths[name], Message: fmt.Sprintf("sheet %s is not in xlsx", name)})
+		default:
+			errs = append(errs, s.validate(paths[name], rows)...)
+		}
+	}
+	return errs
+}
+
+func (s *Schema) validate(path string, v interface{}) []ValidationError {
+	if len(s.AnyOf) > 0 {
+		for _, sub := range s.AnyOf {
+			if len(sub.validate(path, v)) == 0 {
+				return nil
+			}
+		}
+		return []ValidationError{{Path: path, Message: fmt.Sprintf("%s does not match any of schemas", describe(v))}}
+	}
+
+	if len(s.Type) > 0 && !s.matchType(v) {
+		return []ValidationError{{Path: path, Message: fmt.Sprintf("%s should be %s", describe(v), strings.Join(s.Type, " or "))}}
+	}
+	if len(s.Enum) > 0 && !s.matchEnum(v) {
+		return []ValidationError{{Path: path, Message: fmt.Sprintf("%s should be one of %v", describe(v), s.Enum)}}
+	}
+
+	errs := []ValidationError{}
+	switch value := v.(type) {
+	case json.Number:
+		errs = append(errs, s.validateRange(path, value)...)
+	case []interface{}:
+		if s.MaxItems != nil && len(value) > *s.MaxItems {
+			errs = append(errs, ValidationError{Path: path, Message: fmt.Sprintf("array should have %d or less items, but %d", *s.MaxItems, len(value))})
+		}
+		if s.Items != nil {
+			for i, item := range value {
+				errs = append(errs, s.Items.validate(path+"/"+strconv.Itoa(i), item)...)
+			}
+		}
+	case map[string]interface{}:
+		errs = append(errs, s.validateObject(path, value)...)
+	}
+	return errs
+}
+
+func (s *Schema) validateObject(path string, m map[string]interface{}) []ValidationError {
+	errs := []ValidationError{}
+	for _, name := range s.Required {
+		if _, ok := m[name]; !ok {
+			errs = append(errs, ValidationError{Path: path, Message: fmt.Sprintf("required property %q is missing", name)})
+		}
+	}
+
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		propertyPath := path + "/" + escapePointer(k)
+		if sub, ok := s.Properties[k]; ok {
+			errs = append(errs, sub.validate(propertyPath, m[k])...)
+		} else if sub, ok := s.patternProperty(k); ok {
+			errs = append(errs, sub.validate(propertyPath, m[k])...)
+		} else if s.AdditionalProperties != nil && !*s.AdditionalProperties {
+			errs = append(errs, ValidationError{Path: propertyPath, Message: "additional property is not allowed"})
+		}
+	}
+	return errs
+}
+
+// patternProperty returns the schema of patternProperties which matches the key in order of patterns
+func (s *Schema) patternProperty(k string) (*Schema, bool) {
+	patterns := make([]string, 0, len(s.PatternProperties))
+	for pattern := range s.PatternProperties {
+		patterns = append(patterns, pattern)
+	}
+	sort.Strings(patterns)
+	for _, pattern := range patterns {
+		if matched, err := regexp.MatchString(pattern, k); err == nil && matched {
+			return s.PatternProperties[pattern], true
+		}
+	}
+	return nil, false
+}
+
+func (s *Schema) validateRange(path string, n json.Number) []ValidationError {
+	if s.Minimum == nil && s.Maximum == nil {
+		return nil
+	}
+	value, ok := new(big.Float).SetString(n.String())
+	if !ok {
+		return []ValidationError{{Path: path, Message: fmt.Sprintf("invalid number %s", n)}}
+	}
+	if s.Minimum != nil && value.Cmp(new(big.Float).SetInt64(*s.Minimum)) < 0 {
+		return []ValidationError{{Path: path, Message: fmt.Sprintf("%s should be greater than or equal to %d", n, *s.Minimum)}}
+	}
+	if s.Maximum != nil && value.Cmp(new(big.Float).SetInt64(*s.Maximum)) > 0 {
+		return []ValidationError{{Path: path, Message: fmt.Sprintf("%s should be less than or equal to %d", n, *s.Maximum)}}
+	}
+	return nil
+}
+
+func (s *Schema) matchType(v interface{}) bool {
+	for _, t := range s.Type {
+		if matchType(t, v) {
+			return true
+		}
+	}
+	return false
+}
+
+func matchType(t string, v interface{}) bool {
+	switch value := v.(type) {
+	case nil:
+		return t == TypeNull
+	case bool:
+		return t == TypeBoolean
+	case string:
+		return t == TypeString
+	case []interface{}:
+		return t == TypeArray
+	case map[string]interface{}:
+		return t == TypeObject
+	case json.Number:
+		if t == TypeNumber {
+			return true
+		}
+		return t == TypeInteger && isInteger(value)
+	}
+	return false
+}
+
+func (s *Schema) matchEnum(v interface{}) bool {
+	for _, e := range s.Enum {
+		if reflect.DeepEqual(e, v) {
+			return true
+		}
+	}
+	return false
+}
+
+// isInteger reports whether the number has no fractional part like 1 and 1.0
+func isInteger(n json.Number) bool {
+	f, ok := new(big.Float).SetString(n.String())
+	return ok && f.IsInt()
+}
+
+func describe(v interface{}) string {
+	switch v.(type) {
+	case nil:
+		return "null"
+	case []interface{}:
+		return "array"
+	case map[string]interface{}:
+		return "object"
+	}
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(v); err != nil {
+		return fmt.Sprint(v)
+	}
+	return strings.TrimSpace(buf.String())
+}
+
+// escapePointer escapes a key as a reference token of JSON Pointer
+func escapePointer(k string) string {
+	return strings.NewReplacer("~", "~0", "/", "~1").Replace(k)
+}
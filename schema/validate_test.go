@@ -0,0 +1,164 @@
+package schema
+
+import (
+	"bytes"
+	"os"
+	"path"
+	"strings"
+	"testing"
+
+	"github.com/kama2vern/cxtj/config"
+	"github.com/kama2vern/cxtj/cxtj"
+)
+
+func decodeString(t *testing.T, s string) interface{} {
+	v, err := Decode(strings.NewReader(s))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return v
+}
+
+func TestValidate(t *testing.T) {
+	columns := cxtj.SheetColumns{
+		"id":   {Index: 0, ValueType: "int"},
+		"name": {Index: 1, ValueType: "string"},
+		"rate": {Index: 2, ValueType: "double"},
+	}
+	s := FromSheetColumns("sheet", columns, config.EmptyCellDefault)
+
+	valid := `[{"id":1,"name":"alpha","rate":1.5},{"id":2.0,"name":"","rate":null}]`
+	if errs := s.Validate(decodeString(t, valid)); len(errs) != 0 {
+		t.Errorf("valid json is rejected: %v", errs)
+	}
+
+	for json, except := range map[string]string{
+		`{"id":1}`:                                    "/: object should be array",
+		`[{"id":1.5,"name":"alpha","rate":1}]`:        "/0/id: 1.5 should be integer or null",
+		`[{"id":2147483648,"name":"alpha","rate":1}]`: "/0/id: 2147483648 should be less than or equal to 2147483647",
+		`[{"id":1,"name":null,"rate":1}]`:             "/0/name: null should be string",
+		`[{"id":1,"name":"alpha"}]`:                   `/0: required property "rate" is missing`,
+		`[{"id":1,"name":"alpha","rate":1,"hp":10}]`:  "/0/hp: additional property is not allowed",
+		`[{"id":1,"name":"alpha","rate":"1"}]`:        `/0/rate: "1" should be number or null`,
+	} {
+		errs := s.Validate(decodeString(t, json))
+		if len(errs) != 1 || errs[0].Error() != except {
+			t.Errorf("Mismatch errors of %s. except %s, actual %v", json, except, errs)
+		}
+	}
+}
+
+func TestValidateEmptyString(t *testing.T) {
+	columns := cxtj.SheetColumns{
+		"id": {Index: 0, ValueType: "int"},
+	}
+	s := FromSheetColumns("sheet", columns, config.EmptyCellEmptyString)
+
+	if errs := s.Validate(decodeString(t, `[{"id":1},{"id":""}]`)); len(errs) != 0 {
+		t.Errorf("empty string should be valid: %v", errs)
+	}
+	if errs := s.Validate(decodeString(t, `[{"id":"1"}]`)); len(errs) != 1 {
+		t.Errorf("non-empty string should be invalid: %v", errs)
+	}
+}
+
+func TestValidateOutput(t *testing.T) {
+	dir, _ := os.Getwd()
+	inputFiles := []string{path.Join(dir, "..", "test", "excels", "convert_test.xlsx")}
+
+	c, err := cxtj.NewConverter(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	header, err := c.ReadHeader(inputFiles)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f, err := os.Open(inputFiles[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	converted, err := c.ConvertReader(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	schemas := FromHeader(header, config.EmptyCellDefault)
+
+	for _, shape := range []config.OutputShape{config.OutputShapeObject, config.OutputShapeArray} {
+		var buf bytes.Buffer
+		enc, err := cxtj.GetEncoder(cxtj.FormatJSON, cxtj.EncoderOptions{Output: config.OutputConfig{Shape: shape}})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := enc.Encode(&buf, converted); err != nil {
+			t.Fatal(err)
+		}
+		if errs := ValidateOutput(schemas, decodeString(t, buf.String()), shape); len(errs) != 0 {
+			t.Errorf("converted json of %s shape is rejected: %v", shape, errs)
+		}
+	}
+
+	errs := ValidateOutput(schemas, decodeString(t, `{"other":[]}`), config.OutputShapeObject)
+	if len(errs) != 2 || errs[0].Error() != "/other: sheet other is not in xlsx" || errs[1].Error() != "/: sheet sheet is missing" {
+		t.Errorf("Mismatch errors. actual %v", errs)
+	}
+}
+
+func TestValidateNested(t *testing.T) {
+	columns := cxtj.SheetColumns{
+		"stats": {Index: 0, ValueType: "object", Fields: cxtj.SheetColumns{
+			"hp": {Index: 0, ValueType: "int"},
+		}},
+		"tags": {Index: 1, ValueType: "array", Length: 2, Items: &cxtj.ColumnInfo{Index: 1, ValueType: "string"}},
+	}
+	s := FromSheetColumns("sheet", columns, config.EmptyCellDefault)
+
+	if errs := s.Validate(decodeString(t, `[{"stats":{"hp":1},"tags":["a",""]}]`)); len(errs) != 0 {
+		t.Errorf("valid json is rejected: %v", errs)
+	}
+
+	for json, except := range map[string]string{
+		`[{"stats":{"hp":"1"},"tags":["a","b"]}]`:      `/0/stats/hp: "1" should be integer or null`,
+		`[{"stats":{"hp":1,"mp":1},"tags":["a","b"]}]`: "/0/stats/mp: additional property is not allowed",
+		`[{"stats":{"hp":1},"tags":["a","b","c"]}]`:    "/0/tags: array should have 2 or less items, but 3",
+		`[{"stats":{"hp":1},"tags":[1,"b"]}]`:          "/0/tags/0: 1 should be string",
+		`[{"stats":null,"tags":["a","b"]}]`:            "/0/stats: null should be object",
+	} {
+		errs := s.Validate(decodeString(t, json))
+		if len(errs) != 1 || errs[0].Error() != except {
+			t.Errorf("Mismatch errors of %s. except %s, actual %v", json, except, errs)
+		}
+	}
+}
+
+func TestValidateKeyedRows(t *testing.T) {
+	header := cxtj.XlsxHeaderMap{
+		"keyed": {
+			"id":   {Index: 0, ValueType: "int", PrimaryKey: true},
+			"name": {Index: 1, ValueType: "string"},
+		},
+		"plain": {
+			"id": {Index: 0, ValueType: "int"},
+		},
+	}
+	schemas := FromHeaderByOutput(header, config.OutputConfig{RowShape: config.RowShapeKeyed, EmptyCell: config.EmptyCellOmit})
+	if schemas["plain"].Items == nil {
+		t.Errorf("sheet without primary key should be an array. actual %+v", schemas["plain"])
+	}
+
+	v := decodeString(t, `{"keyed": {"1": {"id": 1, "name": "a"}, "2": {"name": "b"}, "3": {"id": null}}, "plain": [{"id": 1}]}`)
+	errs := ValidateOutput(schemas, v, config.OutputShapeObject)
+	expect := []string{
+		`/keyed/2: required property "id" is missing`,
+		`/keyed/3/id: null should be integer`,
+	}
+	if len(errs) != len(expect) {
+		t.Fatalf("invalid validation errors. expect: %v, actual: %v", expect, errs)
+	}
+	for i, e := range errs {
+		if e.Error() != expect[i] {
+			t.Errorf("invalid validation error. expect: %s, actual: %s", expect[i], e)
+		}
+	}
+}